@@ -0,0 +1,105 @@
+package intfact
+
+import (
+	"context"
+	"github.com/ghhenry/primes"
+	"math/big"
+)
+
+// defaultD is the default giant step of the baby-step giant-step phase2.
+const defaultD = 2310
+
+// babySteps returns the indices j in [1, d/2] that are coprime to d and the primes dividing d
+// in this range, so that every odd prime q can be written as q = kd ± j with one of them.
+func babySteps(d uint32) []uint32 {
+	var js []uint32
+	for j := uint32(1); j <= d/2; j++ {
+		g := gcd32(j, d)
+		if g == 1 || g == j && isPrime32(j) {
+			js = append(js, j)
+		}
+	}
+	return js
+}
+
+func isPrime32(j uint32) bool {
+	if j < 2 {
+		return false
+	}
+	for i := uint32(2); i*i <= j; i++ {
+		if j%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func gcd32(a, b uint32) uint32 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// bsgsPhase2 runs the baby-step giant-step phase2 for the primes in (b, b1].
+// Each prime q is written as q = kd ± j with 0 < j <= d/2, and the difference giant(k) - baby[j]
+// is multiplied into the gcd test. The values are chosen by the caller such that the
+// difference vanishes modulo a factor p if the phase1 result has order q modulo p.
+// Since the difference is symmetric in ±j, the primes kd-j and kd+j share one product.
+//
+// baby is indexed by j and has entries for the values returned by babySteps(d).
+// giant is called with increasing k. It may return nil to skip the primes around kd,
+// which must then be covered otherwise.
+// The function returns the factor or the error and the prime processed at that time.
+func bsgsPhase2(ctx context.Context, n *big.Int, b, b1, d uint32, baby []*big.Int,
+	giant func(k uint32) (*big.Int, error)) (fac *big.Int, prime uint32, err error) {
+	gcd := newGcdtest(n, 100)
+	// used marks the j whose product has been taken for the current k
+	used := make([]bool, d/2+1)
+	k := ^uint32(0)
+	var g *big.Int
+	diff := new(big.Int)
+	phase2 := func(q uint32) bool {
+		select {
+		case <-ctx.Done():
+			err = cancelled(ctx)
+			return true
+		default:
+		}
+		prime = q
+		qk := uint32((uint64(q) + uint64(d/2)) / uint64(d))
+		if qk != k {
+			k = qk
+			g, err = giant(k)
+			if err != nil {
+				return true
+			}
+			for i := range used {
+				used[i] = false
+			}
+		}
+		if g == nil {
+			return false
+		}
+		j := int64(q) - int64(k)*int64(d)
+		if j < 0 {
+			j = -j
+		}
+		if used[j] {
+			return false
+		}
+		used[j] = true
+		diff.Sub(g, baby[j])
+		fac, err = gcd.test(diff)
+		return fac != nil || err != nil
+	}
+	primes.Iterate(b+1, b1, phase2)
+	if fac != nil || err != nil {
+		return
+	}
+	fac, err = gcd.finish()
+	if fac != nil || err != nil {
+		return
+	}
+	return nil, prime, ErrNoFactor
+}
@@ -0,0 +1,58 @@
+package intfact
+
+import (
+	"context"
+	"fmt"
+	"github.com/ghhenry/primes"
+	"math/big"
+	"testing"
+)
+
+func TestBabySteps(t *testing.T) {
+	for _, d := range []uint32{4, 6, 30, 210, 2310} {
+		js := babySteps(d)
+		has := make(map[uint32]bool)
+		for _, j := range js {
+			has[j] = true
+		}
+		primes.Iterate(3, 100000, func(q uint32) bool {
+			k := (q + d/2) / d
+			j := int64(q) - int64(k)*int64(d)
+			if j < 0 {
+				j = -j
+			}
+			if !has[uint32(j)] {
+				t.Errorf("D=%v: prime %v is not covered", d, q)
+				return true
+			}
+			return false
+		})
+	}
+	// phi(2310)/2 coprime values and the primes 2, 3, 5, 7, 11
+	if got := len(babySteps(2310)); got != 245 {
+		t.Errorf("got %v baby steps for 2310, want 245", got)
+	}
+}
+
+func TestEcPhase2D(t *testing.T) {
+	// find a curve that finds the factor in phase2, all D must give the same factor
+	n := big.NewInt(43217358712783469)
+	var res *EcResult
+	for sigma := int64(6); res == nil; sigma++ {
+		r, err := EcWithOptions(context.Background(), nil, n, 200, 50000, &EcOptions{Sigma: big.NewInt(sigma)})
+		if err == nil && r.Stage == 2 {
+			res = r
+		}
+	}
+	for _, d := range []uint32{4, 30, 210, 30030} {
+		t.Run(fmt.Sprint(d), func(t *testing.T) {
+			r, err := EcWithOptions(context.Background(), nil, n, 200, 50000, &EcOptions{Sigma: res.Sigma, D: d})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if r.Factor.Cmp(res.Factor) != 0 || r.Stage != 2 {
+				t.Errorf("got %+v, want %+v", r, res)
+			}
+		})
+	}
+}
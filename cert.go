@@ -0,0 +1,417 @@
+package intfact
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// CertType is the theorem used by a step of a primality certificate.
+type CertType int
+
+// CertType values
+const (
+	// CertPratt is Lucas' theorem with the complete factorisation of N-1 and
+	// one base A.
+	CertPratt CertType = iota + 1
+	// CertPocklington is Pocklington's theorem with the primes F of the
+	// factored part of N-1, which is larger than sqrt(N), and a base A for each of them.
+	CertPocklington
+	// CertBLS is the Brillhart-Lehmer-Selfridge cube root test, a Pocklington
+	// step with a factored part of N-1 that is larger than the cube root of N.
+	CertBLS
+	// CertMorrison is Morrison's test with the primes F of the factored part of
+	// N+1, which is larger than sqrt(N)+1, and the Lucas sequence with the parameters P and Q.
+	CertMorrison
+	// CertECPP is a step of the elliptic curve primality proof.
+	CertECPP
+)
+
+var certTypeNames = [...]string{
+	CertPratt:       "Pratt",
+	CertPocklington: "Pocklington",
+	CertBLS:         "BLS",
+	CertMorrison:    "Morrison",
+	CertECPP:        "ECPP",
+}
+
+func (t CertType) String() string {
+	if t > 0 && int(t) < len(certTypeNames) {
+		return certTypeNames[t]
+	}
+	return "CertType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// CertStep proves that N is prime if the primes it depends on are prime.
+// These are the primes F for the tests on N-1 and N+1, and ECPP.Q for an ECPP step.
+type CertStep struct {
+	Type CertType
+	N    *big.Int
+	// F are the prime factors of N-1 or N+1.
+	F []*big.Int
+	// A are the bases, one for Pratt, one for each prime F for Pocklington and BLS.
+	A []int64
+	// P and Q are the parameters of the Lucas sequence of Morrison's test.
+	P, Q int64
+	// ECPP is the elliptic curve step.
+	ECPP *ECPPStep
+}
+
+// Certificate is a primality certificate. The first step proves the primality
+// of the certified number. Each prime a step depends on is below 3.3·10^24, so
+// that a deterministic Miller-Rabin test proves it, or it is proven by a later step.
+type Certificate []CertStep
+
+// ErrInvalidCertificate is reported by VerifyCertificate and UnmarshalText.
+var ErrInvalidCertificate = errors.New("invalid primality certificate")
+
+func certError(i int, format string, a ...interface{}) error {
+	return fmt.Errorf("%w: step %d: %s", ErrInvalidCertificate, i+1, fmt.Sprintf(format, a...))
+}
+
+// ecppCertificate returns the certificate for the ECPP steps.
+func ecppCertificate(steps []ECPPStep) Certificate {
+	var c Certificate
+	for i := range steps {
+		s := steps[i]
+		c = append(c, CertStep{Type: CertECPP, N: s.N, ECPP: &s})
+	}
+	return c
+}
+
+// dedup removes the steps for numbers that an earlier step already proves.
+func (c Certificate) dedup() Certificate {
+	seen := make(map[string]bool)
+	var r Certificate
+	for _, s := range c {
+		k := s.N.String()
+		if !seen[k] {
+			seen[k] = true
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// deps returns the primes the step depends on.
+func (s *CertStep) deps() []*big.Int {
+	if s.Type == CertECPP {
+		return []*big.Int{s.ECPP.Q}
+	}
+	return s.F
+}
+
+// VerifyCertificate checks that c proves the primality of n. It relies only on
+// the arithmetic of the steps and, for the primes below 3.3·10^24, on the
+// deterministic Miller-Rabin test, so that it does not trust the prover.
+// For such a small n the certificate may be empty.
+// The error matches ErrInvalidCertificate if the certificate is wrong.
+func VerifyCertificate(n *big.Int, c Certificate) error {
+	if len(c) == 0 {
+		if prime, proven := IsPrime(n); !prime || !proven {
+			return fmt.Errorf("%w: no steps for %v", ErrInvalidCertificate, n)
+		}
+		return nil
+	}
+	if c[0].N == nil || c[0].N.Cmp(n) != 0 {
+		return fmt.Errorf("%w: the first step is not for %v", ErrInvalidCertificate, n)
+	}
+	proven := make(map[string]bool)
+	for i := len(c) - 1; i >= 0; i-- {
+		s := &c[i]
+		if s.N == nil || s.Type == CertECPP && s.ECPP == nil {
+			return certError(i, "missing values")
+		}
+		for _, q := range s.deps() {
+			if q == nil {
+				return certError(i, "missing values")
+			}
+			if proven[q.String()] {
+				continue
+			}
+			if prime, small := IsPrime(q); !prime || !small {
+				return certError(i, "%v is not proven prime", q)
+			}
+		}
+		if err := s.verify(); err != nil {
+			return certError(i, "%v: %v", s.Type, err)
+		}
+		proven[s.N.String()] = true
+	}
+	return nil
+}
+
+// verify checks the step, assuming that the primes it depends on are prime.
+func (s *CertStep) verify() error {
+	n := s.N
+	if s.Type == CertECPP {
+		if s.ECPP.N == nil || s.ECPP.N.Cmp(n) != 0 {
+			return errors.New("N of the curve differs")
+		}
+		for _, x := range []*big.Int{s.ECPP.A, s.ECPP.B, s.ECPP.M, s.ECPP.X, s.ECPP.Y} {
+			if x == nil {
+				return errors.New("missing values")
+			}
+		}
+		return s.ECPP.check()
+	}
+	if n.Cmp(bigThree) < 0 || n.Bit(0) == 0 {
+		return errors.New("N must be odd and at least 3")
+	}
+	nm1 := new(big.Int).Sub(n, bigOne)
+	switch s.Type {
+	case CertPratt:
+		if len(s.A) != 1 {
+			return errors.New("need one base")
+		}
+		if f := factoredPart(nm1, s.F); f == nil || f.Cmp(nm1) != 0 {
+			return errors.New("N-1 is not factored completely")
+		}
+		a := big.NewInt(s.A[0])
+		t := new(big.Int)
+		if t.Exp(a, nm1, n).Cmp(bigOne) != 0 {
+			return errors.New("Fermat test failed")
+		}
+		for _, q := range s.F {
+			if t.Exp(a, t.Div(nm1, q), n).Cmp(bigOne) == 0 {
+				return fmt.Errorf("A^((N-1)/%v) = 1", q)
+			}
+		}
+	case CertPocklington, CertBLS:
+		if len(s.A) != len(s.F) {
+			return errors.New("need a base for each prime")
+		}
+		f := factoredPart(nm1, s.F)
+		if f == nil {
+			return errors.New("a prime does not divide N-1")
+		}
+		t := new(big.Int)
+		for i, q := range s.F {
+			a := big.NewInt(s.A[i])
+			if t.Exp(a, nm1, n).Cmp(bigOne) != 0 {
+				return errors.New("Fermat test failed")
+			}
+			t.Exp(a, t.Div(nm1, q), n)
+			if t.GCD(nil, nil, t.Sub(t, bigOne), n).Cmp(bigOne) != 0 {
+				return fmt.Errorf("gcd(A^((N-1)/%v) - 1, N) != 1", q)
+			}
+		}
+		switch {
+		case t.Mul(f, f).Cmp(n) > 0:
+		case s.Type == CertPocklington:
+			return errors.New("factored part below sqrt(N)")
+		case t.Mul(t, f).Cmp(n) <= 0:
+			return errors.New("factored part below the cube root of N")
+		case !blsSquare(n, f):
+			return errors.New("c1^2 - 4 c2 is a square")
+		}
+	case CertMorrison:
+		np1 := new(big.Int).Add(n, bigOne)
+		f := factoredPart(np1, s.F)
+		if f == nil {
+			return errors.New("a prime does not divide N+1")
+		}
+		if t := new(big.Int).Sub(f, bigOne); t.Mul(t, t).Cmp(n) <= 0 {
+			return errors.New("factored part below sqrt(N)+1")
+		}
+		if big.Jacobi(lucasD(s.P, s.Q), n) != -1 {
+			return errors.New("Jacobi(P^2-4Q, N) != -1")
+		}
+		g := new(big.Int)
+		if g.GCD(nil, nil, g.Abs(big.NewInt(s.Q)), n).Cmp(bigOne) != 0 {
+			return errors.New("gcd(Q, N) != 1")
+		}
+		if u, _, _ := lucasUV(s.P, s.Q, np1, n); u.Sign() != 0 {
+			return errors.New("U_(N+1) != 0")
+		}
+		for _, q := range s.F {
+			u, _, _ := lucasUV(s.P, s.Q, new(big.Int).Div(np1, q), n)
+			if g.GCD(nil, nil, u, n).Cmp(bigOne) != 0 {
+				return fmt.Errorf("gcd(U_((N+1)/%v), N) != 1", q)
+			}
+		}
+	default:
+		return errors.New("unknown type")
+	}
+	return nil
+}
+
+// factoredPart returns the product of the powers of the primes qs dividing m,
+// or nil if a prime does not divide m or appears twice.
+func factoredPart(m *big.Int, qs []*big.Int) *big.Int {
+	f := big.NewInt(1)
+	r := new(big.Int).Set(m)
+	t := new(big.Int)
+	for _, q := range qs {
+		if q.Cmp(bigOne) <= 0 || t.Mod(r, q).Sign() != 0 {
+			return nil
+		}
+		for t.Mod(r, q).Sign() == 0 {
+			r.Div(r, q)
+			f.Mul(f, q)
+		}
+	}
+	return f
+}
+
+// MarshalText encodes the certificate in a text format similar to the one of
+// Primo. Each step is a block of key=value lines, that starts with the step number
+// in brackets and the type:
+//
+//	[intfact certificate]
+//	[1]
+//	Type=Pocklington
+//	N=...
+//	F=2
+//	A=3
+//	...
+//
+// Pratt, Pocklington and BLS steps list the primes F and the bases A, Morrison
+// steps P, Q and the primes F, and ECPP steps the values A, B, M, Q, X and Y of
+// ECPPStep.
+func (c Certificate) MarshalText() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("[intfact certificate]\n")
+	for i, s := range c {
+		if s.N == nil || s.Type == CertECPP && s.ECPP == nil {
+			return nil, certError(i, "missing values")
+		}
+		fmt.Fprintf(&b, "\n[%d]\nType=%v\nN=%v\n", i+1, s.Type, s.N)
+		switch s.Type {
+		case CertPratt:
+			for _, q := range s.F {
+				fmt.Fprintf(&b, "F=%v\n", q)
+			}
+			for _, a := range s.A {
+				fmt.Fprintf(&b, "A=%v\n", a)
+			}
+		case CertPocklington, CertBLS:
+			for i, q := range s.F {
+				fmt.Fprintf(&b, "F=%v\n", q)
+				if i < len(s.A) {
+					fmt.Fprintf(&b, "A=%v\n", s.A[i])
+				}
+			}
+		case CertMorrison:
+			fmt.Fprintf(&b, "P=%v\nQ=%v\n", s.P, s.Q)
+			for _, q := range s.F {
+				fmt.Fprintf(&b, "F=%v\n", q)
+			}
+		case CertECPP:
+			e := s.ECPP
+			fmt.Fprintf(&b, "A=%v\nB=%v\nM=%v\nQ=%v\nX=%v\nY=%v\n", e.A, e.B, e.M, e.Q, e.X, e.Y)
+		default:
+			return nil, certError(i, "unknown type %v", s.Type)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func (c Certificate) String() string {
+	b, err := c.MarshalText()
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// UnmarshalText decodes a certificate in the format of MarshalText.
+// It does not verify the certificate.
+func (c *Certificate) UnmarshalText(text []byte) error {
+	var r Certificate
+	var s *CertStep
+	sc := bufio.NewScanner(bytes.NewReader(text))
+	sc.Buffer(nil, 1<<24)
+	for line := 1; sc.Scan(); line++ {
+		l := strings.TrimSpace(sc.Text())
+		bad := func(format string, a ...interface{}) error {
+			return fmt.Errorf("%w: line %d: %s", ErrInvalidCertificate, line, fmt.Sprintf(format, a...))
+		}
+		switch {
+		case l == "" || l == "[intfact certificate]":
+			continue
+		case strings.HasPrefix(l, "[") && strings.HasSuffix(l, "]"):
+			if k, err := strconv.Atoi(l[1 : len(l)-1]); err != nil || k != len(r)+1 {
+				return bad("unexpected step %s", l)
+			}
+			r = append(r, CertStep{})
+			s = &r[len(r)-1]
+			continue
+		}
+		key, val, ok := strings.Cut(l, "=")
+		if !ok || s == nil {
+			return bad("unexpected %q", l)
+		}
+		if key == "Type" {
+			if s.Type != 0 {
+				return bad("second type")
+			}
+			for t, name := range certTypeNames {
+				if name != "" && name == val {
+					s.Type = CertType(t)
+				}
+			}
+			if s.Type == 0 {
+				return bad("unknown type %q", val)
+			}
+			if s.Type == CertECPP {
+				s.ECPP = &ECPPStep{}
+			}
+			continue
+		}
+		if s.Type == 0 {
+			return bad("missing type")
+		}
+		var dst **big.Int
+		var small *int64
+		switch {
+		case key == "N":
+			dst = &s.N
+		case key == "F" && s.Type != CertECPP:
+			s.F = append(s.F, nil)
+			dst = &s.F[len(s.F)-1]
+		case key == "A" && s.Type != CertECPP && s.Type != CertMorrison:
+			s.A = append(s.A, 0)
+			small = &s.A[len(s.A)-1]
+		case key == "P" && s.Type == CertMorrison:
+			small = &s.P
+		case key == "Q" && s.Type == CertMorrison:
+			small = &s.Q
+		case s.Type == CertECPP:
+			dst = map[string]**big.Int{"A": &s.ECPP.A, "B": &s.ECPP.B, "M": &s.ECPP.M,
+				"Q": &s.ECPP.Q, "X": &s.ECPP.X, "Y": &s.ECPP.Y}[key]
+		}
+		switch {
+		case dst != nil:
+			x, ok := new(big.Int).SetString(val, 10)
+			if !ok {
+				return bad("invalid number %q", val)
+			}
+			*dst = x
+		case small != nil:
+			x, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return bad("invalid number %q", val)
+			}
+			*small = x
+		default:
+			return bad("unknown key %q for %v", key, s.Type)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	for i := range r {
+		if s := &r[i]; s.N == nil {
+			return certError(i, "missing N")
+		} else if s.Type == CertECPP {
+			s.ECPP.N = s.N
+		}
+	}
+	*c = r
+	return nil
+}
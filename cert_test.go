@@ -0,0 +1,195 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// pratt89 is a Pratt certificate for 2^89-1.
+func pratt89() Certificate {
+	var fs []*big.Int
+	for _, q := range []int64{2, 3, 5, 17, 23, 89, 353, 397, 683, 2113, 2931542417} {
+		fs = append(fs, big.NewInt(q))
+	}
+	return Certificate{{Type: CertPratt, N: intval("618970019642690137449562111"), F: fs, A: []int64{3}}}
+}
+
+func TestPrimeCertificate(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *big.Int
+	}{
+		{"small prime", big.NewInt(1000003)},
+		{"m89", intval("618970019642690137449562111")},
+		{"m127", intval("170141183460469231731687303715884105727")},
+		{"40 digits", intval("1000000000000000000000000000000000000003")},
+		{"50 digits", intval("10000000000000000000000000000000000000000000000009")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := PrimeCertificate(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if err := VerifyCertificate(tt.n, c); err != nil {
+				t.Fatal("verification failed:", err)
+			}
+			text, err := c.MarshalText()
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			var d Certificate
+			if err := d.UnmarshalText(text); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if err := VerifyCertificate(tt.n, d); err != nil {
+				t.Fatal("verification of the decoded certificate failed:", err)
+			}
+			if d.String() != string(text) {
+				t.Errorf("got\n%v\nwant\n%s", d, text)
+			}
+		})
+	}
+	// F7 = 2^128+1
+	if _, err := PrimeCertificate(context.Background(), intval("340282366920938463463374607431768211457")); err != ErrComposite {
+		t.Errorf("got %v, want ErrComposite", err)
+	}
+}
+
+func TestCertificateTypes(t *testing.T) {
+	m89 := intval("618970019642690137449562111")
+	m127 := intval("170141183460469231731687303715884105727")
+	var qs []*big.Int
+	f := big.NewInt(1)
+	for _, q := range []int64{2, 3, 5, 17, 23, 89, 353, 397} {
+		qs = append(qs, big.NewInt(q))
+		f.Mul(f, big.NewInt(q))
+	}
+	_, bls, _ := pocklington(m89, f, qs)
+	qs = append(qs, big.NewInt(683), big.NewInt(2113))
+	f.Mul(f, big.NewInt(683*2113))
+	_, pock, _ := pocklington(m89, f, qs)
+	_, morr, _ := morrison(m89, []*big.Int{big.NewInt(2)})
+	steps, err := ECPP(context.Background(), m127)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	tests := []struct {
+		n    *big.Int
+		c    Certificate
+		want CertType
+	}{
+		{m89, pratt89(), CertPratt},
+		{m89, Certificate{*bls}, CertBLS},
+		{m89, Certificate{*pock}, CertPocklington},
+		{m89, Certificate{*morr}, CertMorrison},
+		{m127, ecppCertificate(steps), CertECPP},
+	}
+	for _, tt := range tests {
+		if tt.c[0].Type != tt.want {
+			t.Errorf("got type %v, want %v", tt.c[0].Type, tt.want)
+		}
+		if err := VerifyCertificate(tt.n, tt.c); err != nil {
+			t.Errorf("%v: %v", tt.want, err)
+		}
+		text, _ := tt.c.MarshalText()
+		if !strings.Contains(string(text), "Type="+tt.want.String()) {
+			t.Errorf("%v: got\n%s", tt.want, text)
+		}
+	}
+}
+
+func TestVerifyCertificateInvalid(t *testing.T) {
+	m89 := intval("618970019642690137449562111")
+	m127 := intval("170141183460469231731687303715884105727")
+	steps, err := ECPP(context.Background(), m127)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	big89 := new(big.Int).Set(m89)
+	tests := []struct {
+		name   string
+		n      *big.Int
+		modify func(c Certificate) Certificate
+	}{
+		{"wrong n", big.NewInt(1000003), nil},
+		{"empty", m89, func(c Certificate) Certificate { return nil }},
+		{"no generator", m89, func(c Certificate) Certificate { c[0].A = []int64{2}; return c }},
+		{"incomplete", m89, func(c Certificate) Certificate { c[0].F = c[0].F[1:]; return c }},
+		{"duplicate prime", m89, func(c Certificate) Certificate { c[0].F = append(c[0].F, big.NewInt(3)); return c }},
+		{"composite", new(big.Int).Add(big89, big.NewInt(2)), func(c Certificate) Certificate {
+			c[0].N = new(big.Int).Add(big89, big.NewInt(2))
+			return c
+		}},
+		{"not BLS", m89, func(c Certificate) Certificate {
+			c[0].Type, c[0].A = CertPocklington, []int64{3, 3, 3, 3, 3, 3, 3, 3}
+			c[0].F = c[0].F[:8]
+			return c
+		}},
+		{"unknown type", m89, func(c Certificate) Certificate { c[0].Type = 0; return c }},
+	}
+	for _, tt := range tests {
+		c := pratt89()
+		if tt.modify != nil {
+			c = tt.modify(c)
+		}
+		if err := VerifyCertificate(tt.n, c); !errors.Is(err, ErrInvalidCertificate) {
+			t.Errorf("%v: got %v", tt.name, err)
+		}
+	}
+	ecpp := []func(c Certificate){
+		func(c Certificate) { c[0].ECPP.X = new(big.Int).Add(c[0].ECPP.X, bigOne) },
+		func(c Certificate) { c[0].ECPP.Q = new(big.Int).Add(c[0].ECPP.Q, big.NewInt(2)) },
+		func(c Certificate) { c[0].ECPP.M = new(big.Int).Add(c[0].ECPP.M, c[0].ECPP.Q) },
+		func(c Certificate) { c[0].ECPP.N = m89 },
+	}
+	for i, modify := range ecpp {
+		c := ecppCertificate(steps)
+		e := *c[0].ECPP
+		c[0].ECPP = &e
+		modify(c)
+		if err := VerifyCertificate(m127, c); !errors.Is(err, ErrInvalidCertificate) {
+			t.Errorf("ECPP %v: got %v", i, err)
+		}
+	}
+	// a large prime that is not proven
+	c := ecppCertificate(steps)
+	if len(c) > 1 {
+		if err := VerifyCertificate(m127, c[:1]); !errors.Is(err, ErrInvalidCertificate) {
+			t.Errorf("got %v", err)
+		}
+	}
+}
+
+func TestCertificateUnmarshal(t *testing.T) {
+	tests := []string{
+		"N=5",
+		"[2]\nType=Pratt\nN=5",
+		"[1]\nN=5",
+		"[1]\nType=Foo\nN=5",
+		"[1]\nType=Pratt\nN=five",
+		"[1]\nType=Pratt\nN=5\nP=2",
+		"[1]\nType=Pratt\nF=2",
+		"[1]\nType=Pratt\nType=Pratt\nN=5",
+	}
+	for _, text := range tests {
+		var c Certificate
+		if err := c.UnmarshalText([]byte(text)); !errors.Is(err, ErrInvalidCertificate) {
+			t.Errorf("%q: got %v", text, err)
+		}
+	}
+	text := "[intfact certificate]\n\n[1]\nType=Pratt\nN=5\nF=2\nA=2\n"
+	var c Certificate
+	if err := c.UnmarshalText([]byte(text)); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if err := VerifyCertificate(big.NewInt(5), c); err != nil {
+		t.Error("unexpected error", err)
+	}
+	if c.String() != text {
+		t.Errorf("got %q, want %q", c.String(), text)
+	}
+}
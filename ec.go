@@ -27,14 +27,6 @@ func (r *lcRandom) Read(p []byte) (int, error) {
 	return len(p), nil
 }
 
-type factorError struct {
-	f *big.Int
-}
-
-func (e factorError) Error() string {
-	return "modulus is not prime, a factor is: " + e.f.String()
-}
-
 type curve struct {
 	n *big.Int
 	a *big.Int
@@ -88,23 +80,27 @@ func randCurve(random io.Reader, n *big.Int) (*curve, point) {
 	for {
 		px, _ := rand.Int(random, n)
 		py, _ := rand.Int(random, n)
-		p := ordinary{px, py}
 		a, _ := rand.Int(random, n)
-		// calculate b as y**2 - x(x**2+a)
-		b := new(big.Int).Mul(py, py)
-		t0 := new(big.Int).Mul(px, px)
-		t0.Add(t0, a)
-		t0.Mod(t0, n)
-		t0.Mul(t0, px)
-		b.Sub(b, t0)
-		b.Mod(b, n)
-		c := &curve{n, a, b}
+		c, p := curveThrough(n, a, px, py)
 		if c.isNonSingular() {
 			return c, p
 		}
 	}
 }
 
+// curveThrough returns the curve y^2 = x^3 + ax + b through the point (px, py).
+func curveThrough(n, a, px, py *big.Int) (*curve, point) {
+	// calculate b as y**2 - x(x**2+a)
+	b := new(big.Int).Mul(py, py)
+	t0 := new(big.Int).Mul(px, px)
+	t0.Add(t0, a)
+	t0.Mod(t0, n)
+	t0.Mul(t0, px)
+	b.Sub(b, t0)
+	b.Mod(b, n)
+	return &curve{n, a, b}, ordinary{px, py}
+}
+
 func (c *curve) neg(a point) point {
 	if a.isZero() {
 		return a
@@ -141,7 +137,7 @@ func (c *curve) double(a point) (point, error) {
 	t0.Mod(t0, c.n)
 	t1 := new(big.Int).GCD(t0, nil, t0, c.n)
 	if t1.Cmp(bigOne) != 0 {
-		return nil, factorError{t1}
+		return nil, FactorFoundError{t1}
 	}
 	t1.Mul(a.x(), a.x())
 	t1.Mod(t1, c.n)
@@ -166,7 +162,7 @@ func (c *curve) rawAdd(a, b point) (point, error) {
 	t0.Mod(t0, c.n)
 	t1 := new(big.Int).GCD(t0, nil, t0, c.n)
 	if t1.Cmp(bigOne) != 0 {
-		return nil, factorError{t1}
+		return nil, FactorFoundError{t1}
 	}
 	t1.Sub(a.y(), b.y())
 	t0.Mul(t1, t0)
@@ -226,32 +222,65 @@ func (c *curve) mult(p point, m *big.Int) (point, error) {
 	return r, nil
 }
 
-type p2echelper struct {
-	c         *curve
-	multiples []point
+// CurveModel selects the curves and the arithmetic used by EcWithOptions.
+type CurveModel int
+
+// CurveModel values
+const (
+	// Weierstrass uses random curves y^2 = x^3 + ax + b with affine coordinates, or the curve
+	// of Suyama's parametrization in this form if a sigma is given. A random curve is
+	// reported as a WeierstrassCurve.
+	// Every group operation needs a modular inversion.
+	Weierstrass CurveModel = iota
+	// Montgomery uses curves By^2 = x^3 + Ax^2 + x with Suyama's parametrization
+	// and XZ coordinates, which need no inversions.
+	Montgomery
+	// Edwards uses Edwards curves x^2 + y^2 = 1 + dx^2y^2 with a large torsion group
+	// and extended coordinates, which need no inversions.
+	Edwards
+)
+
+// EcOptions contains the options of EcWithOptions.
+// A nil pointer or zero fields select the defaults.
+type EcOptions struct {
+	// Model selects the curves (default Weierstrass).
+	Model CurveModel
+	// Torsion selects the family of Edwards curves (default Z12).
+	Torsion Torsion
+	// Sigma selects the curve, the same sigma always gives the same curve.
+	// For Weierstrass and Montgomery curves it is the parameter of Suyama's parametrization
+	// as used by GMP-ECM, for Edwards curves the multiple of the generator in 1 <= sigma < 2^64.
+	// If Sigma is nil, a random curve is selected. Random Weierstrass curves are drawn
+	// with random coefficients and have no sigma, they are selected by Curve instead.
+	Sigma *big.Int
+	// Curve selects a random Weierstrass curve as reported in EcResult.
+	// It requires the Weierstrass model and takes precedence over Sigma.
+	Curve *WeierstrassCurve
+	// D is the giant step of phase2 (default 2310). It must be even and at least 4.
+	D uint32
 }
 
-func newEcHelper(a point, c *curve) (*p2echelper, error) {
-	p2, err := c.double(a)
-	if err != nil {
-		return nil, err
-	}
-	return &p2echelper{
-		c:         c,
-		multiples: []point{p2},
-	}, nil
+// WeierstrassCurve describes the curve y^2 = x^3 + Ax + B through the point (X, Y)
+// modulo n. B follows from the point.
+type WeierstrassCurve struct {
+	A, X, Y *big.Int
 }
 
-func (h *p2echelper) getMultiple(e uint32) (point, error) {
-	i := int(e/2 - 1)
-	for i >= len(h.multiples) {
-		k, err := h.c.add(h.multiples[0], h.multiples[len(h.multiples)-1])
-		if err != nil {
-			return nil, err
-		}
-		h.multiples = append(h.multiples, k)
-	}
-	return h.multiples[i], nil
+// EcResult describes the curve on which a factor was found.
+type EcResult struct {
+	// Factor is the factor found.
+	Factor *big.Int
+	// Sigma selects the curve as in EcOptions. It is nil for a random Weierstrass curve.
+	Sigma *big.Int
+	// Curve describes a random Weierstrass curve, which is selected again by passing
+	// it in EcOptions. It is nil for the other curves.
+	Curve *WeierstrassCurve
+	// Stage is 1 or 2 for the phase in which the factor was found.
+	Stage int
+	// Prime is the prime processed when the factor was detected, or 0 if the factor was found before
+	// the first prime of the stage. Since gcds are calculated in batches, the factor may have appeared
+	// with one of the primes shortly before.
+	Prime uint32
 }
 
 // Ec tries to find a factor of n using randomness from random for the curve selection.
@@ -259,13 +288,110 @@ func (h *p2echelper) getMultiple(e uint32) (point, error) {
 // context can be used to cancel the factorization.
 //
 // The function returns a factor in the first return value if there was one found. Otherwise, an error
-// is returned in the second return value.
+// is returned in the second return value: ErrNoFactor, ErrTrivialGCD if the curve order is smooth
+// for all factors of n, or an error matching ErrCancelled.
 func Ec(ctx context.Context, random io.Reader, n *big.Int, b, b1 uint32) (fac *big.Int, err error) {
-	c, pt := randCurve(random, n)
+	res, err := EcWithOptions(ctx, random, n, b, b1, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Factor, nil
+}
+
+// EcWithOptions is Ec with a choice of the curve model and the curve.
+// With Montgomery curves phase1 uses the Montgomery ladder, with Edwards curves it uses
+// double and add in extended coordinates. In both cases phase2 continues on
+// the equivalent curve in Weierstrass form.
+// If a factor is found, the result describes the curve, so that the run can be repeated
+// with the same sigma, or with the same curve for random Weierstrass curves.
+func EcWithOptions(ctx context.Context, random io.Reader, n *big.Int, b, b1 uint32, opts *EcOptions) (*EcResult, error) {
+	var o EcOptions
+	if opts != nil {
+		o = *opts
+	}
+	sigma := o.Sigma
+	d := o.D
+	if d == 0 {
+		d = defaultD
+	}
+	if d < 4 || d%2 != 0 {
+		return nil, errors.New("D must be even and at least 4")
+	}
+	if o.Curve != nil && o.Model != Weierstrass {
+		return nil, errors.New("Curve requires the Weierstrass model")
+	}
+	switch o.Model {
+	case Weierstrass:
+		if o.Curve != nil || sigma == nil {
+			var c *curve
+			var pt point
+			if o.Curve != nil {
+				w := o.Curve
+				mod := func(x *big.Int) *big.Int { return new(big.Int).Mod(x, n) }
+				c, pt = curveThrough(n, mod(w.A), mod(w.X), mod(w.Y))
+				if !c.isNonSingular() {
+					return nil, errors.New("singular curve")
+				}
+			} else {
+				c, pt = randCurve(random, n)
+			}
+			w := &WeierstrassCurve{A: new(big.Int).Set(c.a), X: new(big.Int).Set(pt.x()), Y: new(big.Int).Set(pt.y())}
+			res, err := ecWeierstrass(ctx, c, pt, nil, b, b1, d)
+			if res != nil {
+				res.Curve = w
+			}
+			return res, err
+		}
+		mc, mpt, err := suyamaCurve(sigma, n)
+		if err != nil {
+			return stageResult(err, n, sigma, 1, 0)
+		}
+		c, pt, err := mc.weierstrass(mpt)
+		if err != nil {
+			return stageResult(err, n, sigma, 1, 0)
+		}
+		return ecWeierstrass(ctx, c, pt, sigma, b, b1, d)
+	case Montgomery:
+		if sigma == nil {
+			// sigma is chosen from [6, 2^63+6)
+			var err error
+			sigma, err = rand.Int(random, new(big.Int).Lsh(bigOne, 63))
+			if err != nil {
+				return nil, err
+			}
+			sigma.Add(sigma, big.NewInt(6))
+		}
+		return ecMontgomery(ctx, sigma, n, b, b1, d)
+	case Edwards:
+		if o.Torsion != Z12 && o.Torsion != Z2xZ8 {
+			return nil, errors.New("unknown torsion")
+		}
+		if sigma == nil {
+			// sigma is chosen from [1, 2^32]
+			var err error
+			sigma, err = rand.Int(random, new(big.Int).Lsh(bigOne, 32))
+			if err != nil {
+				return nil, err
+			}
+			sigma.Add(sigma, bigOne)
+		}
+		if sigma.Sign() <= 0 || !sigma.IsUint64() {
+			return nil, errors.New("sigma out of range")
+		}
+		return ecEdwards(ctx, o.Torsion, sigma, n, b, b1, d)
+	}
+	return nil, errors.New("unknown curve model")
+}
+
+// ecWeierstrass runs the elliptic curve method on the point pt of the curve c in Weierstrass form.
+// sigma is reported in the result and is nil for a random curve.
+// See EcWithOptions for the other parameters and the return values.
+func ecWeierstrass(ctx context.Context, c *curve, pt point, sigma *big.Int, b, b1, d uint32) (res *EcResult, err error) {
+	n := c.n
 	phase1 := func(p uint32) bool {
 		select {
 		case <-ctx.Done():
-			err = errors.New("cancelled")
+			err = cancelled(ctx)
 			return true
 		default:
 		}
@@ -279,65 +405,108 @@ func Ec(ctx context.Context, random io.Reader, n *big.Int, b, b1 uint32) (fac *b
 		}
 		pt, err = c.mult(pt, big.NewInt(mult))
 		if err != nil {
-			if e, ok := err.(factorError); ok {
-				//fmt.Println("factor found in phase1 at", p)
-				fac = e.f
-				err = nil
-			}
+			res, err = stageResult(err, n, sigma, 1, p)
 			return true
 		}
 		if pt.isZero() {
-			err = errors.New("no factor found")
+			err = ErrTrivialGCD
 			return true
 		}
 		return false
 	}
 	primes.Iterate(2, b, phase1)
-	if fac != nil || err != nil {
+	if res != nil || err != nil {
 		return
 	}
 
-	// phase2
-	var prev uint32
-	h, err := newEcHelper(pt, c)
+	return ecPhase2(ctx, c, pt, sigma, b, b1, d)
+}
+
+// ecPhase2 runs the second phase of the elliptic curve method on the point pt after phase1 with bound b.
+// It is the baby-step giant-step phase2 with the x-coordinates of j*pt as baby steps and
+// of kd*pt as giant steps. The difference vanishes modulo p if (kd-j)*pt or (kd+j)*pt is zero.
+// The primes below d/2 are covered by the computation of the baby steps.
+// See EcWithOptions for the return values.
+func ecPhase2(ctx context.Context, c *curve, pt point, sigma *big.Int, b, b1, d uint32) (*EcResult, error) {
+	n := c.n
+	baby := make([]*big.Int, d/2+1)
+	for _, j := range babySteps(d) {
+		baby[j] = new(big.Int)
+	}
+	// the odd multiples are computed by adding 2pt
+	pt2, err := c.double(pt)
 	if err != nil {
-		if e, ok := err.(factorError); ok {
-			fac = e.f
-			err = nil
-		}
-		return
+		return stageResult(err, n, sigma, 2, 0)
 	}
-	phase2 := func(p uint32) bool {
-		select {
-		case <-ctx.Done():
-			err = errors.New("cancelled")
-			return true
-		default:
+	if pt2.isZero() {
+		return nil, ErrTrivialGCD
+	}
+	baby[2].Set(pt2.x())
+	cur := pt
+	for j := uint32(1); j <= d/2; j += 2 {
+		if j > 1 {
+			cur, err = c.add(cur, pt2)
+			if err != nil {
+				return stageResult(err, n, sigma, 2, 0)
+			}
+			if cur.isZero() {
+				return nil, ErrTrivialGCD
+			}
 		}
-		if prev == 0 {
-			pt, err = c.mult(pt, big.NewInt(int64(p)))
-		} else {
-			diff := p - prev
-			var ptInc point
-			ptInc, err = h.getMultiple(diff)
+		if baby[j] != nil {
+			baby[j].Set(cur.x())
+		}
+	}
+	var dpt, gk point
+	var last uint32
+	giant := func(k uint32) (*big.Int, error) {
+		var err error
+		switch {
+		case k == 0:
+			return nil, nil
+		case gk == nil || k != last+1:
+			dpt, err = c.mult(pt, big.NewInt(int64(d)))
 			if err == nil {
-				pt, err = c.add(pt, ptInc)
+				gk, err = c.mult(dpt, big.NewInt(int64(k)))
 			}
+		default:
+			gk, err = c.add(gk, dpt)
 		}
 		if err != nil {
-			if e, ok := err.(factorError); ok {
-				//fmt.Println("factor found in phase2 at", p)
-				fac = e.f
-				err = nil
-			}
-			return true
+			return nil, err
 		}
-		prev = p
-		return false
+		if gk.isZero() {
+			return nil, ErrTrivialGCD
+		}
+		last = k
+		return gk.x(), nil
 	}
-	primes.Iterate(b+1, b1, phase2)
-	if fac != nil || err != nil {
-		return
+	fac, prime, err := bsgsPhase2(ctx, n, b, b1, d, baby, giant)
+	if fac == nil {
+		return stageResult(err, n, sigma, 2, prime)
+	}
+	return &EcResult{Factor: fac, Sigma: sigma, Stage: 2, Prime: prime}, nil
+}
+
+// stageResult converts an error from the curve arithmetic modulo n in the given stage
+// into the return values of EcWithOptions.
+func stageResult(err error, n, sigma *big.Int, stage int, prime uint32) (*EcResult, error) {
+	fac, err := foundFactor(err, n)
+	if err != nil {
+		return nil, err
+	}
+	return &EcResult{Factor: fac, Sigma: sigma, Stage: stage, Prime: prime}, nil
+}
+
+// foundFactor converts a FactorFoundError from the curve arithmetic modulo n
+// into the return values of Ec. Other errors are passed through.
+func foundFactor(err error, n *big.Int) (*big.Int, error) {
+	var e FactorFoundError
+	if !errors.As(err, &e) {
+		return nil, err
+	}
+	if e.Factor.Cmp(n) == 0 {
+		return nil, ErrTrivialGCD
 	}
-	return nil, errors.New("no factor found")
+	return e.Factor, nil
 }
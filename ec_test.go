@@ -2,6 +2,8 @@ package intfact
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -281,9 +283,10 @@ func TestFactorFound(t *testing.T) {
 	if err == nil {
 		t.Fatal("an error was expected")
 	}
-	if e, ok := err.(factorError); ok {
-		if e.f.Cmp(big.NewInt(47)) != 0 && e.f.Cmp(big.NewInt(53)) != 0 {
-			t.Error("invalid factor", e.f)
+	var e FactorFoundError
+	if errors.As(err, &e) {
+		if e.Factor.Cmp(big.NewInt(47)) != 0 && e.Factor.Cmp(big.NewInt(53)) != 0 {
+			t.Error("invalid factor", e.Factor)
 		}
 	} else {
 		t.Error("unexpected error", err)
@@ -517,3 +520,87 @@ func TestEc(t *testing.T) {
 		})
 	}
 }
+
+func TestEcSigma(t *testing.T) {
+	// the factor 7420146347 - 1 = 2 * 3 * 1236691057 is not smooth
+	n := big.NewInt(43217358712783469)
+	tests := []struct {
+		name string
+		opts EcOptions
+	}{
+		{"default", EcOptions{}},
+		{"Weierstrass sigma", EcOptions{Sigma: big.NewInt(1000)}},
+		{"Montgomery", EcOptions{Model: Montgomery}},
+		{"Edwards", EcOptions{Model: Edwards}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			res, err := EcParallelWithOptions(context.Background(), &lcRandom{x: 3}, n, 2000, 50000, 8, &opts)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if res.Stage != 1 && res.Stage != 2 {
+				t.Errorf("invalid stage %v", res.Stage)
+			}
+			if res.Stage == 1 && res.Prime > 2000 || res.Stage == 2 && (res.Prime <= 2000 || res.Prime > 50000) {
+				t.Errorf("prime %v does not belong to stage %v", res.Prime, res.Stage)
+			}
+			// random Weierstrass curves are described by the curve, the others by sigma
+			if (res.Curve != nil) != (opts.Sigma == nil && opts.Model == Weierstrass) || (res.Sigma == nil) == (res.Curve == nil) {
+				t.Fatalf("got sigma %v and curve %v", res.Sigma, res.Curve)
+			}
+			// the same curve gives the same result, even with a different random source
+			opts.Sigma, opts.Curve = res.Sigma, res.Curve
+			again, err := EcWithOptions(context.Background(), &lcRandom{x: 99}, n, 2000, 50000, &opts)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !reflect.DeepEqual(res, again) {
+				t.Errorf("got %+v, want %+v", again, res)
+			}
+		})
+	}
+	_, err := EcWithOptions(context.Background(), nil, n, 2000, 50000,
+		&EcOptions{Model: Montgomery, Curve: &WeierstrassCurve{bigOne, bigOne, bigOne}})
+	if err == nil {
+		t.Error("expected an error for a curve with the Montgomery model")
+	}
+	// sigma = 5 gives a degenerate curve
+	_, err = EcWithOptions(context.Background(), nil, n, 2000, 50000, &EcOptions{Sigma: big.NewInt(5)})
+	if err != ErrTrivialGCD {
+		t.Errorf("got %v, want ErrTrivialGCD", err)
+	}
+	_, err = EcWithOptions(context.Background(), nil, n, 2000, 50000, &EcOptions{Model: Edwards, Sigma: big.NewInt(0)})
+	if err == nil {
+		t.Error("expected an error for sigma 0")
+	}
+}
+
+func benchmarkEc(b *testing.B, opts *EcOptions) {
+	// product of a 90 bit and a 110 bit prime, no factor is found
+	r := &lcRandom{x: 10}
+	p1, _ := rand.Prime(r, 90)
+	p2, _ := rand.Prime(r, 110)
+	n := new(big.Int).Mul(p1, p2)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = EcWithOptions(context.Background(), r, n, 2000, 50000, opts)
+	}
+}
+
+func BenchmarkEcWeierstrass(b *testing.B) {
+	benchmarkEc(b, &EcOptions{Model: Weierstrass})
+}
+
+func BenchmarkEcMontgomery(b *testing.B) {
+	benchmarkEc(b, &EcOptions{Model: Montgomery})
+}
+
+func BenchmarkEcEdwards(b *testing.B) {
+	benchmarkEc(b, &EcOptions{Model: Edwards})
+}
+
+func BenchmarkEcEdwardsZ2xZ8(b *testing.B) {
+	benchmarkEc(b, &EcOptions{Model: Edwards, Torsion: Z2xZ8})
+}
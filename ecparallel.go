@@ -2,7 +2,6 @@ package intfact
 
 import (
 	"context"
-	"errors"
 	"io"
 	"math/big"
 )
@@ -12,22 +11,43 @@ import (
 // The parameter parallel specifies the number of Ec instances to run.
 // See the description of Ec for the other parameters.
 //
-// The function returns a factor if one was found or otherwise an error.
+// The function returns a factor if one was found or otherwise ErrNoFactor or an error matching ErrCancelled.
 func EcParallel(ctx context.Context, random io.Reader, n *big.Int, b, b1 uint32, parallel int) (*big.Int, error) {
+	res, err := EcParallelWithOptions(ctx, random, n, b, b1, parallel, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Factor, nil
+}
+
+// EcParallelWithOptions is EcParallel with the options of EcWithOptions.
+// If the options contain a sigma, the instances use the consecutive values starting there.
+// If they contain a curve, only that curve is run.
+// The result describes the curve on which the factor was found.
+func EcParallelWithOptions(ctx context.Context, random io.Reader, n *big.Int, b, b1 uint32, parallel int, opts *EcOptions) (*EcResult, error) {
+	if opts != nil && opts.Curve != nil {
+		parallel = 1
+	}
 	childctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	type result struct {
-		fac *big.Int
+		res *EcResult
 		err error
 	}
 	resultC := make(chan result)
 	for i := 0; i < parallel; i++ {
+		o := opts
+		if opts != nil && opts.Sigma != nil {
+			oi := *opts
+			oi.Sigma = new(big.Int).Add(opts.Sigma, big.NewInt(int64(i)))
+			o = &oi
+		}
 		go func() {
-			fac, err := Ec(childctx, random, n, b, b1)
+			res, err := EcWithOptions(childctx, random, n, b, b1, o)
 			select {
 			case <-childctx.Done():
 				return
-			case resultC <- result{fac, err}:
+			case resultC <- result{res, err}:
 				return
 			}
 		}()
@@ -37,11 +57,11 @@ func EcParallel(ctx context.Context, random io.Reader, n *big.Int, b, b1 uint32,
 		select {
 		case r = <-resultC:
 			if r.err == nil {
-				return r.fac, nil
+				return r.res, nil
 			}
 		case <-ctx.Done():
-			return nil, errors.New("cancelled")
+			return nil, cancelled(ctx)
 		}
 	}
-	return nil, errors.New("no factor found")
+	return nil, ErrNoFactor
 }
@@ -60,3 +60,19 @@ func TestEcParallel2(t *testing.T) {
 		t.Error("factor does not divide n:", fac)
 	}
 }
+
+func TestEcParallelMontgomery(t *testing.T) {
+	// has a factor 59649589127497217
+	n := intval("340282366920938463463374607431768211457")
+	res, err := EcParallelWithOptions(context.Background(), &lcRandom{x: 10}, n, 10000, 215000, 200, &EcOptions{Model: Montgomery})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	fac := res.Factor
+	if fac == nil {
+		t.Fatal("factor is nil")
+	}
+	if new(big.Int).Mod(n, fac).Sign() != 0 {
+		t.Error("factor does not divide n:", fac)
+	}
+}
@@ -0,0 +1,404 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ghhenry/primes"
+)
+
+// ErrComposite is reported by ECPP if n is composite.
+var ErrComposite = errors.New("n is composite")
+
+// ECPPStep is a step of an ECPP certificate. The point P = (X, Y) on the curve
+// y^2 = x^3 + Ax + B modulo N satisfies M P = 0 and (M/Q) P != 0 for the
+// probable prime Q > (N^(1/4)+1)^2. If Q is prime, then N is prime.
+type ECPPStep struct {
+	N, A, B, M, Q, X, Y *big.Int
+}
+
+// ecppTrialBound is the bound of the trial division of the curve orders.
+const ecppTrialBound = 1 << 20
+
+// ECPP proves the primality of n with the elliptic curve primality proof of
+// Atkin and Morain. The curves have complex multiplication by the fundamental
+// discriminants D with class number up to 8, so that their orders follow from the
+// representation 4n = u^2 + |D| v^2, and the curve is constructed from a root of
+// the Hilbert class polynomial modulo n. An order m = kq with k > 1 composed of
+// primes below 2^20 and a probable prime q > (n^(1/4)+1)^2 reduces the proof for n to
+// a proof for q. The steps continue down to a q that IsPrime proves. If there
+// is no step for some q, the next curve for the previous step is tried.
+//
+// The function returns the certificate chain starting with n, or ErrComposite if n
+// is composite, ErrNoProof if no suitable curve was found for some step, or an error
+// matching ErrCancelled.
+func ECPP(ctx context.Context, n *big.Int) ([]ECPPStep, error) {
+	prime, proven := IsPrime(n)
+	if !prime {
+		return nil, ErrComposite
+	}
+	if proven {
+		return nil, nil
+	}
+	var cert []ECPPStep
+	var err error
+	ecppErr := ecppSteps(ctx, n, func(s *ECPPStep) bool {
+		var rest []ECPPStep
+		rest, err = ECPP(ctx, s.Q)
+		if err == ErrNoProof || err == ErrComposite {
+			// try the next curve
+			return false
+		}
+		if err == nil {
+			cert = append([]ECPPStep{*s}, rest...)
+		}
+		return true
+	})
+	if ecppErr != nil {
+		return nil, ecppErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// ecppSteps calls found with the steps for the probable prime n > 3.3·10^24 until it
+// returns true. It returns ErrNoProof if there are no more steps.
+func ecppSteps(ctx context.Context, n *big.Int, found func(*ECPPStep) bool) error {
+	bound := ecppBound(n)
+	for _, d := range ecppDiscriminants {
+		if ctx.Err() != nil {
+			return cancelled(ctx)
+		}
+		bd := big.NewInt(d)
+		if big.Jacobi(bd, n) != 1 {
+			continue
+		}
+		u, v, err := cornacchia4(n, bd)
+		if err != nil {
+			return err
+		}
+		if u == nil {
+			continue
+		}
+		for _, t := range ecppTraces(d, u, v) {
+			m := new(big.Int).Add(n, bigOne)
+			m.Sub(m, t)
+			k, q := ecppSplitOrder(m)
+			if k.Cmp(bigOne) == 0 || q.Cmp(bound) <= 0 || !BPSW(q) {
+				continue
+			}
+			curves, err := ecppCurves(n, d)
+			if err != nil {
+				return err
+			}
+			for _, c := range curves {
+				s, err := ecppPoint(c, m, k, q)
+				if err != nil {
+					return err
+				}
+				if s != nil {
+					if found(s) {
+						return nil
+					}
+					break
+				}
+			}
+		}
+	}
+	return ErrNoProof
+}
+
+// ecppBound returns a bound that is at least (n^(1/4)+1)^2.
+func ecppBound(n *big.Int) *big.Int {
+	s := new(big.Int).Sqrt(n)
+	s.Sqrt(s)
+	s.Add(s, big.NewInt(2))
+	return s.Mul(s, s)
+}
+
+// cornacchia4 solves 4n = u^2 + |d| v^2 for the prime n with Jacobi(d, n) = 1.
+// It returns nil if there is no solution and ErrComposite if d has no square root
+// modulo n.
+func cornacchia4(n, d *big.Int) (u, v *big.Int, err error) {
+	x := new(big.Int).Mod(d, n)
+	if x.ModSqrt(x, n) == nil || new(big.Int).Exp(x, big.NewInt(2), n).Cmp(new(big.Int).Mod(d, n)) != 0 {
+		return nil, nil, ErrComposite
+	}
+	if x.Bit(0) != d.Bit(0) {
+		x.Sub(n, x)
+	}
+	a := new(big.Int).Lsh(n, 1)
+	b := x
+	l := new(big.Int).Sqrt(new(big.Int).Lsh(n, 2))
+	for b.Cmp(l) > 0 {
+		a, b = b, a.Mod(a, b)
+	}
+	// |d| v^2 = 4n - b^2
+	c := new(big.Int).Lsh(n, 2)
+	c.Sub(c, new(big.Int).Mul(b, b))
+	ad := new(big.Int).Neg(d)
+	r := new(big.Int)
+	if c.DivMod(c, ad, r); r.Sign() != 0 {
+		return nil, nil, nil
+	}
+	v = new(big.Int).Sqrt(c)
+	if new(big.Int).Mul(v, v).Cmp(c) != 0 {
+		return nil, nil, nil
+	}
+	return b, v, nil
+}
+
+// ecppTraces returns the traces of Frobenius of the curves with complex
+// multiplication by d for 4n = u^2 + |d| v^2.
+func ecppTraces(d int64, u, v *big.Int) []*big.Int {
+	ts := []*big.Int{u}
+	switch d {
+	case -4:
+		ts = append(ts, new(big.Int).Lsh(v, 1))
+	case -3:
+		w := new(big.Int).Mul(v, big.NewInt(3))
+		ts = append(ts, new(big.Int).Rsh(new(big.Int).Add(u, w), 1), new(big.Int).Rsh(new(big.Int).Sub(u, w), 1))
+	}
+	for _, t := range ts[:len(ts):len(ts)] {
+		ts = append(ts, new(big.Int).Neg(t))
+	}
+	return ts
+}
+
+// ecppSplitOrder returns the part k of m composed of primes below ecppTrialBound
+// and the cofactor q = m/k.
+func ecppSplitOrder(m *big.Int) (k, q *big.Int) {
+	k = big.NewInt(1)
+	q = new(big.Int).Set(m)
+	primes.Iterate(2, ecppTrialBound, func(p uint32) bool {
+		bp := big.NewInt(int64(p))
+		for bigModSmall(q, p) == 0 {
+			q.Quo(q, bp)
+			k.Mul(k, bp)
+		}
+		return false
+	})
+	return k, q
+}
+
+// ecppCurves returns the curves modulo n with complex multiplication by d.
+// They are the twists of one curve with a j-invariant that is a root of the
+// Hilbert class polynomial.
+func ecppCurves(n *big.Int, d int64) ([]*curve, error) {
+	// g is a quadratic nonresidue and for n = 1 mod 3 also a cubic nonresidue
+	cubic := bigModSmall(n, 3) == 1
+	e3 := new(big.Int).Sub(n, bigOne)
+	e3.Quo(e3, bigThree)
+	g := big.NewInt(2)
+	for ; ; g.Add(g, bigOne) {
+		if big.Jacobi(g, n) != -1 {
+			continue
+		}
+		if cubic && new(big.Int).Exp(g, e3, n).Cmp(bigOne) == 0 {
+			continue
+		}
+		break
+	}
+	var cs []*curve
+	switch d {
+	case -3:
+		// y^2 = x^3 + g^i
+		b := big.NewInt(1)
+		for i := 0; i < 6; i++ {
+			cs = append(cs, &curve{n, new(big.Int), new(big.Int).Set(b)})
+			b.Mul(b, g)
+			b.Mod(b, n)
+		}
+	case -4:
+		// y^2 = x^3 + g^i x
+		a := big.NewInt(1)
+		for i := 0; i < 4; i++ {
+			cs = append(cs, &curve{n, new(big.Int).Set(a), new(big.Int)})
+			a.Mul(a, g)
+			a.Mod(a, n)
+		}
+	default:
+		j, err := hilbertRoot(hilbertPoly(d), n)
+		if err != nil {
+			return nil, err
+		}
+		// y^2 = x^3 + 3k x + 2k with k = j/(1728-j) has the j-invariant j
+		k := new(big.Int).Sub(big.NewInt(1728), j)
+		if k.ModInverse(k.Mod(k, n), n) == nil {
+			return nil, ErrComposite
+		}
+		k.Mul(k, j)
+		k.Mod(k, n)
+		a := new(big.Int).Mul(k, bigThree)
+		a.Mod(a, n)
+		b := new(big.Int).Lsh(k, 1)
+		b.Mod(b, n)
+		// the twist with g
+		g2 := new(big.Int).Mul(g, g)
+		at := new(big.Int).Mul(a, g2)
+		at.Mod(at, n)
+		bt := new(big.Int).Mul(b, g2.Mul(g2, g))
+		bt.Mod(bt, n)
+		cs = append(cs, &curve{n, a, b}, &curve{n, at, bt})
+	}
+	return cs, nil
+}
+
+// hilbertRoot returns a root modulo n of the monic class polynomial h. For prime
+// n with 4n = u^2 + |d| v^2 the polynomial splits into distinct linear factors,
+// which are separated by gcd(f, (x+a)^((n-1)/2) - 1) for a = 0, 1, 2, ...
+func hilbertRoot(h []*big.Int, n *big.Int) (*big.Int, error) {
+	f := make(poly, len(h))
+	for i, c := range h {
+		f[i] = new(big.Int).Mod(c, n)
+	}
+	e := new(big.Int).Sub(n, bigOne)
+	e.Rsh(e, 1)
+	for a := int64(0); f.degree() > 1; a++ {
+		if a == maxWitness {
+			return nil, ErrNoProof
+		}
+		p := polyPowRem(poly{big.NewInt(a), big.NewInt(1)}, e, f, n)
+		if len(p) == 0 {
+			continue
+		}
+		p[0].Sub(p[0], bigOne)
+		p[0].Mod(p[0], n)
+		g, err := polyGCD(f, polyTrimmed(p), n)
+		if err != nil {
+			return nil, err
+		}
+		if g.degree() > 0 && g.degree() < f.degree() {
+			f = g
+		}
+	}
+	if f.degree() < 1 {
+		return nil, ErrComposite
+	}
+	return new(big.Int).Mod(new(big.Int).Neg(f[0]), n), nil
+}
+
+// polyPowRem returns f^e mod m for a monic m.
+func polyPowRem(f poly, e *big.Int, m poly, n *big.Int) poly {
+	f = polyRem(f, m, n)
+	r := poly{big.NewInt(1)}
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r = polyRem(polyMul(r, r, n), m, n)
+		if e.Bit(i) != 0 {
+			r = polyRem(polyMul(r, f, n), m, n)
+		}
+	}
+	return polyTrimmed(r)
+}
+
+// polyTrimmed returns f without the leading zero coefficients.
+func polyTrimmed(f poly) poly {
+	for len(f) > 0 && f[len(f)-1].Sign() == 0 {
+		f = f[:len(f)-1]
+	}
+	return f
+}
+
+// polyGCD returns the monic greatest common divisor of f and g modulo the
+// probable prime n, or ErrComposite if a leading coefficient is not invertible.
+func polyGCD(f, g poly, n *big.Int) (poly, error) {
+	f = polyTrimmed(f)
+	for len(g) > 0 {
+		inv := new(big.Int).ModInverse(g[len(g)-1], n)
+		if inv == nil {
+			return nil, ErrComposite
+		}
+		m := make(poly, len(g))
+		for i, c := range g {
+			m[i] = new(big.Int).Mul(c, inv)
+			m[i].Mod(m[i], n)
+		}
+		f, g = m, polyTrimmed(polyRem(f, m, n))
+	}
+	return f, nil
+}
+
+// ecppPoint looks for a point P on c with (m/q) P != 0 and q (m/q) P = 0.
+// It returns nil if the order of the curve is not m.
+func ecppPoint(c *curve, m, k, q *big.Int) (*ECPPStep, error) {
+	n := c.n
+	rhs := new(big.Int)
+	x := big.NewInt(0)
+	for tries := 0; tries < 20; {
+		x.Add(x, bigOne)
+		// x^3 + ax + b
+		rhs.Mul(x, x)
+		rhs.Add(rhs, c.a)
+		rhs.Mul(rhs, x)
+		rhs.Add(rhs, c.b)
+		rhs.Mod(rhs, n)
+		if big.Jacobi(rhs, n) != 1 {
+			continue
+		}
+		tries++
+		y := new(big.Int).ModSqrt(rhs, n)
+		if y == nil || new(big.Int).Exp(y, big.NewInt(2), n).Cmp(rhs) != 0 {
+			return nil, ErrComposite
+		}
+		p := ordinary{new(big.Int).Set(x), y}
+		pk, err := c.mult(p, k)
+		if err != nil {
+			return nil, ErrComposite
+		}
+		if pk.isZero() {
+			continue
+		}
+		pm, err := c.mult(pk, q)
+		if err != nil {
+			return nil, ErrComposite
+		}
+		if !pm.isZero() {
+			// the order of the curve is not m
+			return nil, nil
+		}
+		return &ECPPStep{N: n, A: c.a, B: c.b, M: m, Q: q, X: p.px, Y: p.py}, nil
+	}
+	return nil, nil
+}
+
+// check verifies the step, assuming that Q is prime.
+func (s *ECPPStep) check() error {
+	n := s.N
+	if n.Cmp(big.NewInt(3)) <= 0 || n.Bit(0) == 0 || bigModSmall(n, 3) == 0 {
+		return errors.New("N must be coprime to 6")
+	}
+	c := &curve{n, new(big.Int).Mod(s.A, n), new(big.Int).Mod(s.B, n)}
+	disc := new(big.Int).Exp(c.a, bigThree, n)
+	disc.Lsh(disc, 2)
+	disc.Add(disc, new(big.Int).Mul(new(big.Int).Mul(c.b, c.b), big.NewInt(27)))
+	if new(big.Int).GCD(nil, nil, disc.Mod(disc, n), n).Cmp(bigOne) != 0 {
+		return errors.New("singular curve")
+	}
+	x := new(big.Int).Mod(s.X, n)
+	y := new(big.Int).Mod(s.Y, n)
+	rhs := new(big.Int).Mul(x, x)
+	rhs.Add(rhs, c.a)
+	rhs.Mul(rhs, x)
+	rhs.Add(rhs, c.b)
+	if rhs.Sub(rhs, new(big.Int).Mul(y, y)).Mod(rhs, n).Sign() != 0 {
+		return errors.New("point not on the curve")
+	}
+	if s.Q.Cmp(ecppBound(n)) <= 0 {
+		return errors.New("Q too small")
+	}
+	k, r := new(big.Int).QuoRem(s.M, s.Q, new(big.Int))
+	if r.Sign() != 0 || k.Sign() <= 0 {
+		return errors.New("Q does not divide M")
+	}
+	pk, err := c.mult(ordinary{x, y}, k)
+	if err != nil || pk.isZero() {
+		return errors.New("(M/Q)P is zero")
+	}
+	if pm, err := c.mult(pk, s.Q); err != nil || !pm.isZero() {
+		return errors.New("MP is not zero")
+	}
+	return nil
+}
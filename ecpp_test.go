@@ -0,0 +1,211 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestHilbertPoly(t *testing.T) {
+	classOne := []int64{-3, -4, -7, -8, -11, -19, -43, -67, -163}
+	for i, d := range classOne {
+		if ecppDiscriminants[i] != d {
+			t.Errorf("discriminant %v is %v, want %v", i, ecppDiscriminants[i], d)
+		}
+	}
+	for _, d := range ecppDiscriminants {
+		h := len(reducedForms(d))
+		if p := hilbertPoly(d); len(p) != h+1 || p[h].Cmp(bigOne) != 0 {
+			t.Errorf("%v: got %v", d, p)
+		}
+	}
+	tests := []struct {
+		d    int64
+		want []int64
+	}{
+		{-3, []int64{0, 1}},
+		{-4, []int64{-1728, 1}},
+		{-7, []int64{3375, 1}},
+		{-163, []int64{262537412640768000, 1}},
+		{-15, []int64{-121287375, 191025, 1}},
+		{-20, []int64{-681472000, -1264000, 1}},
+		{-23, []int64{12771880859375, -5151296875, 3491750, 1}},
+	}
+	for _, tt := range tests {
+		p := hilbertPoly(tt.d)
+		for i, c := range tt.want {
+			if p[i].Cmp(big.NewInt(c)) != 0 {
+				t.Errorf("%v: got %v, want %v", tt.d, p, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCornacchia4(t *testing.T) {
+	n := intval("618970019642690137449562111")
+	for _, d := range ecppDiscriminants {
+		bd := big.NewInt(d)
+		if big.Jacobi(bd, n) != 1 {
+			continue
+		}
+		u, v, err := cornacchia4(n, bd)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if u == nil {
+			continue
+		}
+		// 4n = u^2 + |d| v^2
+		s := new(big.Int).Mul(v, v)
+		s.Mul(s, big.NewInt(-d))
+		s.Add(s, new(big.Int).Mul(u, u))
+		if s.Cmp(new(big.Int).Lsh(n, 2)) != 0 {
+			t.Errorf("%v: wrong solution %v, %v", d, u, v)
+		}
+	}
+}
+
+func TestECPPCurves(t *testing.T) {
+	// the curves from the class polynomials have one of the orders n+1-t
+	n := intval("170141183460469231731687303715884105727")
+	count := 0
+	for _, d := range ecppDiscriminants {
+		bd := big.NewInt(d)
+		if big.Jacobi(bd, n) != 1 {
+			continue
+		}
+		u, v, err := cornacchia4(n, bd)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if u == nil {
+			continue
+		}
+		count++
+		curves, err := ecppCurves(n, d)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", d, err)
+		}
+	curves:
+		for _, c := range curves {
+			// a point with x = 1, 2, ...
+			x, rhs := new(big.Int), new(big.Int)
+			for big.Jacobi(rhs, n) != 1 {
+				x.Add(x, bigOne)
+				rhs.Mul(x, x)
+				rhs.Add(rhs, c.a)
+				rhs.Mul(rhs, x)
+				rhs.Add(rhs, c.b)
+				rhs.Mod(rhs, n)
+			}
+			p := ordinary{x, new(big.Int).ModSqrt(rhs, n)}
+			for _, tr := range ecppTraces(d, u, v) {
+				m := new(big.Int).Add(n, bigOne)
+				if pm, err := c.mult(p, m.Sub(m, tr)); err == nil && pm.isZero() {
+					continue curves
+				}
+			}
+			t.Errorf("%v: curve %v has none of the orders", d, c)
+		}
+	}
+	if count < 10 {
+		t.Errorf("only %v discriminants", count)
+	}
+}
+
+func TestECPP(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     *big.Int
+		short bool
+	}{
+		{"m89", intval("618970019642690137449562111"), true},
+		{"m127", intval("170141183460469231731687303715884105727"), true},
+		{"50 digits", intval("10000000000000000000000000000000000000000000000009"), true},
+		{"100 digits", new(big.Int).Add(new(big.Int).Exp(big.NewInt(10), big.NewInt(99), nil), big.NewInt(289)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.short && testing.Short() {
+				t.Skip("skipped in short mode")
+			}
+			cert, err := ECPP(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if len(cert) == 0 || cert[0].N.Cmp(tt.n) != 0 {
+				t.Fatal("certificate does not start with n")
+			}
+			for i := range cert {
+				if err := cert[i].check(); err != nil {
+					t.Errorf("step %v: %v", i, err)
+				}
+				if i > 0 && cert[i].N.Cmp(cert[i-1].Q) != 0 {
+					t.Errorf("step %v: N is not the previous Q", i)
+				}
+			}
+			if prime, proven := IsPrime(cert[len(cert)-1].Q); !prime || !proven {
+				t.Error("last Q is not proven prime")
+			}
+		})
+	}
+	// 2^128+1
+	if _, err := ECPP(context.Background(), intval("340282366920938463463374607431768211457")); err != ErrComposite {
+		t.Errorf("got %v, want ErrComposite", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ECPP(ctx, intval("618970019642690137449562111")); !errors.Is(err, ErrCancelled) {
+		t.Errorf("got %v, want ErrCancelled", err)
+	}
+}
+
+// countdownContext is cancelled after n calls of Err.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestECPPCancelledRecursion(t *testing.T) {
+	m127 := intval("170141183460469231731687303715884105727")
+	for n := 1; n < 100; n++ {
+		cert, err := ECPP(&countdownContext{context.Background(), n}, m127)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrCancelled) {
+			t.Fatalf("%v: got %v, want ErrCancelled", n, err)
+		}
+		if cert != nil {
+			t.Fatalf("%v: got a partial certificate with %v steps", n, len(cert))
+		}
+	}
+}
+
+func TestECPPStepCheck(t *testing.T) {
+	cert, err := ECPP(context.Background(), intval("170141183460469231731687303715884105727"))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	s := cert[0]
+	bad := []ECPPStep{s, s, s, s}
+	bad[0].Y = new(big.Int).Add(s.Y, bigOne)
+	bad[1].Q = big.NewInt(1000003)
+	bad[2].M = new(big.Int).Add(s.M, bigOne)
+	bad[3].M = new(big.Int).Mul(s.Q, big.NewInt(1000003))
+	for i := range bad {
+		if bad[i].check() == nil {
+			t.Errorf("invalid step %v accepted", i)
+		}
+	}
+}
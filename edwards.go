@@ -0,0 +1,345 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"github.com/ghhenry/primes"
+	"math/big"
+	"math/bits"
+)
+
+// Torsion selects the family of the Edwards curves used by EcWithOptions.
+type Torsion int
+
+// Torsion values
+const (
+	// Z12 curves have a rational torsion group Z/12, so the group order modulo a prime is divisible by 12.
+	Z12 Torsion = iota
+	// Z2xZ8 curves have a rational torsion group Z/2×Z/8, so the group order modulo a prime is divisible by 16.
+	Z2xZ8
+)
+
+// ecurve is a twisted Edwards curve ax^2 + y^2 = 1 + dx^2y^2 modulo n.
+// Points use extended coordinates (X:Y:Z:T) with x = X/Z, y = Y/Z and T = XY/Z,
+// so that addition and doubling need no inversions.
+// The temporaries t make the arithmetic free of allocations, so a curve
+// must not be used concurrently.
+type ecurve struct {
+	n    *big.Int
+	a, d *big.Int
+	t    [6]big.Int
+}
+
+// edPoint is a point in extended coordinates, the neutral element is (0:1:1:0).
+type edPoint struct {
+	x, y, z, t *big.Int
+}
+
+func newED() edPoint {
+	return edPoint{new(big.Int), new(big.Int), new(big.Int), new(big.Int)}
+}
+
+func (p edPoint) set(q edPoint) {
+	p.x.Set(q.x)
+	p.y.Set(q.y)
+	p.z.Set(q.z)
+	p.t.Set(q.t)
+}
+
+// edwardsCurve returns the curve and start point with the given torsion for the parameter seed > 0.
+// The curves have a = 1 and a start point of infinite order over the rationals. They are
+// constructed from seed*G, where G is a generator of an auxiliary elliptic curve of rank 1:
+//
+// Z12: y^2 = x^3 - 12x with G = (-2, 4). For a point (X, Y) let u = (X-Y)/(2(X+3)) and
+// w = u^2 - u + 1 - X/2, then d = (u^2+1)^3 (u^2-4u+1) / ((u-1)^6 (u+1)^2) and the start point is
+// ((u^2-1)w / ((u^2+1)(u^2-u+1)), (u-1)^2 (u^2-4u+1) / ((u+1)^2 (u^2+1))).
+//
+// Z2xZ8: y^2 = x^3 + 2x^2 - 8x with G = (-2, 4), which is computed in the short form
+// Y^2 = T^3 - 756T + 4320 with x = (T-6)/9 and y = Y/27. For a point (x, y) let
+// u = -(x+y+4)/(2(x+1)), w = u^2 + u - x/2 and s = (u^2+2u+2)/(u^2-2),
+// then d = (2s^2-1)/s^4 and the start point is (s^2, w/(u^2-2)).
+//
+// If an inverse does not exist modulo n, a FactorFoundError is returned.
+func edwardsCurve(torsion Torsion, seed uint64, n *big.Int) (*ecurve, edPoint, error) {
+	mod := func(x int64) *big.Int {
+		return new(big.Int).Mod(big.NewInt(x), n)
+	}
+	mul := func(x, y *big.Int) *big.Int {
+		r := new(big.Int).Mul(x, y)
+		return r.Mod(r, n)
+	}
+	div := func(x, y *big.Int) (*big.Int, error) {
+		i, err := inverse(y, n)
+		if err != nil {
+			return nil, err
+		}
+		return mul(x, i), nil
+	}
+	var aux *curve
+	var g point
+	switch torsion {
+	case Z12:
+		aux = &curve{n, mod(-12), new(big.Int)}
+		g = ordinary{mod(-2), mod(4)}
+	case Z2xZ8:
+		aux = &curve{n, mod(-756), mod(4320)}
+		g = ordinary{mod(-12), mod(108)}
+	default:
+		panic(errors.New("unknown torsion"))
+	}
+	q, err := aux.mult(g, new(big.Int).SetUint64(seed))
+	if err != nil {
+		return nil, edPoint{}, err
+	}
+	if q.isZero() {
+		return nil, edPoint{}, FactorFoundError{new(big.Int).Set(n)}
+	}
+	var d, x0, y0 *big.Int
+	if torsion == Z12 {
+		x, y := q.x(), q.y()
+		// u = (x-y)/(2(x+3))
+		t0 := new(big.Int).Add(x, bigThree)
+		u, err := div(new(big.Int).Sub(x, y), t0.Lsh(t0, 1))
+		if err != nil {
+			return nil, edPoint{}, err
+		}
+		u2 := mul(u, u)
+		// w = u^2 - u + 1 - x/2, computed as 2w
+		w := new(big.Int).Sub(u2, u)
+		w.Add(w, bigOne)
+		w.Lsh(w, 1)
+		w.Sub(w, x)
+		// s = u^2+1, v = u^2-4u+1, r = u^2-u+1, um = u-1, up = u+1
+		s := new(big.Int).Add(u2, bigOne)
+		v := new(big.Int).Lsh(u, 2)
+		v.Sub(s, v)
+		r := new(big.Int).Sub(s, u)
+		um := new(big.Int).Sub(u, bigOne)
+		up := new(big.Int).Add(u, bigOne)
+		um2 := mul(um, um)
+		t0 = mul(um2, um2)
+		t0 = mul(t0, um2)
+		t0 = mul(t0, mul(up, up))
+		d, err = div(mul(mul(mul(s, s), s), v), t0)
+		if err != nil {
+			return nil, edPoint{}, err
+		}
+		x0, err = div(mul(new(big.Int).Sub(u2, bigOne), w), mul(mul(s, r), bigTwo))
+		if err != nil {
+			return nil, edPoint{}, err
+		}
+		y0, err = div(mul(um2, v), mul(mul(up, up), s))
+		if err != nil {
+			return nil, edPoint{}, err
+		}
+	} else {
+		t, y := q.x(), q.y()
+		// u = -(3t+y+90)/(6(t+3))
+		t0 := new(big.Int).Mul(t, bigThree)
+		t0.Add(t0, y)
+		t0.Add(t0, big.NewInt(90))
+		t0.Neg(t0)
+		t1 := new(big.Int).Add(t, bigThree)
+		u, err := div(t0, t1.Mul(t1, big.NewInt(6)))
+		if err != nil {
+			return nil, edPoint{}, err
+		}
+		u2 := mul(u, u)
+		// w = u^2 + u - (t-6)/18, computed as 18w
+		w := new(big.Int).Add(u2, u)
+		w.Mul(w, big.NewInt(18))
+		w.Sub(w, t)
+		w.Add(w, big.NewInt(6))
+		// e = u^2-2, s = (u^2+2u+2)/e
+		e := new(big.Int).Sub(u2, bigTwo)
+		t0.Lsh(u, 1)
+		t0.Add(t0, u2)
+		t0.Add(t0, bigTwo)
+		s, err := div(t0, e)
+		if err != nil {
+			return nil, edPoint{}, err
+		}
+		x0 = mul(s, s)
+		t0.Lsh(x0, 1)
+		t0.Sub(t0, bigOne)
+		d, err = div(t0, mul(x0, x0))
+		if err != nil {
+			return nil, edPoint{}, err
+		}
+		y0, err = div(w, mul(e, big.NewInt(18)))
+		if err != nil {
+			return nil, edPoint{}, err
+		}
+	}
+	c := &ecurve{n: n, a: big.NewInt(1), d: d}
+	return c, edPoint{x0, y0, big.NewInt(1), mul(x0, y0)}, nil
+}
+
+// double computes 2p.
+func (c *ecurve) double(p edPoint) edPoint {
+	r := newED()
+	c.doubleTo(r, p)
+	return r
+}
+
+// doubleTo sets r = 2p, r may be p.
+func (c *ecurve) doubleTo(r, p edPoint) {
+	a, b, cc, e := &c.t[0], &c.t[1], &c.t[2], &c.t[3]
+	a.Mul(p.x, p.x)
+	a.Mod(a, c.n)
+	b.Mul(p.y, p.y)
+	b.Mod(b, c.n)
+	cc.Mul(p.z, p.z)
+	cc.Lsh(cc, 1)
+	cc.Mod(cc, c.n)
+	e.Add(p.x, p.y)
+	e.Mul(e, e)
+	e.Sub(e, a)
+	e.Sub(e, b)
+	e.Mod(e, c.n)
+	// g = aA + B, f = g - C, h = aA - B
+	g, f, h := &c.t[4], &c.t[5], a
+	h.Mul(c.a, a)
+	g.Add(h, b)
+	f.Sub(g, cc)
+	h.Sub(h, b)
+	r.x.Mul(e, f)
+	r.x.Mod(r.x, c.n)
+	r.y.Mul(g, h)
+	r.y.Mod(r.y, c.n)
+	r.t.Mul(e, h)
+	r.t.Mod(r.t, c.n)
+	r.z.Mul(f, g)
+	r.z.Mod(r.z, c.n)
+}
+
+// add computes p+q.
+func (c *ecurve) add(p, q edPoint) edPoint {
+	r := newED()
+	c.addTo(r, p, q)
+	return r
+}
+
+// addTo sets r = p+q with the unified addition formula. r may be any of the other points.
+func (c *ecurve) addTo(r, p, q edPoint) {
+	a, b, cc, d, e, f := &c.t[0], &c.t[1], &c.t[2], &c.t[3], &c.t[4], &c.t[5]
+	a.Mul(p.x, q.x)
+	a.Mod(a, c.n)
+	b.Mul(p.y, q.y)
+	b.Mod(b, c.n)
+	cc.Mul(p.t, q.t)
+	cc.Mod(cc, c.n)
+	cc.Mul(cc, c.d)
+	cc.Mod(cc, c.n)
+	d.Mul(p.z, q.z)
+	d.Mod(d, c.n)
+	e.Add(p.x, p.y)
+	f.Add(q.x, q.y)
+	e.Mul(e, f)
+	e.Sub(e, a)
+	e.Sub(e, b)
+	e.Mod(e, c.n)
+	// f = D - C, g = D + C, h = B - aA
+	g, h := d, b
+	f.Sub(d, cc)
+	g.Add(d, cc)
+	a.Mul(c.a, a)
+	h.Sub(b, a)
+	r.x.Mul(e, f)
+	r.x.Mod(r.x, c.n)
+	r.y.Mul(g, h)
+	r.y.Mod(r.y, c.n)
+	r.t.Mul(e, h)
+	r.t.Mod(r.t, c.n)
+	r.z.Mul(f, g)
+	r.z.Mod(r.z, c.n)
+}
+
+// mult computes m*p with double and add.
+func (c *ecurve) mult(p edPoint, m uint64) edPoint {
+	r := edPoint{big.NewInt(0), big.NewInt(1), big.NewInt(1), big.NewInt(0)}
+	if m == 0 {
+		return r
+	}
+	r.set(p)
+	for i := bits.Len64(m) - 2; i >= 0; i-- {
+		c.doubleTo(r, r)
+		if m>>uint(i)&1 == 1 {
+			c.addTo(r, r, p)
+		}
+	}
+	return r
+}
+
+// montgomery returns the birationally equivalent Montgomery curve and the point corresponding to p.
+// The map is u = (1+y)/(1-y) and A = 2(a+d)/(a-d), B = 4/(a-d), so a24 = a/(a-d).
+func (c *ecurve) montgomery(p edPoint) (*mcurve, xzPoint, error) {
+	a24, err := inverse(new(big.Int).Sub(c.a, c.d), c.n)
+	if err != nil {
+		return nil, xzPoint{}, err
+	}
+	a24.Mul(a24, c.a)
+	a24.Mod(a24, c.n)
+	x := new(big.Int).Add(p.z, p.y)
+	x.Mod(x, c.n)
+	z := new(big.Int).Sub(p.z, p.y)
+	z.Mod(z, c.n)
+	return &mcurve{n: c.n, a24: a24}, xzPoint{x, z}, nil
+}
+
+// ecEdwards runs the elliptic curve method on the Edwards curve with the given torsion for sigma.
+// See EcWithOptions for the parameters and the return values.
+func ecEdwards(ctx context.Context, torsion Torsion, sigma, n *big.Int, b, b1, d uint32) (res *EcResult, err error) {
+	c, pt, err := edwardsCurve(torsion, sigma.Uint64(), n)
+	if err != nil {
+		return stageResult(err, n, sigma, 1, 0)
+	}
+	gcd := newGcdtest(n, 20)
+	var last uint32
+	phase1 := func(p uint32) bool {
+		select {
+		case <-ctx.Done():
+			err = cancelled(ctx)
+			return true
+		default:
+		}
+		mult := uint64(p)
+		for {
+			ne := mult * uint64(p)
+			if ne > uint64(b) {
+				break
+			}
+			mult = ne
+		}
+		pt = c.mult(pt, mult)
+		last = p
+		var fac *big.Int
+		fac, err = gcd.test(pt.x)
+		if fac != nil {
+			res = &EcResult{Factor: fac, Sigma: sigma, Stage: 1, Prime: p}
+		}
+		return res != nil || err != nil
+	}
+	primes.Iterate(2, b, phase1)
+	if res != nil || err != nil {
+		return
+	}
+
+	// phase2 continues on the curve in Weierstrass form
+	fac, err := gcd.finish()
+	if err != nil {
+		return nil, err
+	}
+	if fac != nil {
+		return &EcResult{Factor: fac, Sigma: sigma, Stage: 1, Prime: last}, nil
+	}
+	mc, mpt, err := c.montgomery(pt)
+	if err != nil {
+		return stageResult(err, n, sigma, 1, last)
+	}
+	wc, wpt, err := mc.weierstrass(mpt)
+	if err != nil {
+		return stageResult(err, n, sigma, 1, last)
+	}
+	return ecPhase2(ctx, wc, wpt, sigma, b, b1, d)
+}
@@ -0,0 +1,164 @@
+package intfact
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func onEdwards(c *ecurve, p edPoint) bool {
+	// aX^2Z^2 + Y^2Z^2 = Z^4 + dX^2Y^2 and XY = TZ
+	n := c.n
+	x2 := new(big.Int).Mul(p.x, p.x)
+	y2 := new(big.Int).Mul(p.y, p.y)
+	z2 := new(big.Int).Mul(p.z, p.z)
+	left := new(big.Int).Mul(c.a, x2)
+	left.Add(left, y2)
+	left.Mul(left, z2)
+	right := new(big.Int).Mul(z2, z2)
+	t := new(big.Int).Mul(x2, y2)
+	right.Add(right, t.Mul(t, c.d))
+	if left.Sub(left, right).Mod(left, n).Sign() != 0 {
+		return false
+	}
+	left.Mul(p.x, p.y)
+	right.Mul(p.t, p.z)
+	return left.Sub(left, right).Mod(left, n).Sign() == 0
+}
+
+func TestEdwardsCurve(t *testing.T) {
+	// the group order modulo a prime is divisible by 12 resp. 16
+	p := big.NewInt(1009)
+	for _, tt := range []struct {
+		name    string
+		torsion Torsion
+		divisor int64
+	}{
+		{"Z12", Z12, 12},
+		{"Z2xZ8", Z2xZ8, 16},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tested := 0
+			for seed := uint64(1); seed <= 10; seed++ {
+				c, pt, err := edwardsCurve(tt.torsion, seed, p)
+				if err != nil {
+					// the parametrization degenerates modulo p
+					continue
+				}
+				if !onEdwards(c, pt) {
+					t.Fatalf("seed %v: start point is not on the curve", seed)
+				}
+				mc, mpt, err := c.montgomery(pt)
+				if err != nil {
+					continue
+				}
+				wc, _, err := mc.weierstrass(mpt)
+				if err != nil {
+					continue
+				}
+				tested++
+				order := p.Int64() + 1
+				x := new(big.Int)
+				for i := int64(0); i < p.Int64(); i++ {
+					x.SetInt64(i)
+					r := new(big.Int).Mul(x, x)
+					r.Add(r, wc.a)
+					r.Mul(r, x)
+					r.Add(r, wc.b)
+					r.Mod(r, p)
+					order += int64(big.Jacobi(r, p))
+				}
+				if order%tt.divisor != 0 {
+					t.Errorf("seed %v: group order %v is not divisible by %v", seed, order, tt.divisor)
+				}
+			}
+			if tested < 5 {
+				t.Errorf("only %v curves tested", tested)
+			}
+		})
+	}
+	// 2 is not invertible
+	_, _, err := edwardsCurve(Z12, 1, big.NewInt(2*1009))
+	if e, ok := err.(FactorFoundError); !ok || e.Factor.Int64() != 2 {
+		t.Errorf("got %v, want a FactorFoundError", err)
+	}
+}
+
+func TestEcurveArithmetic(t *testing.T) {
+	n := big.NewInt(1000003)
+	c, p, err := edwardsCurve(Z2xZ8, 3, n)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	mc, mp, err := c.montgomery(p)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	for m := uint64(2); m < 50; m++ {
+		cur := c.mult(p, m)
+		if !onEdwards(c, cur) {
+			t.Fatalf("multiple %v is not on the curve", m)
+		}
+		next := c.add(cur, p)
+		if !edEqual(c, next, c.mult(p, m+1)) {
+			t.Errorf("add and mult differ for %v", m+1)
+		}
+		if !edEqual(c, c.double(cur), c.mult(p, 2*m)) {
+			t.Errorf("double and mult differ for %v", 2*m)
+		}
+		// the map to the Montgomery curve is a homomorphism
+		_, mcur, err := c.montgomery(cur)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if !xzEqual(mc, mcur, mc.mult(mp, m)) {
+			t.Errorf("Edwards and Montgomery multiples differ for %v", m)
+		}
+	}
+}
+
+func edEqual(c *ecurve, p, q edPoint) bool {
+	l := new(big.Int).Mul(p.x, q.z)
+	r := new(big.Int).Mul(q.x, p.z)
+	if l.Sub(l, r).Mod(l, c.n).Sign() != 0 {
+		return false
+	}
+	l.Mul(p.y, q.z)
+	r.Mul(q.y, p.z)
+	return l.Sub(l, r).Mod(l, c.n).Sign() == 0
+}
+
+func TestEcEdwards(t *testing.T) {
+	n := big.NewInt(43217358712783469)
+	for _, torsion := range []Torsion{Z12, Z2xZ8} {
+		res, err := EcParallelWithOptions(context.Background(), &lcRandom{x: 10}, n, 2000, 50000, 4,
+			&EcOptions{Model: Edwards, Torsion: torsion, Sigma: big.NewInt(1)})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if new(big.Int).Mod(n, res.Factor).Sign() != 0 {
+			t.Error("factor does not divide n:", res.Factor)
+		}
+	}
+}
+
+func BenchmarkCurveMult(b *testing.B) {
+	// a random curve modulo a 200 bit number
+	r := &lcRandom{x: 10}
+	n, _ := new(big.Int).SetString("1000000000000000000000000000000000000000000000000000000000007", 10)
+	c, p := randCurve(r, n)
+	m := new(big.Int).SetUint64(0xfedcba9876543210)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = c.mult(p, m)
+	}
+}
+
+func BenchmarkEcurveMult(b *testing.B) {
+	n, _ := new(big.Int).SetString("1000000000000000000000000000000000000000000000000000000000007", 10)
+	c, p, _ := edwardsCurve(Z12, 1, n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = c.mult(p, 0xfedcba9876543210)
+	}
+}
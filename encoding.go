@@ -0,0 +1,272 @@
+package intfact
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var statusNames = [...]string{
+	Unknown:   "Unknown",
+	ProbPrime: "ProbPrime",
+	Composite: "Composite",
+	Prime:     "Prime",
+}
+
+func (s Status) String() string {
+	if s >= 0 && int(s) < len(statusNames) {
+		return statusNames[s]
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// MarshalText encodes the status as its name, which does not change with the
+// numeric values.
+func (s Status) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(statusNames) {
+		return nil, fmt.Errorf("invalid status %d", int(s))
+	}
+	return []byte(statusNames[s]), nil
+}
+
+// UnmarshalText decodes a status name.
+func (s *Status) UnmarshalText(text []byte) error {
+	for i, name := range statusNames {
+		if name == string(text) {
+			*s = Status(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid status %q", text)
+}
+
+// factJSON is the JSON form of a Fact without the link to the next factor.
+type factJSON struct {
+	Fac  *big.Int    `json:"fac"`
+	Exp  uint        `json:"exp"`
+	Stat Status      `json:"status"`
+	Cert Certificate `json:"cert,omitempty"`
+}
+
+// factorsJSON is the JSON form of Factors.
+type factorsJSON struct {
+	PBound  *big.Int `json:"pbound"`
+	Factors []*Fact  `json:"factors"`
+}
+
+// MarshalJSON encodes the factor without the link to the next one as
+// {"fac":12,"exp":1,"status":"Composite"}. A certificate is added as a string in
+// the format of Certificate.MarshalText.
+func (f *Fact) MarshalJSON() ([]byte, error) {
+	return json.Marshal(factJSON{f.Fac, f.Exp, f.Stat, f.Cert})
+}
+
+// UnmarshalJSON decodes a factor encoded by MarshalJSON. Next is set to nil.
+func (f *Fact) UnmarshalJSON(data []byte) error {
+	var j factJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*f = Fact{Fac: j.Fac, Exp: j.Exp, Stat: j.Stat, Cert: j.Cert}
+	return f.valid()
+}
+
+// valid checks the fields of a decoded factor.
+func (f *Fact) valid() error {
+	if f.Fac == nil || f.Fac.Sign() <= 0 || f.Exp == 0 {
+		return fmt.Errorf("%w: factor %v with exponent %v", ErrInvalidFactors, f.Fac, f.Exp)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the list as {"pbound":10000,"factors":[...]} with the
+// factors in the format of Fact.MarshalJSON. Debug is not encoded.
+func (l *Factors) MarshalJSON() ([]byte, error) {
+	j := factorsJSON{PBound: l.PBound, Factors: []*Fact{}}
+	for f := l.First; f != nil; f = f.Next {
+		j.Factors = append(j.Factors, f)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a list encoded by MarshalJSON. The factors must be
+// increasing and pairwise coprime.
+func (l *Factors) UnmarshalJSON(data []byte) error {
+	var j factorsJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return l.set(j.PBound, j.Factors)
+}
+
+// set replaces the list by the factors, after checking them like Verify does
+// except for the product and the status.
+func (l *Factors) set(pbound *big.Int, fs []*Fact) error {
+	if pbound == nil || pbound.Sign() <= 0 {
+		return fmt.Errorf("%w: PBound %v", ErrInvalidFactors, pbound)
+	}
+	r := Factors{PBound: pbound, Debug: l.Debug}
+	for i := len(fs) - 1; i >= 0; i-- {
+		if fs[i] == nil {
+			return fmt.Errorf("%w: missing factor", ErrInvalidFactors)
+		}
+		fs[i].Next = r.First
+		r.First = fs[i]
+	}
+	if err := r.checkList(); err != nil {
+		return err
+	}
+	*l = r
+	return nil
+}
+
+// factorsVersion is the version of the binary format.
+const factorsVersion = 1
+
+// errShortData is reported by UnmarshalBinary for truncated data.
+var errShortData = errors.New("binary data too short")
+
+// MarshalBinary encodes the factor without the link to the next one as the
+// uvarint length and the big-endian bytes of Fac, the uvarint Exp, the uvarint
+// length and name of the Status as in Status.MarshalText, and the uvarint length
+// and text of the certificate.
+func (f *Fact) MarshalBinary() ([]byte, error) {
+	return appendFact(nil, f)
+}
+
+func appendFact(b []byte, f *Fact) ([]byte, error) {
+	if f.Fac == nil {
+		return nil, fmt.Errorf("%w: missing factor", ErrInvalidFactors)
+	}
+	stat, err := f.Stat.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	b = appendBytes(b, f.Fac.Bytes())
+	b = appendUvarint(b, uint64(f.Exp))
+	b = appendBytes(b, stat)
+	var cert []byte
+	if f.Cert != nil {
+		if cert, err = f.Cert.MarshalText(); err != nil {
+			return nil, err
+		}
+	}
+	return appendBytes(b, cert), nil
+}
+
+func appendUvarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = appendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// UnmarshalBinary decodes a factor encoded by MarshalBinary. Next is set to nil.
+func (f *Fact) UnmarshalBinary(data []byte) error {
+	g, rest, err := readFact(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("extra binary data")
+	}
+	*f = *g
+	return nil
+}
+
+func readFact(b []byte) (*Fact, []byte, error) {
+	fac, b, err := readBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	exp, n := binary.Uvarint(b)
+	if n <= 0 || uint64(uint(exp)) != exp {
+		return nil, nil, errShortData
+	}
+	stat, b, err := readBytes(b[n:])
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &Fact{Fac: new(big.Int).SetBytes(fac), Exp: uint(exp)}
+	if err := f.Stat.UnmarshalText(stat); err != nil {
+		return nil, nil, err
+	}
+	cert, b, err := readBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cert) > 0 {
+		if err := f.Cert.UnmarshalText(cert); err != nil {
+			return nil, nil, err
+		}
+	}
+	return f, b, f.valid()
+}
+
+func readBytes(b []byte) (data, rest []byte, err error) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < l {
+		return nil, nil, errShortData
+	}
+	return b[n : n+int(l)], b[n+int(l):], nil
+}
+
+// MarshalBinary encodes the list as a version byte, the uvarint length and the
+// big-endian bytes of PBound, the uvarint number of factors and the factors in
+// the format of Fact.MarshalBinary. Debug is not encoded.
+func (l *Factors) MarshalBinary() ([]byte, error) {
+	if l.PBound == nil {
+		return nil, fmt.Errorf("%w: missing PBound", ErrInvalidFactors)
+	}
+	b := []byte{factorsVersion}
+	b = appendBytes(b, l.PBound.Bytes())
+	count := 0
+	for f := l.First; f != nil; f = f.Next {
+		count++
+	}
+	b = appendUvarint(b, uint64(count))
+	for f := l.First; f != nil; f = f.Next {
+		var err error
+		if b, err = appendFact(b, f); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a list encoded by MarshalBinary. The factors must be
+// increasing and pairwise coprime.
+func (l *Factors) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errShortData
+	}
+	if data[0] != factorsVersion {
+		return fmt.Errorf("unknown binary format version %d", data[0])
+	}
+	pb, b, err := readBytes(data[1:])
+	if err != nil {
+		return err
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return errShortData
+	}
+	b = b[n:]
+	var fs []*Fact
+	for i := uint64(0); i < count; i++ {
+		var f *Fact
+		if f, b, err = readFact(b); err != nil {
+			return err
+		}
+		fs = append(fs, f)
+	}
+	if len(b) != 0 {
+		return errors.New("extra binary data")
+	}
+	return l.set(new(big.Int).SetBytes(pb), fs)
+}
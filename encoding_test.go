@@ -0,0 +1,176 @@
+package intfact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// sameFactors reports whether the lists have the same factors, stati,
+// certificates and PBound.
+func sameFactors(a, b *Factors) bool {
+	if a.PBound.Cmp(b.PBound) != 0 {
+		return false
+	}
+	f, g := a.First, b.First
+	for ; f != nil && g != nil; f, g = f.Next, g.Next {
+		if f.Fac.Cmp(g.Fac) != 0 || f.Exp != g.Exp || f.Stat != g.Stat || f.Cert.String() != g.Cert.String() {
+			return false
+		}
+	}
+	return f == nil && g == nil
+}
+
+// encodingFactors returns a list with all stati and a certificate.
+func encodingFactors(t *testing.T) *Factors {
+	// 2^3 * 3 * 5 * 143 * 1000003*1000033 * m89 * m127^2 * 10^39+3
+	m89 := intval("618970019642690137449562111")
+	m127 := intval("170141183460469231731687303715884105727")
+	l := NewFactors(big.NewInt(120))
+	l.TrialDivision(100)
+	l.Insert(&Fact{Fac: m89, Exp: 1, Stat: ProbPrime})
+	l.Insert(&Fact{Fac: m127, Exp: 2, Stat: ProbPrime})
+	l.Insert(&Fact{Fac: big.NewInt(1000003 * 1000033), Exp: 1, Stat: Composite})
+	l.Insert(&Fact{Fac: big.NewInt(11 * 13), Exp: 1, Stat: Unknown})
+	if err := l.Prove(context.Background(), nil); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	l.Insert(&Fact{Fac: intval("1000000000000000000000000000000000000003"), Exp: 1, Stat: ProbPrime})
+	return l
+}
+
+func TestFactorsJSON(t *testing.T) {
+	l := encodingFactors(t)
+	data, err := json.Marshal(l)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	for _, s := range []string{`"pbound":100`, `"status":"Prime"`, `"status":"ProbPrime"`, `"status":"Composite"`, `"status":"Unknown"`, `"cert":"[intfact certificate]`} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("%s does not contain %s", data, s)
+		}
+	}
+	var m Factors
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !sameFactors(l, &m) {
+		t.Errorf("got %s", mustJSON(&m))
+	}
+	for f := m.First; f != nil; f = f.Next {
+		if f.Cert != nil {
+			if err := VerifyCertificate(f.Fac, f.Cert); err != nil {
+				t.Error("unexpected error", err)
+			}
+		}
+	}
+	// a single factor
+	var f Fact
+	if err := json.Unmarshal([]byte(`{"fac":1000003,"exp":2,"status":"Prime"}`), &f); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if f.Fac.Int64() != 1000003 || f.Exp != 2 || f.Stat != Prime {
+		t.Errorf("got %+v", f)
+	}
+	invalid := []string{
+		`{"pbound":1,"factors":[{"fac":5,"exp":1,"status":"Prime"},{"fac":3,"exp":1,"status":"Prime"}]}`,
+		`{"pbound":1,"factors":[{"fac":6,"exp":1,"status":"Unknown"},{"fac":9,"exp":1,"status":"Unknown"}]}`,
+		`{"pbound":1,"factors":[{"fac":5,"exp":0,"status":"Prime"}]}`,
+		`{"pbound":1,"factors":[{"fac":5,"exp":1,"status":"prime"}]}`,
+		`{"pbound":1,"factors":[{"fac":5,"exp":1,"status":3}]}`,
+		`{"pbound":1,"factors":[{"exp":1,"status":"Prime"}]}`,
+		`{"pbound":1,"factors":[null]}`,
+		`{"factors":[]}`,
+		`{"pbound":1,"factors":[{"fac":5,"exp":1,"status":"Prime","cert":"[1]\nN=5"}]}`,
+	}
+	for _, s := range invalid {
+		var m Factors
+		if err := json.Unmarshal([]byte(s), &m); err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+}
+
+func mustJSON(l *Factors) string {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func TestFactorsBinary(t *testing.T) {
+	l := encodingFactors(t)
+	data, err := l.MarshalBinary()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var m Factors
+	if err := m.UnmarshalBinary(data); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !sameFactors(l, &m) {
+		t.Errorf("got %s", mustJSON(&m))
+	}
+	// every truncation fails
+	for i := 0; i < len(data); i++ {
+		var m Factors
+		if err := m.UnmarshalBinary(data[:i]); err == nil {
+			t.Fatalf("no error for %v of %v bytes", i, len(data))
+		}
+	}
+	if err := m.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("no error for extra data")
+	}
+	data[0] = 2
+	if err := m.UnmarshalBinary(data); err == nil {
+		t.Error("no error for wrong version")
+	}
+	// a single factor
+	f := Fact{Fac: big.NewInt(1000003), Exp: 3, Stat: Composite}
+	data, err = f.MarshalBinary()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var g Fact
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if g.Fac.Cmp(f.Fac) != 0 || g.Exp != f.Exp || g.Stat != f.Stat {
+		t.Errorf("got %+v", g)
+	}
+	// the status is stored by name
+	i := bytes.Index(data, []byte("Composite"))
+	if i < 0 {
+		t.Fatalf("no status name in %q", data)
+	}
+	data[i] = 'X'
+	if err := g.UnmarshalBinary(data); err == nil {
+		t.Error("no error for an unknown status name")
+	}
+	f.Stat = 7
+	if _, err := f.MarshalBinary(); err == nil {
+		t.Error("no error for invalid status")
+	}
+}
+
+func TestStatusText(t *testing.T) {
+	// the names are stable
+	want := map[Status]string{Unknown: "Unknown", ProbPrime: "ProbPrime", Composite: "Composite", Prime: "Prime"}
+	for s, name := range want {
+		text, err := s.MarshalText()
+		if err != nil || string(text) != name || s.String() != name {
+			t.Errorf("%d: got %s, %v", int(s), text, err)
+		}
+		var r Status
+		if err := r.UnmarshalText(text); err != nil || r != s {
+			t.Errorf("%s: got %v, %v", name, r, err)
+		}
+	}
+	if s := Status(9).String(); s != "Status(9)" {
+		t.Errorf("got %v", s)
+	}
+}
@@ -0,0 +1,211 @@
+package intfact
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"runtime"
+)
+
+// FactorOptions controls the strategy used by Factor and Complete.
+// A nil pointer or zero fields select the defaults.
+type FactorOptions struct {
+	// TrialBound is the prime bound for the initial trial division (default 10000).
+	TrialBound uint32
+	// Rounds is the number of Miller-Rabin rounds that PrimTest adds to BPSW for factors
+	// above 3.3·10^24 (default 20).
+	Rounds int
+	// Random is the source of randomness for the curve selection (default crypto/rand.Reader).
+	Random io.Reader
+	// Parallel is the number of curves run concurrently (default runtime.NumCPU()).
+	Parallel int
+	// Prove makes Complete prove the probable prime factors with ProvePrime,
+	// so that all factors are Prime when it succeeds.
+	Prove bool
+	// Debug sets Factors.Debug for the list created by Factor, so that every change
+	// of the list is checked.
+	Debug bool
+	// Methods are the factoring methods to use. If Methods is nil, DefaultMethods
+	// for Random and Parallel and the methods added with RegisterMethod are used.
+	Methods []Method
+}
+
+func (o *FactorOptions) withDefaults() FactorOptions {
+	var r FactorOptions
+	if o != nil {
+		r = *o
+	}
+	if r.TrialBound == 0 {
+		r.TrialBound = 10000
+	}
+	if r.Rounds == 0 {
+		r.Rounds = 20
+	}
+	if r.Random == nil {
+		r.Random = rand.Reader
+	}
+	if r.Parallel <= 0 {
+		r.Parallel = runtime.NumCPU()
+	}
+	if r.Methods == nil {
+		r.Methods = append(DefaultMethods(r.Random, r.Parallel), RegisteredMethods()...)
+	}
+	return r
+}
+
+// level describes one step of the escalation.
+// rho is the iteration limit for Rho (0 skips it), pb and pb1 are the bounds for PmOne,
+// eb and eb1 are the bounds for Ec and curves is the number of curves to try.
+type level struct {
+	rho     int
+	pb, pb1 uint32
+	eb, eb1 uint32
+	curves  int
+}
+
+// levels roughly follows the usual recommendations for finding factors of
+// 15, 20, 25, ... digits.
+var levels = []level{
+	{rho: 1 << 12, pb: 20000, pb1: 1000000, eb: 2000, eb1: 50000, curves: 25},
+	{rho: 1 << 16, pb: 110000, pb1: 5500000, eb: 11000, eb1: 275000, curves: 90},
+	{pb: 500000, pb1: 25000000, eb: 50000, eb1: 1250000, curves: 300},
+	{pb: 2500000, pb1: 125000000, eb: 250000, eb1: 6250000, curves: 700},
+	{pb: 10000000, pb1: 500000000, eb: 1000000, eb1: 25000000, curves: 1800},
+	{pb: 30000000, pb1: math.MaxUint32, eb: 3000000, eb1: 75000000, curves: 5100},
+	{pb: 110000000, pb1: math.MaxUint32, eb: 11000000, eb1: 275000000, curves: 10600},
+	{pb: 430000000, pb1: math.MaxUint32, eb: 43000000, eb1: 1075000000, curves: 19300},
+}
+
+// Factor completely factors n > 0.
+// It is a shorthand for NewFactors followed by Complete.
+// The factorisation of 1 is the empty list.
+//
+// The function returns the factorisation found so far and an error if the
+// factorisation could not be completed, e.g. because the context was cancelled.
+func Factor(ctx context.Context, n *big.Int, opts *FactorOptions) (*Factors, error) {
+	if n.Sign() <= 0 {
+		return nil, errors.New("n must be positive")
+	}
+	l := NewFactors(new(big.Int).Set(n))
+	l.Debug = opts != nil && opts.Debug
+	return l, l.Complete(ctx, opts)
+}
+
+// Complete factors the list until IsComplete reports that all factors are at least probably prime.
+// It first runs trial division if PBound is below the trial division bound and then
+// runs the configured methods in order of increasing cost on each unknown or composite factor.
+// With the default methods this escalates through Rho, PmOne and Ec with growing bounds
+// and runs QuadraticSieve when its cost estimate is reached.
+// If all methods fail, they are tried again, so that randomized methods get a new chance.
+// Every factor found is recorded with RecordSplit.
+// Factors equal to 1 are removed from the list.
+// Before the methods run, perfect powers are reduced to their base with PerfectPowers.
+// Factors below 2^64 are instead factored completely with FactorUint64 and marked as prime.
+// With the option Prove the remaining probable primes are proven with ProvePrime.
+//
+// The function returns an error if the context is cancelled before the factorisation is complete.
+// In this case the list contains the partial factorisation found so far.
+func (l *Factors) Complete(ctx context.Context, opts *FactorOptions) error {
+	o := opts.withDefaults()
+	var want *big.Int
+	if l.Debug {
+		want = l.Product()
+	}
+	if l.PBound.Cmp(big.NewInt(int64(o.TrialBound))) < 0 {
+		l.TrialDivision(o.TrialBound)
+	}
+	l.dropUnits()
+	for {
+		l.PerfectPowers()
+		l.completeSmall()
+		if l.Debug {
+			l.check("Complete", want)
+		}
+		l.PrimTest(o.Rounds, false)
+		if l.IsComplete() != 0 {
+			if !o.Prove {
+				return nil
+			}
+			if err := l.prove(ctx, &o); err != nil {
+				return err
+			}
+			if l.IsComplete() != 0 {
+				return nil
+			}
+		}
+		fp := &l.First
+		for (*fp).Stat != Unknown && (*fp).Stat != Composite {
+			fp = &(*fp).Next
+		}
+		n := (*fp).Fac
+		d, err := split(ctx, n, &o)
+		if err != nil {
+			return err
+		}
+		l.RecordSplit(fp, d, new(big.Int).Div(n, d))
+	}
+}
+
+// dropUnits removes the factors equal to 1, which have no prime factors.
+func (l *Factors) dropUnits() {
+	for fp := &l.First; *fp != nil; {
+		if f := *fp; f.Fac.Cmp(bigOne) == 0 {
+			*fp = f.Next
+		} else {
+			fp = &f.Next
+		}
+	}
+}
+
+// completeSmall replaces the factors below 2^64 that are not known to be prime
+// by their prime factors.
+func (l *Factors) completeSmall() {
+	var small []*Fact
+	for fp := &l.First; *fp != nil; {
+		if f := *fp; f.Stat != Prime && f.Fac.IsUint64() && f.Fac.Cmp(bigOne) > 0 {
+			*fp = f.Next
+			small = append(small, f)
+		} else {
+			fp = &f.Next
+		}
+	}
+	for _, f := range small {
+		for _, p := range FactorUint64(f.Fac.Uint64()) {
+			l.Insert(&Fact{Fac: new(big.Int).SetUint64(p), Exp: f.Exp, Stat: Prime})
+		}
+	}
+}
+
+// split finds a nontrivial factor of the composite n.
+// It only returns an error if the context is cancelled or no method is applicable to n.
+func split(ctx context.Context, n *big.Int, o *FactorOptions) (*big.Int, error) {
+	ms := schedule(o.Methods, n)
+	if len(ms) == 0 {
+		return nil, errors.New("no applicable factoring method")
+	}
+	for {
+		for _, m := range ms {
+			if ctx.Err() != nil {
+				return nil, cancelled(ctx)
+			}
+			d, _ := m.Run(ctx, n)
+			if divides(d, n) {
+				return d, nil
+			}
+		}
+	}
+}
+
+// divides checks if the result d of a method is a proper divisor of n.
+// Unlike isProper it does not trust the method and checks that d divides n.
+func divides(d, n *big.Int) bool {
+	return isProper(d, n) && new(big.Int).Mod(n, d).Sign() == 0
+}
+
+// isProper checks if d is a proper divisor of n, i.e. 1 < d < n.
+func isProper(d, n *big.Int) bool {
+	return d != nil && d.Cmp(bigOne) > 0 && d.Cmp(n) < 0
+}
@@ -0,0 +1,130 @@
+package intfact
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestFactor(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *big.Int
+		want []string
+		long bool
+	}{
+		{
+			name: "small",
+			n:    big.NewInt(360),
+			want: []string{"2", "2", "2", "3", "3", "5"},
+		},
+		{
+			name: "one",
+			n:    big.NewInt(1),
+			want: nil,
+		},
+		{
+			name: "m67",
+			n:    intval("147573952589676412927"),
+			want: []string{"193707721", "761838257287"},
+		},
+		{
+			name: "f6",
+			n:    intval("18446744073709551617"),
+			want: []string{"274177", "67280421310721"},
+		},
+		{
+			name: "square",
+			n:    intval("1000006000009"),
+			want: []string{"1000003", "1000003"},
+		},
+		{
+			name: "f7",
+			n:    intval("340282366920938463463374607431768211457"),
+			want: []string{"59649589127497217", "5704689200685129054721"},
+			long: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.long && testing.Short() {
+				t.Skip("skipped in short mode")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+			l, err := Factor(ctx, tt.n, &FactorOptions{Random: &lcRandom{x: 10}})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if l.IsComplete() == 0 {
+				t.Error("factorisation is not complete")
+			}
+			var got []string
+			for f := l.First; f != nil; f = f.Next {
+				for i := uint(0); i < f.Exp; i++ {
+					got = append(got, f.Fac.String())
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got factors %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got factors %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCompleteUnit(t *testing.T) {
+	// trial division is skipped, so the unit reaches the primality test
+	l := &Factors{
+		First:  &Fact{Fac: big.NewInt(1), Exp: 1, Stat: Unknown, Next: &Fact{Fac: big.NewInt(1000003), Exp: 1, Stat: Unknown}},
+		PBound: big.NewInt(1 << 20),
+	}
+	if err := l.Complete(context.Background(), nil); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if l.First == nil || l.First.Fac.Int64() != 1000003 || l.First.Next != nil {
+		t.Errorf("got %v, want 1000003", l)
+	}
+}
+
+func TestFactorCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// product of two 30 digit primes
+	n := intval("1000000000000000000000000000156000000000000000000000000005643")
+	l, err := Factor(ctx, n, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if l.IsComplete() != 0 {
+		t.Error("factorisation should not be complete")
+	}
+}
+
+func TestFactorUint64Proven(t *testing.T) {
+	// 2^64-1 and a semiprime just below 2^64
+	for _, s := range []string{"18446744073709551615", "18446743979220271189"} {
+		l, err := Factor(context.Background(), intval(s), nil)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if l.IsComplete() != 2 {
+			t.Errorf("%v: factors are not proven prime", s)
+		}
+		p := big.NewInt(1)
+		for f := l.First; f != nil; f = f.Next {
+			for i := uint(0); i < f.Exp; i++ {
+				p.Mul(p, f.Fac)
+			}
+		}
+		if p.String() != s {
+			t.Errorf("%v: product of the factors is %v", s, p)
+		}
+	}
+}
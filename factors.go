@@ -1,7 +1,9 @@
 package intfact
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"math/big"
 )
 
@@ -21,10 +23,50 @@ var (
 	bigThree = big.NewInt(3)
 )
 
+// Errors returned by the factoring methods.
 var (
-	errGcdIsN = errors.New("no factor found")
+	// ErrCancelled is reported when the context of a method is done.
+	// The returned error also wraps the error of the context, so that errors.Is
+	// can be used to check for context.DeadlineExceeded or context.Canceled.
+	ErrCancelled = errors.New("cancelled")
+	// ErrNoFactor is reported when a method finished without finding a factor.
+	ErrNoFactor = errors.New("no factor found")
+	// ErrTrivialGCD is reported when a gcd collapsed to n, i.e. all factors
+	// were found at once. Retrying with other parameters may help.
+	ErrTrivialGCD = errors.New("gcd is n")
 )
 
+type cancelledError struct {
+	err error
+}
+
+// cancelled returns the error reported when ctx is done.
+func cancelled(ctx context.Context) error {
+	return cancelledError{ctx.Err()}
+}
+
+func (e cancelledError) Error() string {
+	return "cancelled: " + e.err.Error()
+}
+
+func (e cancelledError) Is(target error) bool {
+	return target == ErrCancelled
+}
+
+func (e cancelledError) Unwrap() error {
+	return e.err
+}
+
+// FactorFoundError reports that a number assumed to be prime has the nontrivial factor Factor.
+// It is returned by computations that need inverses modulo n, like the curve arithmetic of Ec.
+type FactorFoundError struct {
+	Factor *big.Int
+}
+
+func (e FactorFoundError) Error() string {
+	return "modulus is not prime, a factor is: " + e.Factor.String()
+}
+
 type gcdtest struct {
 	n      *big.Int
 	it     int
@@ -59,7 +101,7 @@ func (t *gcdtest) finish() (fac *big.Int, err error) {
 	d := new(big.Int).GCD(nil, nil, t.acc, t.n)
 	if d.Cmp(bigOne) != 0 {
 		if d.Cmp(t.n) == 0 {
-			fac, err = nil, errGcdIsN
+			fac, err = nil, ErrTrivialGCD
 		} else {
 			fac, err = d, nil
 		}
@@ -72,6 +114,9 @@ type Fact struct {
 	Fac  *big.Int
 	Exp  uint
 	Stat Status
+	// Cert is the primality certificate of a factor that Prove has proven prime.
+	// It is empty for factors below 3.3·10^24.
+	Cert Certificate
 	Next *Fact
 }
 
@@ -81,6 +126,10 @@ type Factors struct {
 	First *Fact
 	// bound used for trial division. Factors < PBound^2 must be prime.
 	PBound *big.Int
+	// Debug makes RecordSplit, TrialDivision and Insert check after each change
+	// that the factors are increasing and pairwise coprime and that the product
+	// is as expected. They panic if the check fails.
+	Debug bool
 }
 
 // NewFactors creates a fresh Factors structure for the number a.
@@ -98,32 +147,122 @@ func NewFactors(a *big.Int) *Factors {
 }
 
 // RecordSplit removes fp and inserts new factors a and b.
-// Since the new factors are smaller than fp, the list starting with
-// *fp.Next is unchanged.
+// Factors below PBound^2 are marked as prime.
+// Since the new factors are inserted with Insert, the factors stay pairwise
+// coprime and the product is preserved.
 func (l *Factors) RecordSplit(fp **Fact, a, b *big.Int) {
+	var want *big.Int
+	if l.Debug {
+		want = l.Product()
+	}
 	// remove the factor from the list
 	f := *fp
 	*fp = f.Next
 	// create and insert new factors
-	bnd := new(big.Int).Mul(l.PBound, l.PBound)
-	fn := new(Fact)
-	fn.Fac = a
-	fn.Exp = f.Exp
-	if bnd.Cmp(a) > 0 {
-		fn.Stat = Prime
-	} else {
-		fn.Stat = Unknown
-	}
-	l.Insert(fn)
-	fn = new(Fact)
-	fn.Fac = b
-	fn.Exp = f.Exp
-	if bnd.Cmp(b) > 0 {
+	l.insert(l.newFact(a, f.Exp), true)
+	l.insert(l.newFact(b, f.Exp), true)
+	if l.Debug {
+		l.check("RecordSplit", want)
+	}
+}
+
+// newFact returns a new factor, which is prime if it is below PBound^2.
+func (l *Factors) newFact(a *big.Int, exp uint) *Fact {
+	fn := &Fact{Fac: a, Exp: exp, Stat: Unknown}
+	if new(big.Int).Mul(l.PBound, l.PBound).Cmp(a) > 0 {
 		fn.Stat = Prime
-	} else {
-		fn.Stat = Unknown
 	}
-	l.Insert(fn)
+	return fn
+}
+
+// Product returns the product of the factors with their exponents.
+func (l *Factors) Product() *big.Int {
+	p := big.NewInt(1)
+	for f := l.First; f != nil; f = f.Next {
+		p.Mul(p, new(big.Int).Exp(f.Fac, big.NewInt(int64(f.Exp)), nil))
+	}
+	return p
+}
+
+// ErrInvalidFactors is reported by Verify.
+var ErrInvalidFactors = errors.New("invalid factorisation")
+
+// Verify checks that the product of the factors is n, that they are increasing,
+// pairwise coprime and have positive exponents, and that their status is
+// consistent with IsPrime: Prime factors must be proven prime by IsPrime or by
+// their certificate, or at least pass BPSW if they have none, ProbPrime factors
+// must pass BPSW, and Composite factors must fail it.
+// The error matches ErrInvalidFactors if a check fails.
+func (l *Factors) Verify(n *big.Int) error {
+	if err := l.checkList(); err != nil {
+		return err
+	}
+	if p := l.Product(); p.Cmp(n) != 0 {
+		return fmt.Errorf("%w: the product is %v, not %v", ErrInvalidFactors, p, n)
+	}
+	for f := l.First; f != nil; f = f.Next {
+		if f.Fac.Cmp(bigOne) == 0 && f == l.First && f.Next == nil {
+			// the factorisation of 1
+			continue
+		}
+		prime, proven := IsPrime(f.Fac)
+		switch f.Stat {
+		case Prime:
+			if f.Cert != nil {
+				if err := VerifyCertificate(f.Fac, f.Cert); err != nil {
+					return fmt.Errorf("%w: factor %v: %v", ErrInvalidFactors, f.Fac, err)
+				}
+			} else if !prime {
+				return fmt.Errorf("%w: factor %v is marked prime but is composite", ErrInvalidFactors, f.Fac)
+			}
+		case ProbPrime:
+			if !prime {
+				return fmt.Errorf("%w: factor %v is marked probably prime but is composite", ErrInvalidFactors, f.Fac)
+			}
+		case Composite:
+			if prime && proven {
+				return fmt.Errorf("%w: factor %v is marked composite but is prime", ErrInvalidFactors, f.Fac)
+			}
+			if prime {
+				return fmt.Errorf("%w: factor %v is marked composite but passes BPSW", ErrInvalidFactors, f.Fac)
+			}
+		}
+	}
+	return nil
+}
+
+// checkList checks that the factors are increasing and pairwise coprime and
+// that the exponents are positive.
+func (l *Factors) checkList() error {
+	g := new(big.Int)
+	for f := l.First; f != nil; f = f.Next {
+		if f.Fac == nil || f.Fac.Sign() <= 0 || f.Exp == 0 {
+			return fmt.Errorf("%w: factor %v with exponent %v", ErrInvalidFactors, f.Fac, f.Exp)
+		}
+		if f.Next == nil {
+			break
+		}
+		if f.Next.Fac == nil || f.Fac.Cmp(f.Next.Fac) >= 0 {
+			return fmt.Errorf("%w: factor %v before %v", ErrInvalidFactors, f.Fac, f.Next.Fac)
+		}
+		for h := f.Next; h != nil; h = h.Next {
+			if h.Fac != nil && g.GCD(nil, nil, f.Fac, h.Fac).Cmp(bigOne) != 0 {
+				return fmt.Errorf("%w: factors %v and %v are not coprime", ErrInvalidFactors, f.Fac, h.Fac)
+			}
+		}
+	}
+	return nil
+}
+
+// check panics if checkList fails or the product is not want.
+func (l *Factors) check(op string, want *big.Int) {
+	err := l.checkList()
+	if p := l.Product(); err == nil && p.Cmp(want) != 0 {
+		err = fmt.Errorf("%w: the product is %v, not %v", ErrInvalidFactors, p, want)
+	}
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", op, err))
+	}
 }
 
 // IsComplete checks if the factorisation is complete.
@@ -144,14 +283,22 @@ func (l *Factors) IsComplete() int {
 }
 
 // PrimTest runs a primality test on the factors and updates their status.
-// The test is done by calling func (*big.Int) ProbablyPrime(n)
+// Factors below 3.3·10^24 get a deterministic Miller-Rabin test and become Prime or Composite.
+// Larger factors that pass BPSW and n further Miller-Rabin rounds with the primes
+// from 43 on as bases become ProbPrime.
 // If retest is true checks again probably prime factors.
 func (l *Factors) PrimTest(n int, retest bool) {
 	for f := l.First; f != nil; f = f.Next {
 		if f.Stat == Unknown || retest && f.Stat == ProbPrime {
-			if f.Fac.ProbablyPrime(n) {
+			prime, proven := IsPrime(f.Fac)
+			switch {
+			case !prime:
+				f.Stat = Composite
+			case proven:
+				f.Stat = Prime
+			case MillerRabin(f.Fac, extraBases(n)...):
 				f.Stat = ProbPrime
-			} else {
+			default:
 				f.Stat = Composite
 			}
 		}
@@ -160,24 +307,101 @@ func (l *Factors) PrimTest(n int, retest bool) {
 
 // Insert is a low level function that adds a factor to the list.
 // This operation does not preserve the product.
+// If the new factor shares a nontrivial gcd with an existing factor, both are
+// split into the gcd and the cofactors until all factors are pairwise coprime
+// again. A factor equal to an existing one is merged by adding the exponents.
 func (l *Factors) Insert(f *Fact) {
+	l.insert(f, false)
+}
+
+// insert is Insert for a factor f that was trial divided up to PBound if listed
+// is true, e.g. because it divides a factor of the list.
+func (l *Factors) insert(f *Fact, listed bool) {
+	var want *big.Int
+	if l.Debug {
+		want = l.Product()
+		want.Mul(want, new(big.Int).Exp(f.Fac, big.NewInt(int64(f.Exp)), nil))
+	}
+	pending := []*Fact{f}
+	for len(pending) > 0 {
+		f := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		pending = append(pending, l.insertCoprime(f, listed)...)
+	}
+	if l.Debug {
+		l.check("Insert", want)
+	}
+}
+
+// insertCoprime inserts f into the list if it is coprime to all factors or
+// equal to one of them. Otherwise it removes the first factor b with
+// d = gcd(f, b) > 1 and returns the pieces d and b/d with the exponent of b,
+// and d and f/d with the exponent of f, without the ones.
+func (l *Factors) insertCoprime(f *Fact, listed bool) []*Fact {
+	d := new(big.Int)
 	var pp **Fact
-	var cmp int
 	for pp = &l.First; *pp != nil; pp = &(*pp).Next {
-		cmp = (*pp).Fac.Cmp(f.Fac)
+		b := *pp
+		cmp := b.Fac.Cmp(f.Fac)
 		if cmp == 0 {
-			(*pp).Exp += f.Exp
-			(*pp).Stat = mergeStat((*pp).Stat, f.Stat)
-			return
+			b.Exp += f.Exp
+			b.Stat = mergeStat(b.Stat, f.Stat)
+			if b.Cert == nil {
+				b.Cert = f.Cert
+			}
+			return nil
 		}
-		if cmp == 1 {
-			break
+		if d.GCD(nil, nil, b.Fac, f.Fac).Cmp(bigOne) != 0 {
+			*pp = b.Next
+			return l.pieces(d, b, f, listed)
 		}
 	}
+	for pp = &l.First; *pp != nil && (*pp).Fac.Cmp(f.Fac) < 0; pp = &(*pp).Next {
+	}
 	f.Next = *pp
 	*pp = f
+	return nil
+}
+
+// pieces returns the factors d, b/d, d and f/d of the overlapping factor b of
+// the list and the inserted factor f with their exponents. A piece keeps the status
+// and certificate of an equal original factor. Other pieces of b are Prime below
+// PBound^2, and so are those of f if it is listed. Otherwise they are Unknown,
+// since f was not trial divided.
+func (l *Factors) pieces(d *big.Int, b, f *Fact, listed bool) []*Fact {
+	var ps []*Fact
+	for _, o := range []*Fact{b, f} {
+		for _, v := range []*big.Int{d, new(big.Int).Div(o.Fac, d)} {
+			switch {
+			case v.Cmp(bigOne) == 0:
+			case v.Cmp(o.Fac) == 0:
+				ps = append(ps, &Fact{Fac: o.Fac, Exp: o.Exp, Stat: o.Stat, Cert: o.Cert})
+			case o == b || listed:
+				ps = append(ps, l.newFact(new(big.Int).Set(v), o.Exp))
+			default:
+				ps = append(ps, &Fact{Fac: new(big.Int).Set(v), Exp: o.Exp, Stat: Unknown})
+			}
+		}
+	}
+	return ps
+}
+
+// Refine makes the factors pairwise coprime by inserting them again with
+// Insert. The product is preserved.
+func (l *Factors) Refine() {
+	f := l.First
+	l.First = nil
+	for f != nil {
+		next := f.Next
+		f.Next = nil
+		l.insert(f, true)
+		f = next
+	}
 }
 
+// mergeStat returns the status of a factor that was found twice.
+// Contradicting results of primality tests become Unknown, so that the
+// factor is tested again.
 func mergeStat(a, b Status) Status {
 	switch {
 	case a == Unknown:
@@ -191,5 +415,5 @@ func mergeStat(a, b Status) Status {
 	case a == b:
 		return a
 	}
-	panic(errors.New("incompatible factor stati"))
+	return Unknown
 }
@@ -0,0 +1,222 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// checkCoprime checks that the factors are increasing and pairwise coprime
+// and returns their product.
+func checkCoprime(t *testing.T, l *Factors) *big.Int {
+	t.Helper()
+	p := big.NewInt(1)
+	g := new(big.Int)
+	for f := l.First; f != nil; f = f.Next {
+		if f.Next != nil && f.Fac.Cmp(f.Next.Fac) >= 0 {
+			t.Errorf("%v before %v", f.Fac, f.Next.Fac)
+		}
+		for h := f.Next; h != nil; h = h.Next {
+			if g.GCD(nil, nil, f.Fac, h.Fac).Cmp(bigOne) != 0 {
+				t.Errorf("%v and %v are not coprime", f.Fac, h.Fac)
+			}
+		}
+		p.Mul(p, new(big.Int).Exp(f.Fac, big.NewInt(int64(f.Exp)), nil))
+	}
+	return p
+}
+
+func TestInsertCoprime(t *testing.T) {
+	type fe struct {
+		f int64
+		e uint
+	}
+	tests := []struct {
+		n      int64
+		insert []fe
+		want   []fe
+	}{
+		{210, []fe{{15, 2}}, []fe{{14, 1}, {15, 3}}},
+		{210, []fe{{11, 1}}, []fe{{11, 1}, {210, 1}}},
+		{210, []fe{{210, 2}}, []fe{{210, 3}}},
+		{12, []fe{{18, 1}}, []fe{{2, 3}, {3, 3}}},
+		{30, []fe{{42, 1}, {70, 1}}, []fe{{2, 3}, {3, 2}, {5, 2}, {7, 2}}},
+		{1 << 10, []fe{{6, 1}}, []fe{{2, 11}, {3, 1}}},
+	}
+	for _, tt := range tests {
+		l := NewFactors(big.NewInt(tt.n))
+		want := big.NewInt(tt.n)
+		for _, f := range tt.insert {
+			l.Insert(&Fact{Fac: big.NewInt(f.f), Exp: f.e, Stat: Composite})
+			want.Mul(want, new(big.Int).Exp(big.NewInt(f.f), big.NewInt(int64(f.e)), nil))
+		}
+		if p := checkCoprime(t, l); p.Cmp(want) != 0 {
+			t.Errorf("%v: product %v, want %v", tt.n, p, want)
+		}
+		i := 0
+		for f := l.First; f != nil; f = f.Next {
+			if i >= len(tt.want) || f.Fac.Int64() != tt.want[i].f || f.Exp != tt.want[i].e {
+				t.Errorf("%v: factor %v is %v^%v", tt.n, i, f.Fac, f.Exp)
+				break
+			}
+			i++
+		}
+		if i != len(tt.want) {
+			t.Errorf("%v: got %v factors, want %v", tt.n, i, len(tt.want))
+		}
+	}
+}
+
+func TestInsertRandom(t *testing.T) {
+	ps := []int64{2, 3, 5, 7, 11, 13, 1000003, 4294967291}
+	rnd := &lcRandom{x: 1}
+	next := func(n int) int {
+		b := make([]byte, 1)
+		_, _ = rnd.Read(b)
+		return int(b[0]) % n
+	}
+	for i := 0; i < 100; i++ {
+		l := NewFactors(big.NewInt(1))
+		want := big.NewInt(1)
+		for j := 0; j < 5; j++ {
+			v := big.NewInt(1)
+			for k := next(4) + 1; k > 0; k-- {
+				v.Mul(v, big.NewInt(ps[next(len(ps))]))
+			}
+			e := uint(next(3) + 1)
+			l.Insert(&Fact{Fac: v, Exp: e, Stat: Unknown})
+			want.Mul(want, new(big.Int).Exp(v, big.NewInt(int64(e)), nil))
+		}
+		if p := checkCoprime(t, l); p.Cmp(want) != 0 {
+			t.Fatalf("product %v, want %v", p, want)
+		}
+	}
+}
+
+func TestRefine(t *testing.T) {
+	// a list with overlapping factors built by hand
+	l := &Factors{PBound: big.NewInt(10)}
+	l.First = &Fact{Fac: big.NewInt(15), Exp: 1, Stat: Composite,
+		Next: &Fact{Fac: big.NewInt(21), Exp: 2, Stat: Composite,
+			Next: &Fact{Fac: big.NewInt(1000003 * 35), Exp: 1, Stat: Composite}}}
+	l.Refine()
+	// 15 * 21^2 * 1000003 * 35 = 3^3 5^2 7^3 1000003
+	want := []struct {
+		f int64
+		e uint
+		s Status
+	}{{3, 3, Prime}, {5, 2, Prime}, {7, 3, Prime}, {1000003, 1, Unknown}}
+	i := 0
+	for f := l.First; f != nil; f = f.Next {
+		if i >= len(want) || f.Fac.Int64() != want[i].f || f.Exp != want[i].e || f.Stat != want[i].s {
+			t.Fatalf("factor %v is %v^%v %v", i, f.Fac, f.Exp, f.Stat)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Errorf("got %v factors, want %v", i, len(want))
+	}
+}
+
+func TestInsertNotTrialDivided(t *testing.T) {
+	// the inserted factor was not trial divided, so its piece 35 is not prime
+	n := big.NewInt(10007 * 10009)
+	l := NewFactors(n)
+	l.TrialDivision(10000)
+	l.Insert(&Fact{Fac: big.NewInt(10007 * 35), Exp: 1, Stat: Composite})
+	n.Mul(n, big.NewInt(10007*35))
+	for f := l.First; f != nil; f = f.Next {
+		if f.Fac.Int64() == 35 && f.Stat == Prime {
+			t.Error("35 is marked prime")
+		}
+	}
+	l.PrimTest(1, false)
+	if err := l.Verify(n); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInsertConflict(t *testing.T) {
+	// contradicting stati do not panic but lead to a new test
+	l := NewFactors(big.NewInt(1000003))
+	l.First.Stat = Composite
+	l.Insert(&Fact{Fac: big.NewInt(1000003), Exp: 1, Stat: Prime})
+	if l.First.Stat != Unknown || l.First.Exp != 2 {
+		t.Errorf("got %v^%v %v", l.First.Fac, l.First.Exp, l.First.Stat)
+	}
+	l.PrimTest(1, false)
+	if l.First.Stat != Prime {
+		t.Errorf("got %v", l.First.Stat)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m89 := intval("618970019642690137449562111")
+	n := new(big.Int).Mul(m89, big.NewInt(360))
+	l, err := Factor(context.Background(), n, &FactorOptions{Random: &lcRandom{x: 10}, Prove: true, Debug: true})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if p := l.Product(); p.Cmp(n) != 0 {
+		t.Errorf("product %v, want %v", p, n)
+	}
+	if err := l.Verify(n); err != nil {
+		t.Error("unexpected error", err)
+	}
+	if err := NewFactors(big.NewInt(1)).Verify(big.NewInt(1)); err != nil {
+		t.Error("unexpected error", err)
+	}
+	tests := []struct {
+		name   string
+		modify func(l *Factors)
+	}{
+		{"lost factor", func(l *Factors) { l.First = l.First.Next }},
+		{"exponent", func(l *Factors) { l.First.Exp++ }},
+		{"zero exponent", func(l *Factors) { l.First.Fac = big.NewInt(1); l.First.Exp = 0 }},
+		{"composite marked prime", func(l *Factors) {
+			l.First.Fac, l.First.Exp = big.NewInt(4), 1
+			l.First.Next.Fac, l.First.Next.Exp = big.NewInt(9*5*4), 1
+		}},
+		{"prime marked composite", func(l *Factors) { l.First.Stat = Composite }},
+		{"large prime marked composite", func(l *Factors) { l.First.Next.Next.Next.Stat = Composite }},
+		{"wrong certificate", func(l *Factors) { l.First.Cert = pratt89() }},
+		{"order", func(l *Factors) { l.First, l.First.Next, l.First.Next.Next = l.First.Next, l.First.Next.Next, l.First }},
+	}
+	for _, tt := range tests {
+		l, _ := Factor(context.Background(), n, nil)
+		tt.modify(l)
+		if err := l.Verify(n); !errors.Is(err, ErrInvalidFactors) {
+			t.Errorf("%v: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestDebug(t *testing.T) {
+	check := func(name string, f func(l *Factors)) {
+		t.Helper()
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("%v: no panic", name)
+			} else if err, ok := r.(error); !ok || !errors.Is(err, ErrInvalidFactors) {
+				t.Errorf("%v: got %v", name, r)
+			}
+		}()
+		l := NewFactors(big.NewInt(1000003 * 1000033))
+		l.Debug = true
+		f(l)
+	}
+	check("RecordSplit", func(l *Factors) { l.RecordSplit(&l.First, big.NewInt(1000003), big.NewInt(1000037)) })
+	check("Insert", func(l *Factors) { l.Insert(&Fact{Fac: big.NewInt(7), Exp: 0}) })
+	// correct changes pass
+	l := NewFactors(big.NewInt(1000003 * 1000033 * 12))
+	l.Debug = true
+	l.TrialDivision(100)
+	l.RecordSplit(&l.First.Next.Next, big.NewInt(1000003), big.NewInt(1000033))
+	l.Insert(&Fact{Fac: big.NewInt(1000003 * 7), Exp: 2})
+	want := big.NewInt(1000003 * 1000033 * 12)
+	want.Mul(want, big.NewInt(1000003*7*1000003*7))
+	if err := l.Verify(want); err != nil {
+		t.Error("unexpected error", err)
+	}
+}
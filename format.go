@@ -0,0 +1,150 @@
+package intfact
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// statusMarks are the marks of the factors in the notation of String.
+// The mark P is only used for abbreviated factors.
+var statusMarks = [...]string{
+	Unknown:   "U",
+	ProbPrime: "PRP",
+	Composite: "C",
+	Prime:     "P",
+}
+
+// String returns the factorisation in the notation 2^3 * 5 * 1234567891011 (C),
+// where the factors are marked with (C) if they are composite, (PRP) if they are
+// probably prime and (U) if their status is unknown. Proven prime factors have no
+// mark, so every unmarked factor is Prime. The empty product is 1.
+func (l *Factors) String() string {
+	return l.format(-1)
+}
+
+// Format implements fmt.Formatter. The verbs %v and %s print the notation of
+// String. With a precision, as in %.20v, factors with more digits are abbreviated
+// to their status and number of digits, e.g. P40, PRP40, C40 or U40.
+func (l *Factors) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		prec, ok := s.Precision()
+		if !ok {
+			prec = -1
+		}
+		fmt.Fprint(s, l.format(prec))
+	default:
+		fmt.Fprintf(s, "%%!%c(*intfact.Factors=%s)", verb, l.format(-1))
+	}
+}
+
+// format abbreviates factors with more than prec digits if prec >= 0.
+func (l *Factors) format(prec int) string {
+	if l == nil || l.First == nil {
+		return "1"
+	}
+	var b strings.Builder
+	for f := l.First; f != nil; f = f.Next {
+		if f != l.First {
+			b.WriteString(" * ")
+		}
+		mark := ""
+		if f.Stat >= 0 && int(f.Stat) < len(statusMarks) {
+			mark = statusMarks[f.Stat]
+		} else {
+			mark = f.Stat.String()
+		}
+		digits := f.Fac.String()
+		if prec >= 0 && len(digits) > prec {
+			b.WriteString(mark + strconv.Itoa(len(digits)))
+			mark = ""
+		} else {
+			b.WriteString(digits)
+		}
+		if f.Exp != 1 {
+			b.WriteString("^" + strconv.FormatUint(uint64(f.Exp), 10))
+		}
+		if mark != "" && f.Stat != Prime {
+			b.WriteString(" (" + mark + ")")
+		}
+	}
+	return b.String()
+}
+
+// ParseFactors reads a factorisation in the notation of String, also without
+// spaces as in 2^3*5. Repeated factors like in 2*2*3 are merged, and the factors
+// are sorted and made pairwise coprime with Insert. Factors without a mark or
+// with (P) are Prime and must pass IsPrime, as must those with (PRP), while
+// factors with (C) must fail it. Factors 1 are dropped, so that 1
+// gives the empty list. Abbreviated factors like P40 have no value and are rejected.
+func ParseFactors(s string) (*Factors, error) {
+	l := &Factors{PBound: big.NewInt(1)}
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("%w: empty factorisation", ErrInvalidFactors)
+	}
+	for _, term := range strings.Split(s, "*") {
+		f, err := parseFact(strings.TrimSpace(term))
+		if err != nil {
+			return nil, err
+		}
+		if f.Fac.Cmp(bigOne) != 0 {
+			l.Insert(f)
+		}
+	}
+	return l, nil
+}
+
+func parseFact(term string) (*Fact, error) {
+	bad := func(format string, a ...interface{}) error {
+		return fmt.Errorf("%w: %q: %s", ErrInvalidFactors, term, fmt.Sprintf(format, a...))
+	}
+	f := &Fact{Exp: 1, Stat: Prime}
+	rest := term
+	if strings.HasSuffix(rest, ")") {
+		i := strings.LastIndex(rest, "(")
+		if i < 0 {
+			return nil, bad("unbalanced parenthesis")
+		}
+		mark := strings.TrimSpace(rest[i+1 : len(rest)-1])
+		found := false
+		for st, m := range statusMarks {
+			if m == mark {
+				f.Stat, found = Status(st), true
+			}
+		}
+		if !found {
+			return nil, bad("unknown mark %q", mark)
+		}
+		rest = strings.TrimSpace(rest[:i])
+	}
+	base, exp, hasExp := strings.Cut(rest, "^")
+	base = strings.TrimSpace(base)
+	if hasExp {
+		e, err := strconv.ParseUint(strings.TrimSpace(exp), 10, 0)
+		if err != nil || e == 0 {
+			return nil, bad("invalid exponent %q", exp)
+		}
+		f.Exp = uint(e)
+	}
+	if base != "" && (base[0] < '0' || base[0] > '9') {
+		return nil, bad("abbreviated or invalid factor %q", base)
+	}
+	fac, ok := new(big.Int).SetString(base, 10)
+	if !ok || fac.Sign() <= 0 {
+		return nil, bad("invalid factor %q", base)
+	}
+	f.Fac = fac
+	if fac.Cmp(bigOne) == 0 {
+		return f, nil
+	}
+	prime, _ := IsPrime(fac)
+	switch {
+	case !prime && (f.Stat == Prime || f.Stat == ProbPrime):
+		return nil, bad("%v is not prime", fac)
+	case prime && f.Stat == Composite:
+		return nil, bad("%v is not composite", fac)
+	}
+	return f, nil
+}
@@ -0,0 +1,98 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestFactorsString(t *testing.T) {
+	p40 := intval("1000000000000000000000000000000000000003")
+	l := &Factors{PBound: big.NewInt(1)}
+	l.Insert(&Fact{Fac: big.NewInt(2), Exp: 3, Stat: Prime})
+	l.Insert(&Fact{Fac: big.NewInt(5), Exp: 1, Stat: Prime})
+	l.Insert(&Fact{Fac: big.NewInt(1234567891011), Exp: 1, Stat: Composite})
+	l.Insert(&Fact{Fac: p40, Exp: 1, Stat: Prime})
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%v", "2^3 * 5 * 1234567891011 (C) * " + p40.String()},
+		{"%s", "2^3 * 5 * 1234567891011 (C) * " + p40.String()},
+		{"%.20v", "2^3 * 5 * 1234567891011 (C) * P40"},
+		{"%.5v", "2^3 * 5 * C13 * P40"},
+		{"%d", "%!d(*intfact.Factors=2^3 * 5 * 1234567891011 (C) * " + p40.String() + ")"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf(tt.format, l); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+	if got, want := l.String(), tests[0].want; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	l = &Factors{PBound: big.NewInt(1)}
+	l.Insert(&Fact{Fac: p40, Exp: 2, Stat: ProbPrime})
+	l.Insert(&Fact{Fac: big.NewInt(1000003 * 1000033), Exp: 1, Stat: Unknown})
+	if got, want := fmt.Sprintf("%.10v", l), "U13 * PRP40^2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := l.String(), "1000036000099 (U) * "+p40.String()+"^2 (PRP)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := (&Factors{}).String(); got != "1" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseFactors(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"2^3 * 5 * 1234567891011 (C)", "2^3 * 5 * 1234567891011 (C)"},
+		{"2^3*5", "2^3 * 5"},
+		{"5*2*2*2", "2^3 * 5"},
+		{" 3 ^ 2 * 7 (P) ", "3^2 * 7"},
+		{"1000036000099 (U) * 1000000000000000000000000000000000000003^2 (PRP)",
+			"1000036000099 (U) * 1000000000000000000000000000000000000003^2 (PRP)"},
+		{"6 (C) * 10 (C)", "2^2 (U) * 3 (U) * 5 (U)"},
+		{"1", "1"},
+		{"1 * 2", "2"},
+		{"3 * 1^2 (C)", "3"},
+	}
+	for _, tt := range tests {
+		l, err := ParseFactors(tt.s)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.s, err)
+			continue
+		}
+		if got := l.String(); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.s, got, tt.want)
+		}
+		if err := l.Verify(l.Product()); err != nil {
+			t.Errorf("%q: %v", tt.s, err)
+		}
+	}
+	invalid := []string{"", "2 * ", "2^3 * P40", "2^0", "2^x", "4", "0", "-3", "7 (X)", "7 )", "2**3",
+		"9 (PRP)", "7 (C)", "1000000000000000000000000000000000000003 (C)"}
+	for _, s := range invalid {
+		if _, err := ParseFactors(s); !errors.Is(err, ErrInvalidFactors) {
+			t.Errorf("%q: got %v", s, err)
+		}
+	}
+	// round trip
+	l, err := Factor(context.Background(), big.NewInt(360*1000003), nil)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	m, err := ParseFactors(l.String())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if err := m.Verify(big.NewInt(360 * 1000003)); err != nil || m.String() != l.String() {
+		t.Errorf("got %v, %v", m, err)
+	}
+}
@@ -0,0 +1,166 @@
+package gf2
+
+import "sort"
+
+// Filter returns the indices of the columns that can be part of a vector
+// in the nullspace. It removes the columns with a row that is non-zero in
+// no other column (singletons) until there are none left.
+func Filter(m *Matrix) []int {
+	alive := make([]bool, len(m.Cols))
+	for j := range alive {
+		alive[j] = true
+	}
+	cnt := make([]int, m.Rows)
+	for _, c := range m.Cols {
+		for _, r := range c {
+			cnt[r]++
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for j, c := range m.Cols {
+			if !alive[j] {
+				continue
+			}
+			for _, r := range c {
+				if cnt[r] == 1 {
+					alive[j] = false
+					break
+				}
+			}
+			if !alive[j] {
+				for _, r := range c {
+					cnt[r]--
+				}
+				changed = true
+			}
+		}
+	}
+	var kept []int
+	for j, a := range alive {
+		if a {
+			kept = append(kept, j)
+		}
+	}
+	return kept
+}
+
+// Reduce shrinks m by structured Gaussian elimination. Singleton columns are
+// removed, and a row with two non-zero entries is eliminated by adding one
+// column to the other, as long as the sum has at most maxWeight entries.
+// A maxWeight of 0 means no limit. Empty rows are dropped and the rows are
+// renumbered.
+// Reduce returns the reduced matrix and, for each of its columns, the sorted
+// indices of the columns of m whose sum it is. The nullspace vectors of the
+// reduced matrix map to nullspace vectors of m by replacing each column with
+// its combination.
+func Reduce(m *Matrix, maxWeight int) (*Matrix, [][]int) {
+	kept := Filter(m)
+	cols := make([][]int, len(kept))
+	comb := make([][]int, len(kept))
+	for i, j := range kept {
+		cols[i] = m.Cols[j]
+		comb[i] = []int{j}
+	}
+	alive := make([]bool, len(cols))
+	for j := range alive {
+		alive[j] = true
+	}
+	first := make([]int, m.Rows)
+	second := make([]int, m.Rows)
+	cnt := make([]int, m.Rows)
+	touched := make([]bool, len(cols))
+	for changed := true; changed; {
+		changed = false
+		for r := range cnt {
+			cnt[r] = 0
+		}
+		for j, c := range cols {
+			if !alive[j] {
+				continue
+			}
+			touched[j] = false
+			for _, r := range c {
+				switch cnt[r] {
+				case 0:
+					first[r] = j
+				case 1:
+					second[r] = j
+				}
+				cnt[r]++
+			}
+		}
+		for r, k := range cnt {
+			switch k {
+			case 1:
+				j := first[r]
+				if alive[j] && !touched[j] {
+					alive[j] = false
+					changed = true
+				}
+			case 2:
+				a, b := first[r], second[r]
+				if !alive[a] || !alive[b] || touched[a] || touched[b] {
+					continue
+				}
+				if maxWeight > 0 && len(cols[a])+len(cols[b])-2 > maxWeight {
+					continue
+				}
+				// keep the lighter column as the one to remove
+				if len(cols[a]) > len(cols[b]) {
+					a, b = b, a
+				}
+				cols[b] = xorSorted(cols[a], cols[b])
+				comb[b] = xorSorted(comb[a], comb[b])
+				alive[a] = false
+				touched[b] = true
+				changed = true
+			}
+		}
+	}
+	// renumber the rows that are still in use
+	idx := make([]int, m.Rows)
+	for r := range idx {
+		idx[r] = -1
+	}
+	red := &Matrix{}
+	var rcomb [][]int
+	for j, c := range cols {
+		if !alive[j] {
+			continue
+		}
+		nc := make([]int, len(c))
+		for i, r := range c {
+			if idx[r] < 0 {
+				idx[r] = red.Rows
+				red.Rows++
+			}
+			nc[i] = idx[r]
+		}
+		sort.Ints(nc)
+		red.Cols = append(red.Cols, nc)
+		rcomb = append(rcomb, comb[j])
+	}
+	return red, rcomb
+}
+
+// xorSorted returns the symmetric difference of two sorted sets.
+func xorSorted(a, b []int) []int {
+	s := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			s = append(s, a[i])
+			i++
+		case a[i] > b[j]:
+			s = append(s, b[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	s = append(s, a[i:]...)
+	return append(s, b[j:]...)
+}
@@ -0,0 +1,59 @@
+package gf2
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	m := NewMatrix(4)
+	m.AddColumn([]int{0, 1})
+	m.AddColumn([]int{1, 2})
+	m.AddColumn([]int{0, 2})
+	// row 3 is a singleton, removing column 3 makes row 2 of column 4 one
+	m.AddColumn([]int{2, 3})
+	m.AddColumn(nil)
+	kept := Filter(m)
+	if len(kept) != 4 || kept[0] != 0 || kept[1] != 1 || kept[2] != 2 || kept[3] != 4 {
+		t.Errorf("got %v, want [0 1 2 4]", kept)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for _, sz := range [][2]int{{30, 40}, {300, 330}, {2000, 2100}} {
+		m := randMatrix(rnd, sz[0], sz[1], 10)
+		red, comb := Reduce(m, 0)
+		if len(red.Cols) != len(comb) {
+			t.Fatal("lengths differ")
+		}
+		if len(red.Cols) >= len(m.Cols) {
+			t.Errorf("%v: nothing removed", sz)
+		}
+		// the combinations give the columns of the reduced matrix
+		for j, c := range comb {
+			w := 0
+			for _, k := range c {
+				w += len(m.Cols[k])
+			}
+			if w < len(red.Cols[j]) {
+				t.Fatal("inconsistent combination")
+			}
+		}
+		// no nullspace dimension is lost
+		want := len(m.Dense().Nullspace())
+		vecs := red.Dense().Nullspace()
+		if len(vecs) != want {
+			t.Errorf("%v: nullity %v, want %v", sz, len(vecs), want)
+		}
+		for _, v := range vecs {
+			var s []int
+			for _, j := range v {
+				s = xorSorted(s, comb[j])
+			}
+			if !m.IsZero(s) {
+				t.Fatal("vector not in the nullspace")
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package gf2 implements the linear algebra over GF(2) needed by the sieve
+// factoring methods. The relations are the columns of a sparse matrix with
+// one row per prime, an entry is the exponent of the prime modulo 2. A vector
+// in the nullspace selects relations whose product is a square.
+//
+// Nullspace shrinks the matrix with Filter and Reduce and then solves it with
+// Gaussian elimination on a Dense matrix or with BlockLanczos.
+package gf2
+
+import (
+	"errors"
+	"math/bits"
+	"sort"
+)
+
+// Method selects the algorithm that solves the reduced matrix.
+type Method int
+
+const (
+	// Auto uses Gaussian elimination for small matrices and Block Lanczos otherwise.
+	Auto Method = iota
+	// Gauss uses Gaussian elimination on a dense matrix.
+	Gauss
+	// Lanczos uses Block Lanczos.
+	Lanczos
+)
+
+// Options contains the parameters of Nullspace.
+// A nil pointer or zero fields select the defaults.
+type Options struct {
+	// Method is the solver for the reduced matrix (default Auto).
+	Method Method
+	// MaxWeight limits the column weight created by Reduce (default 32).
+	MaxWeight int
+	// Excess is the number of columns kept above the number of rows (default 64).
+	// Surplus columns are dropped after the reduction, the heaviest first.
+	Excess int
+	// Seed initializes the random source of Block Lanczos (default 1).
+	Seed int64
+}
+
+// gaussLimit is the number of columns up to which Auto uses Gaussian elimination.
+const gaussLimit = 1000
+
+func (o *Options) withDefaults() (Options, error) {
+	var r Options
+	if o != nil {
+		r = *o
+	}
+	if r.Method < Auto || r.Method > Lanczos {
+		return r, errors.New("unknown method")
+	}
+	if r.MaxWeight == 0 {
+		r.MaxWeight = 32
+	}
+	if r.Excess == 0 {
+		r.Excess = 64
+	}
+	if r.Seed == 0 {
+		r.Seed = 1
+	}
+	if r.MaxWeight < 0 || r.Excess < 0 {
+		return r, errors.New("negative option")
+	}
+	return r, nil
+}
+
+// Nullspace returns vectors in the nullspace of m. A vector is given by the
+// sorted indices of its non-zero entries, that is the columns whose sum is
+// zero. The vectors are distinct and not zero, but not necessarily independent.
+func Nullspace(m *Matrix, opts *Options) ([][]int, error) {
+	o, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	norm := &Matrix{Rows: m.Rows, Cols: make([][]int, len(m.Cols))}
+	for j, c := range m.Cols {
+		norm.Cols[j] = normalize(c)
+	}
+	red, comb := Reduce(norm, o.MaxWeight)
+	for len(red.Cols) > red.Rows+o.Excess {
+		// drop the heaviest columns and reduce again
+		idx := make([]int, len(red.Cols))
+		for j := range idx {
+			idx[j] = j
+		}
+		sort.SliceStable(idx, func(a, b int) bool {
+			return len(red.Cols[idx[a]]) < len(red.Cols[idx[b]])
+		})
+		idx = idx[:red.Rows+o.Excess]
+		sort.Ints(idx)
+		trim := &Matrix{Rows: red.Rows}
+		var tcomb [][]int
+		for _, j := range idx {
+			trim.Cols = append(trim.Cols, red.Cols[j])
+			tcomb = append(tcomb, comb[j])
+		}
+		var sub [][]int
+		red, sub = Reduce(trim, o.MaxWeight)
+		comb = make([][]int, len(sub))
+		for j, s := range sub {
+			for _, k := range s {
+				comb[j] = xorSorted(comb[j], tcomb[k])
+			}
+		}
+	}
+	var sols [][]int
+	method := o.Method
+	if method == Auto {
+		method = Lanczos
+		if len(red.Cols) <= gaussLimit {
+			method = Gauss
+		}
+	}
+	if method == Lanczos {
+		sols, err = BlockLanczos(red, o.Seed)
+		if err != nil && o.Method == Lanczos {
+			return nil, err
+		}
+	}
+	if method == Gauss || err != nil {
+		sols = red.Dense().Nullspace()
+	}
+	var res [][]int
+	seen := make(map[string]bool)
+	for _, s := range sols {
+		// sum the combinations in a bit vector over the columns of m
+		sum := make([]uint64, (len(m.Cols)+63)/64)
+		for _, j := range s {
+			for _, k := range comb[j] {
+				sum[k/64] ^= 1 << uint(k%64)
+			}
+		}
+		var v []int
+		for w, b := range sum {
+			for ; b != 0; b &= b - 1 {
+				v = append(v, 64*w+bits.TrailingZeros64(b))
+			}
+		}
+		if len(v) == 0 {
+			continue
+		}
+		if !norm.IsZero(v) {
+			panic(errors.New("gf2: invalid nullspace vector"))
+		}
+		key := string(intsKey(v))
+		if !seen[key] {
+			seen[key] = true
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,57 @@
+package gf2
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNullspace(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	tests := []struct {
+		rows, cols, weight int
+		method             Method
+		short              bool
+	}{
+		{50, 70, 6, Auto, true},
+		{50, 70, 6, Lanczos, true},
+		{2000, 2100, 15, Gauss, true},
+		{2000, 2100, 15, Auto, true},
+		{20000, 20200, 20, Auto, false},
+	}
+	for _, tt := range tests {
+		if !tt.short && testing.Short() {
+			continue
+		}
+		m := randMatrix(rnd, tt.rows, tt.cols, tt.weight)
+		vecs, err := Nullspace(m, &Options{Method: tt.method})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if len(vecs) < 20 {
+			t.Errorf("%v: got %v vectors", tt, len(vecs))
+		}
+		checkNullspace(t, m, vecs)
+	}
+}
+
+func TestNullspaceOptions(t *testing.T) {
+	m := NewMatrix(3)
+	m.AddColumn([]int{0, 0})
+	m.AddColumn([]int{1, 2})
+	m.AddColumn([]int{1})
+	m.AddColumn([]int{2})
+	vecs, err := Nullspace(m, nil)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(vecs) != 2 {
+		t.Errorf("got %v, want 2 vectors", vecs)
+	}
+	checkNullspace(t, m, vecs)
+	if _, err := Nullspace(m, &Options{Method: 7}); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+	if _, err := Nullspace(m, &Options{Excess: -1}); err == nil {
+		t.Error("expected an error for a negative excess")
+	}
+}
@@ -0,0 +1,299 @@
+package gf2
+
+import (
+	"errors"
+	"math/bits"
+	"math/rand"
+)
+
+// ErrNotConverged is returned when the Block Lanczos iteration breaks down.
+// Another seed may succeed.
+var ErrNotConverged = errors.New("block lanczos did not converge")
+
+// block64 is a 64x64 matrix, element i is row i, bit j is column j.
+type block64 [64]uint64
+
+var identity64 = func() (m block64) {
+	for i := range m {
+		m[i] = 1 << uint(i)
+	}
+	return
+}()
+
+// mul64 returns a*b.
+func mul64(a, b *block64) (c block64) {
+	for i, r := range a {
+		var s uint64
+		for ; r != 0; r &= r - 1 {
+			s ^= b[bits.TrailingZeros64(r)]
+		}
+		c[i] = s
+	}
+	return
+}
+
+// innerProduct returns the transpose of x times y.
+func innerProduct(x, y []uint64) (c block64) {
+	for i, r := range x {
+		yi := y[i]
+		for ; r != 0; r &= r - 1 {
+			c[bits.TrailingZeros64(r)] ^= yi
+		}
+	}
+	return
+}
+
+// mulAdd adds v*m to y.
+func mulAdd(y, v []uint64, m *block64) {
+	// precompute the sums for each byte of the rows of v
+	var tab [8][256]uint64
+	for k := range tab {
+		for b := 1; b < 256; b++ {
+			low := b & -b
+			tab[k][b] = tab[k][b^low] ^ m[8*k+bits.TrailingZeros(uint(low))]
+		}
+	}
+	for i, r := range v {
+		var s uint64
+		for k := 0; r != 0; k++ {
+			s ^= tab[k][r&0xff]
+			r >>= 8
+		}
+		y[i] ^= s
+	}
+}
+
+// selectColumns chooses the columns S of the symmetric matrix t, such that the
+// submatrix of S is invertible, and returns the inverse embedded in a 64x64
+// matrix (zero outside S) and the mask of S. Columns not in the previous
+// selection prev are preferred, so that every column is selected eventually.
+func selectColumns(t *block64, prev uint64) (winv block64, mask uint64, err error) {
+	var m [64][2]uint64
+	for i := range m {
+		m[i] = [2]uint64{t[i], 1 << uint(i)}
+	}
+	var order [64]int
+	k := 0
+	for i := 0; i < 64; i++ {
+		if prev>>uint(i)&1 == 0 {
+			order[k] = i
+			k++
+		}
+	}
+	for i := 0; i < 64; i++ {
+		if prev>>uint(i)&1 != 0 {
+			order[k] = i
+			k++
+		}
+	}
+	for i := 0; i < 64; i++ {
+		c := order[i]
+		b := uint64(1) << uint(c)
+		// find a pivot in column c of the left half
+		j := i
+		for ; j < 64 && m[order[j]][0]&b == 0; j++ {
+		}
+		if j < 64 {
+			m[order[i]], m[order[j]] = m[order[j]], m[order[i]]
+			pi := m[order[i]]
+			for l := 0; l < 64; l++ {
+				if l != i && m[order[l]][0]&b != 0 {
+					m[order[l]][0] ^= pi[0]
+					m[order[l]][1] ^= pi[1]
+				}
+			}
+			mask |= b
+			continue
+		}
+		// the column is dependent, use the right half and drop the row
+		j = i
+		for ; j < 64 && m[order[j]][1]&b == 0; j++ {
+		}
+		if j == 64 {
+			return winv, 0, ErrNotConverged
+		}
+		m[order[i]], m[order[j]] = m[order[j]], m[order[i]]
+		pi := m[order[i]]
+		for l := 0; l < 64; l++ {
+			if l != i && m[order[l]][1]&b != 0 {
+				m[order[l]][0] ^= pi[0]
+				m[order[l]][1] ^= pi[1]
+			}
+		}
+		m[order[i]] = [2]uint64{}
+	}
+	for i := range winv {
+		winv[i] = m[i][1]
+	}
+	return winv, mask, nil
+}
+
+// BlockLanczos returns vectors in the nullspace of m using Montgomery's Block
+// Lanczos algorithm on the symmetric matrix A = transpose(m)*m. A vector is
+// given by the sorted indices of its non-zero entries. The algorithm works
+// best if m has some more columns than rows. It returns at most 64 vectors.
+func BlockLanczos(m *Matrix, seed int64) ([][]int, error) {
+	n := len(m.Cols)
+	if n == 0 {
+		return nil, nil
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	mulA := func(v []uint64) []uint64 {
+		return m.mulTransBlock(m.mulBlock(v))
+	}
+	y := make([]uint64, n)
+	for i := range y {
+		y[i] = rnd.Uint64()
+	}
+	// solve A*x = A*y, then x - y is in the nullspace of A
+	v0 := mulA(y)
+	x := make([]uint64, n)
+	v := [3][]uint64{v0, make([]uint64, n), make([]uint64, n)}
+	var vtav, vta2v [2]block64
+	var winv [3]block64
+	mask1 := ^uint64(0)
+	for iter := 0; ; iter++ {
+		if iter > n/32+100 {
+			return nil, ErrNotConverged
+		}
+		av := mulA(v[0])
+		vtav[0] = innerProduct(v[0], av)
+		vta2v[0] = innerProduct(av, av)
+		if vtav[0] == (block64{}) {
+			break
+		}
+		w, mask0, err := selectColumns(&vtav[0], mask1)
+		if err != nil {
+			return nil, err
+		}
+		if mask0 == 0 {
+			return nil, ErrNotConverged
+		}
+		winv[0] = w
+		// d = I - winv0*(vta2v0*S*S' + vtav0)
+		var d block64
+		for i := range d {
+			d[i] = vta2v[0][i]&mask0 ^ vtav[0][i]
+		}
+		d = mul64(&winv[0], &d)
+		for i := range d {
+			d[i] ^= identity64[i]
+		}
+		// e = -winv1*vtav0*S*S'
+		e := mul64(&winv[1], &vtav[0])
+		for i := range e {
+			e[i] &= mask0
+		}
+		// f = -winv2*(I - vtav1*winv1)*(vta2v1*S1*S1' + vtav1)*S*S'
+		f := mul64(&vtav[1], &winv[1])
+		for i := range f {
+			f[i] ^= identity64[i]
+		}
+		f = mul64(&winv[2], &f)
+		var f2 block64
+		for i := range f2 {
+			f2[i] = (vta2v[1][i]&mask1 ^ vtav[1][i]) & mask0
+		}
+		f = mul64(&f, &f2)
+		next := av
+		for i := range next {
+			next[i] &= mask0
+		}
+		mulAdd(next, v[0], &d)
+		mulAdd(next, v[1], &e)
+		mulAdd(next, v[2], &f)
+		// x += v0*winv0*v0'*v0 (the initial v0)
+		t := innerProduct(v[0], v0)
+		t = mul64(&winv[0], &t)
+		mulAdd(x, v[0], &t)
+		v[2], v[1], v[0] = v[1], v[0], next
+		winv[2], winv[1] = winv[1], winv[0]
+		vtav[1], vta2v[1] = vtav[0], vta2v[0]
+		mask1 = mask0
+	}
+	for i := range x {
+		x[i] ^= y[i]
+	}
+	return combineBlocks(m, x, v[0]), nil
+}
+
+// combineBlocks returns the combinations of the 128 vectors in x and v
+// that are in the nullspace of m.
+func combineBlocks(m *Matrix, x, v []uint64) [][]int {
+	mx, mv := m.mulBlock(x), m.mulBlock(v)
+	// column k of [mx mv] is the image of vector k; find the dependent ones
+	// by Gaussian elimination on the transposed matrix
+	rows := m.Rows
+	words := (rows + 63) / 64
+	img := make([][]uint64, 128)
+	comb := make([][2]uint64, 128)
+	for k := range img {
+		img[k] = make([]uint64, words)
+		comb[k][k/64] = 1 << uint(k%64)
+	}
+	for r := 0; r < rows; r++ {
+		for k := 0; k < 128; k++ {
+			var b uint64
+			if k < 64 {
+				b = mx[r] >> uint(k) & 1
+			} else {
+				b = mv[r] >> uint(k-64) & 1
+			}
+			img[k][r/64] |= b << uint(r%64)
+		}
+	}
+	used := make([]bool, 128)
+	for r := 0; r < rows; r++ {
+		w, b := r/64, uint64(1)<<uint(r%64)
+		p := -1
+		for k := 0; k < 128; k++ {
+			if !used[k] && img[k][w]&b != 0 {
+				p = k
+				break
+			}
+		}
+		if p < 0 {
+			continue
+		}
+		used[p] = true
+		for k := 0; k < 128; k++ {
+			if k != p && img[k][w]&b != 0 {
+				xorWords(img[k], img[p])
+				comb[k][0] ^= comb[p][0]
+				comb[k][1] ^= comb[p][1]
+			}
+		}
+	}
+	var res [][]int
+	seen := make(map[string]bool)
+	for k := 0; k < 128; k++ {
+		if used[k] {
+			continue
+		}
+		cx, cv := comb[k][0], comb[k][1]
+		var vec []int
+		for i := range x {
+			if (bits.OnesCount64(x[i]&cx)+bits.OnesCount64(v[i]&cv))%2 != 0 {
+				vec = append(vec, i)
+			}
+		}
+		if len(vec) == 0 {
+			continue
+		}
+		key := string(intsKey(vec))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		res = append(res, vec)
+	}
+	return res
+}
+
+func intsKey(v []int) []byte {
+	b := make([]byte, 0, 4*len(v))
+	for _, i := range v {
+		b = append(b, byte(i), byte(i>>8), byte(i>>16), byte(i>>24))
+	}
+	return b
+}
@@ -0,0 +1,55 @@
+package gf2
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSelectColumns(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	for k := 0; k < 20; k++ {
+		// a random symmetric matrix
+		var m block64
+		for i := range m {
+			for j := 0; j <= i; j++ {
+				if rnd.Intn(3) == 0 {
+					m[i] |= 1 << uint(j)
+					m[j] |= 1 << uint(i)
+				}
+			}
+		}
+		prev := rnd.Uint64()
+		winv, mask, err := selectColumns(&m, prev)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		// winv is the inverse of the submatrix of the columns in mask
+		p := mul64(&winv, &m)
+		for i := range p {
+			if mask>>uint(i)&1 == 0 {
+				if winv[i] != 0 {
+					t.Fatal("row outside the selection")
+				}
+				continue
+			}
+			if p[i]&mask != 1<<uint(i) {
+				t.Fatal("not an inverse")
+			}
+		}
+	}
+}
+
+func TestBlockLanczos(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	for _, sz := range [][2]int{{100, 164}, {1000, 1100}, {5000, 5100}} {
+		m := randMatrix(rnd, sz[0], sz[1], 20)
+		vecs, err := BlockLanczos(m, 1)
+		if err != nil {
+			t.Fatal(sz, "unexpected error", err)
+		}
+		if len(vecs) < 32 {
+			t.Errorf("%v: got %v vectors", sz, len(vecs))
+		}
+		checkNullspace(t, m, vecs)
+	}
+}
@@ -0,0 +1,217 @@
+package gf2
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// Matrix is a sparse matrix over GF(2) stored by columns.
+// A column lists the row indices of its non-zero entries.
+type Matrix struct {
+	Rows int
+	Cols [][]int
+}
+
+// NewMatrix returns an empty matrix with the given number of rows.
+func NewMatrix(rows int) *Matrix {
+	return &Matrix{Rows: rows}
+}
+
+// AddColumn appends a column and returns its index.
+// The entries are row indices, a row occurring twice cancels.
+func (m *Matrix) AddColumn(rows []int) int {
+	m.Cols = append(m.Cols, normalize(rows))
+	return len(m.Cols) - 1
+}
+
+// normalize returns the sorted rows occurring an odd number of times.
+func normalize(rows []int) []int {
+	s := append([]int(nil), rows...)
+	sort.Ints(s)
+	k := 0
+	for i := 0; i < len(s); {
+		j := i + 1
+		for j < len(s) && s[j] == s[i] {
+			j++
+		}
+		if (j-i)%2 != 0 {
+			s[k] = s[i]
+			k++
+		}
+		i = j
+	}
+	return s[:k]
+}
+
+// IsZero reports whether the sum of the given columns is zero.
+func (m *Matrix) IsZero(cols []int) bool {
+	v := make([]uint64, (m.Rows+63)/64)
+	for _, c := range cols {
+		for _, r := range m.Cols[c] {
+			v[r/64] ^= 1 << uint(r%64)
+		}
+	}
+	for _, w := range v {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mulBlock returns m*x, where x holds 64 vectors of length len(m.Cols),
+// one bit for each vector.
+func (m *Matrix) mulBlock(x []uint64) []uint64 {
+	y := make([]uint64, m.Rows)
+	for j, c := range m.Cols {
+		if xj := x[j]; xj != 0 {
+			for _, r := range c {
+				y[r] ^= xj
+			}
+		}
+	}
+	return y
+}
+
+// mulTransBlock returns the transpose of m times y.
+func (m *Matrix) mulTransBlock(y []uint64) []uint64 {
+	x := make([]uint64, len(m.Cols))
+	for j, c := range m.Cols {
+		var s uint64
+		for _, r := range c {
+			s ^= y[r]
+		}
+		x[j] = s
+	}
+	return x
+}
+
+// Dense is a dense matrix over GF(2). The rows are packed into 64 bit words.
+type Dense struct {
+	rows, cols int
+	stride     int
+	bits       []uint64
+}
+
+// NewDense returns a zero matrix of the given size.
+func NewDense(rows, cols int) *Dense {
+	stride := (cols + 63) / 64
+	return &Dense{rows: rows, cols: cols, stride: stride, bits: make([]uint64, rows*stride)}
+}
+
+// Dims returns the number of rows and columns.
+func (d *Dense) Dims() (rows, cols int) {
+	return d.rows, d.cols
+}
+
+// Get returns the entry in row i and column j.
+func (d *Dense) Get(i, j int) bool {
+	return d.bits[i*d.stride+j/64]>>uint(j%64)&1 != 0
+}
+
+// Set sets the entry in row i and column j.
+func (d *Dense) Set(i, j int, v bool) {
+	w := &d.bits[i*d.stride+j/64]
+	b := uint64(1) << uint(j%64)
+	if v {
+		*w |= b
+	} else {
+		*w &^= b
+	}
+}
+
+// Flip adds 1 to the entry in row i and column j.
+func (d *Dense) Flip(i, j int) {
+	d.bits[i*d.stride+j/64] ^= 1 << uint(j%64)
+}
+
+// Row returns the packed words of row i. The slice shares the storage of d.
+func (d *Dense) Row(i int) []uint64 {
+	return d.bits[i*d.stride : (i+1)*d.stride]
+}
+
+// AddRow adds row src to row dst.
+func (d *Dense) AddRow(dst, src int) {
+	xorWords(d.Row(dst), d.Row(src))
+}
+
+// Weight returns the number of non-zero entries of row i.
+func (d *Dense) Weight(i int) int {
+	n := 0
+	for _, w := range d.Row(i) {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Dense returns m as a dense matrix.
+func (m *Matrix) Dense() *Dense {
+	d := NewDense(m.Rows, len(m.Cols))
+	for j, c := range m.Cols {
+		for _, r := range c {
+			d.Flip(r, j)
+		}
+	}
+	return d
+}
+
+// Nullspace returns a basis of the vectors x with d*x = 0.
+// A vector is given by the indices of its non-zero entries.
+// It reduces a copy of d to row echelon form by Gaussian elimination.
+func (d *Dense) Nullspace() [][]int {
+	e := &Dense{rows: d.rows, cols: d.cols, stride: d.stride, bits: append([]uint64(nil), d.bits...)}
+	// pivots[r] is the pivot column of row r
+	var pivots []int
+	isPivot := make([]bool, e.cols)
+	for c := 0; c < e.cols && len(pivots) < e.rows; c++ {
+		r := len(pivots)
+		w, b := c/64, uint64(1)<<uint(c%64)
+		pr := -1
+		for i := r; i < e.rows; i++ {
+			if e.bits[i*e.stride+w]&b != 0 {
+				pr = i
+				break
+			}
+		}
+		if pr < 0 {
+			continue
+		}
+		if pr != r {
+			rw, pw := e.Row(r), e.Row(pr)
+			for k := range rw {
+				rw[k], pw[k] = pw[k], rw[k]
+			}
+		}
+		pv := e.Row(r)[w:]
+		for i := 0; i < e.rows; i++ {
+			if i != r && e.bits[i*e.stride+w]&b != 0 {
+				xorWords(e.Row(i)[w:], pv)
+			}
+		}
+		pivots = append(pivots, c)
+		isPivot[c] = true
+	}
+	var basis [][]int
+	for f := 0; f < e.cols; f++ {
+		if isPivot[f] {
+			continue
+		}
+		// the reduced form gives the pivot variables in terms of the free variable f
+		v := []int{}
+		for r, c := range pivots {
+			if e.Get(r, f) {
+				v = append(v, c)
+			}
+		}
+		v = append(v, f)
+		sort.Ints(v)
+		basis = append(basis, v)
+	}
+	return basis
+}
+
+func xorWords(dst, src []uint64) {
+	for k, w := range src {
+		dst[k] ^= w
+	}
+}
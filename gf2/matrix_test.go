@@ -0,0 +1,104 @@
+package gf2
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randMatrix returns a matrix resembling relations of a sieve: the lower rows
+// (small primes) are denser than the higher ones.
+func randMatrix(rnd *rand.Rand, rows, cols, weight int) *Matrix {
+	m := NewMatrix(rows)
+	for j := 0; j < cols; j++ {
+		var c []int
+		for k := 0; k < weight; k++ {
+			f := rnd.Float64()
+			c = append(c, int(f*f*float64(rows)))
+		}
+		m.AddColumn(c)
+	}
+	return m
+}
+
+func checkNullspace(t *testing.T, m *Matrix, vecs [][]int) {
+	t.Helper()
+	for _, v := range vecs {
+		if len(v) == 0 {
+			t.Fatal("zero vector")
+		}
+		if !m.IsZero(v) {
+			t.Fatal("vector not in the nullspace", v)
+		}
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	m := NewMatrix(10)
+	m.AddColumn([]int{5, 3, 5, 5, 1, 3})
+	if got := m.Cols[0]; len(got) != 2 || got[0] != 1 || got[1] != 5 {
+		t.Errorf("got %v, want [1 5]", got)
+	}
+	if m.IsZero([]int{0}) {
+		t.Error("column is not zero")
+	}
+	m.AddColumn([]int{1})
+	m.AddColumn([]int{5})
+	if !m.IsZero([]int{0, 1, 2}) {
+		t.Error("sum is zero")
+	}
+}
+
+func TestDense(t *testing.T) {
+	d := NewDense(3, 100)
+	d.Set(1, 70, true)
+	d.Flip(1, 3)
+	d.Flip(2, 3)
+	if !d.Get(1, 70) || !d.Get(1, 3) || d.Get(0, 70) {
+		t.Error("wrong entries")
+	}
+	d.AddRow(2, 1)
+	if !d.Get(2, 70) || d.Get(2, 3) || d.Weight(2) != 1 {
+		t.Error("wrong row sum")
+	}
+	d.Set(1, 70, false)
+	if r, c := d.Dims(); d.Get(1, 70) || r != 3 || c != 100 {
+		t.Error("wrong entries")
+	}
+}
+
+func TestDenseNullspace(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, sz := range [][2]int{{1, 1}, {10, 5}, {50, 60}, {200, 250}} {
+		m := randMatrix(rnd, sz[0], sz[1], 6)
+		vecs := m.Dense().Nullspace()
+		checkNullspace(t, m, vecs)
+		// rank + nullity = columns
+		rank := 0
+		d := m.Dense()
+		r, c := d.Dims()
+		for col := 0; col < c && rank < r; col++ {
+			p := -1
+			for i := rank; i < r; i++ {
+				if d.Get(i, col) {
+					p = i
+					break
+				}
+			}
+			if p < 0 {
+				continue
+			}
+			if p != rank {
+				d.AddRow(rank, p)
+			}
+			for i := 0; i < r; i++ {
+				if i != rank && d.Get(i, col) {
+					d.AddRow(i, rank)
+				}
+			}
+			rank++
+		}
+		if rank+len(vecs) != sz[1] {
+			t.Errorf("%v: rank %v and nullity %v", sz, rank, len(vecs))
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package intfact
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// ecppMaxClass and ecppMaxDisc bound the class number and the absolute value of
+// the discriminants used by ECPP.
+const (
+	ecppMaxClass = 8
+	ecppMaxDisc  = 2000
+)
+
+// ecppDiscriminants are the fundamental discriminants with class number up to
+// ecppMaxClass and absolute value up to ecppMaxDisc, ordered by class number and
+// absolute value.
+var ecppDiscriminants = fundamentalDiscriminants(ecppMaxClass, ecppMaxDisc)
+
+// fundamentalDiscriminants returns the fundamental discriminants -maxDisc <= d < 0
+// with class number up to maxClass.
+func fundamentalDiscriminants(maxClass int, maxDisc int64) []int64 {
+	squarefree := func(m int64) bool {
+		for p := int64(2); p*p <= m; p++ {
+			if m%(p*p) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	var ds []int64
+	for m := int64(3); m <= maxDisc; m++ {
+		switch {
+		case m%4 == 3 && squarefree(m):
+		case m%4 == 0 && (m/4%4 == 1 || m/4%4 == 2) && squarefree(m/4):
+		default:
+			continue
+		}
+		if len(reducedForms(-m)) <= maxClass {
+			ds = append(ds, -m)
+		}
+	}
+	sort.SliceStable(ds, func(i, j int) bool {
+		return len(reducedForms(ds[i])) < len(reducedForms(ds[j]))
+	})
+	return ds
+}
+
+var hilbert struct {
+	sync.Mutex
+	polys map[int64][]*big.Int
+}
+
+// hilbertPoly returns the coefficients of the Hilbert class polynomial of the
+// discriminant d, lowest first. The polynomials are computed once.
+func hilbertPoly(d int64) []*big.Int {
+	hilbert.Lock()
+	defer hilbert.Unlock()
+	if p, ok := hilbert.polys[d]; ok {
+		return p
+	}
+	if hilbert.polys == nil {
+		hilbert.polys = make(map[int64][]*big.Int)
+	}
+	p := computeHilbertPoly(d)
+	hilbert.polys[d] = p
+	return p
+}
+
+// reducedForms returns the primitive reduced quadratic forms (a, b, c) of the
+// discriminant d < 0, i.e. b^2 - 4ac = d, |b| <= a <= c and b >= 0 if |b| = a or a = c.
+// Their number is the class number of d.
+func reducedForms(d int64) [][3]int64 {
+	var forms [][3]int64
+	for a := int64(1); 3*a*a <= -d; a++ {
+		for b := -a + 1; b <= a; b++ {
+			if (b*b-d)%(4*a) != 0 {
+				continue
+			}
+			c := (b*b - d) / (4 * a)
+			if c < a || a == c && b < 0 || gcd64(gcd64(a, abs64(b)), c) != 1 {
+				continue
+			}
+			forms = append(forms, [3]int64{a, b, c})
+		}
+	}
+	return forms
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// complexFloat is a complex number with big.Float parts.
+type complexFloat struct {
+	re, im *big.Float
+}
+
+func newComplexFloat(prec uint) complexFloat {
+	return complexFloat{new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)}
+}
+
+// mul returns z*w.
+func (z complexFloat) mul(w complexFloat) complexFloat {
+	prec := z.re.Prec()
+	r := newComplexFloat(prec)
+	t := new(big.Float).SetPrec(prec)
+	r.re.Mul(z.re, w.re)
+	r.re.Sub(r.re, t.Mul(z.im, w.im))
+	r.im.Mul(z.re, w.im)
+	r.im.Add(r.im, t.Mul(z.im, w.re))
+	return r
+}
+
+// quo returns z/w.
+func (z complexFloat) quo(w complexFloat) complexFloat {
+	prec := z.re.Prec()
+	norm := new(big.Float).SetPrec(prec).Mul(w.re, w.re)
+	norm.Add(norm, new(big.Float).SetPrec(prec).Mul(w.im, w.im))
+	conj := complexFloat{w.re, new(big.Float).Neg(w.im)}
+	r := z.mul(conj)
+	r.re.Quo(r.re, norm)
+	r.im.Quo(r.im, norm)
+	return r
+}
+
+// computeHilbertPoly computes the class polynomial as the product of x - j(τ)
+// over the reduced forms with τ = (-b + sqrt(d))/2a. The coefficients are integers,
+// so a floating point computation with enough precision gives them exactly.
+// |j(τ)| is about exp(π sqrt(-d)/a), which determines the precision.
+func computeHilbertPoly(d int64) []*big.Int {
+	forms := reducedForms(d)
+	prec := uint(128)
+	for _, f := range forms {
+		prec += uint(math.Pi*math.Sqrt(float64(-d))/float64(f[0])/math.Ln2) + 32
+	}
+	pi := bigPi(prec)
+	poly := []complexFloat{newComplexFloat(prec)}
+	poly[0].re.SetInt64(1)
+	for _, f := range forms {
+		a, b := f[0], f[1]
+		// q = exp(2πiτ) = exp(-π sqrt(-d)/a) exp(-πib/a)
+		e := new(big.Float).SetPrec(prec).SetInt64(-d)
+		e.Sqrt(e)
+		e.Mul(e, pi)
+		e.Quo(e, new(big.Float).SetInt64(a))
+		r := bigExp(e.Neg(e), prec)
+		theta := new(big.Float).SetPrec(prec).Mul(pi, new(big.Float).SetInt64(-b))
+		theta.Quo(theta, new(big.Float).SetInt64(a))
+		cos, sin := bigCosSin(theta, prec)
+		q := complexFloat{cos.Mul(cos, r), sin.Mul(sin, r)}
+		j := jInvariant(q, r, prec)
+		// multiply by x - j
+		next := make([]complexFloat, len(poly)+1)
+		for i := range next {
+			next[i] = newComplexFloat(prec)
+		}
+		for i, p := range poly {
+			next[i+1].re.Add(next[i+1].re, p.re)
+			next[i+1].im.Add(next[i+1].im, p.im)
+			pj := p.mul(j)
+			next[i].re.Sub(next[i].re, pj.re)
+			next[i].im.Sub(next[i].im, pj.im)
+		}
+		poly = next
+	}
+	coef := make([]*big.Int, len(poly))
+	half := big.NewFloat(0.5)
+	for i, p := range poly {
+		c := p.re
+		if c.Sign() < 0 {
+			c.Sub(c, half)
+		} else {
+			c.Add(c, half)
+		}
+		coef[i], _ = c.Int(nil)
+	}
+	return coef
+}
+
+// jInvariant returns j = E4^3/Δ for q with |q| = r < 1, where
+// E4 = 1 + 240 Σ σ3(n) q^n and Δ = q Π (1-q^n)^24.
+func jInvariant(q complexFloat, r *big.Float, prec uint) complexFloat {
+	eps := new(big.Float).SetMantExp(big.NewFloat(1), -int(prec))
+	e4 := newComplexFloat(prec)
+	e4.re.SetInt64(1)
+	delta := q
+	qn := q
+	rn := new(big.Float).SetPrec(prec).Set(r)
+	t := new(big.Float).SetPrec(prec)
+	for n := int64(1); rn.Cmp(eps) > 0; n++ {
+		var s3 int64
+		for k := int64(1); k <= n; k++ {
+			if n%k == 0 {
+				s3 += k * k * k
+			}
+		}
+		t.SetInt64(240 * s3)
+		e4.re.Add(e4.re, new(big.Float).SetPrec(prec).Mul(t, qn.re))
+		e4.im.Add(e4.im, new(big.Float).SetPrec(prec).Mul(t, qn.im))
+		// (1-q^n)^24 = ((1-q^n)^8)^3
+		f := newComplexFloat(prec)
+		f.re.Sub(big.NewFloat(1), qn.re)
+		f.im.Neg(qn.im)
+		f = f.mul(f)
+		f = f.mul(f)
+		f = f.mul(f)
+		delta = delta.mul(f.mul(f).mul(f))
+		qn = qn.mul(q)
+		rn.Mul(rn, r)
+	}
+	j := e4.mul(e4).mul(e4)
+	return j.quo(delta)
+}
+
+// bigPi returns π with Machin's formula π = 16 arctan(1/5) - 4 arctan(1/239).
+func bigPi(prec uint) *big.Float {
+	atanInv := func(x int64) *big.Float {
+		// Σ (-1)^k / ((2k+1) x^(2k+1))
+		eps := new(big.Float).SetMantExp(big.NewFloat(1), -int(prec)-8)
+		sum := new(big.Float).SetPrec(prec + 8)
+		pow := new(big.Float).SetPrec(prec+8).Quo(big.NewFloat(1), big.NewFloat(float64(x)))
+		x2 := new(big.Float).SetPrec(prec + 8).SetInt64(x * x)
+		t := new(big.Float).SetPrec(prec + 8)
+		for k := int64(0); pow.Cmp(eps) > 0; k++ {
+			t.Quo(pow, new(big.Float).SetInt64(2*k+1))
+			if k%2 == 0 {
+				sum.Add(sum, t)
+			} else {
+				sum.Sub(sum, t)
+			}
+			pow.Quo(pow, x2)
+		}
+		return sum
+	}
+	pi := atanInv(5)
+	pi.Mul(pi, big.NewFloat(16))
+	t := atanInv(239)
+	t.Mul(t, big.NewFloat(4))
+	return pi.Sub(pi, t).SetPrec(prec)
+}
+
+// bigExp returns e^x for x <= 0 with the power series of e^(x/2^s) and s squarings.
+func bigExp(x *big.Float, prec uint) *big.Float {
+	wp := prec + 64
+	y := new(big.Float).SetPrec(wp).Set(x)
+	s := 0
+	one := big.NewFloat(1)
+	for new(big.Float).Abs(y).Cmp(one) > 0 {
+		y.Quo(y, big.NewFloat(2))
+		s++
+	}
+	eps := new(big.Float).SetMantExp(one, -int(wp))
+	sum := new(big.Float).SetPrec(wp).SetInt64(1)
+	term := new(big.Float).SetPrec(wp).SetInt64(1)
+	for k := int64(1); new(big.Float).Abs(term).Cmp(eps) > 0; k++ {
+		term.Mul(term, y)
+		term.Quo(term, new(big.Float).SetInt64(k))
+		sum.Add(sum, term)
+	}
+	for ; s > 0; s-- {
+		sum.Mul(sum, sum)
+	}
+	return sum.SetPrec(prec)
+}
+
+// bigCosSin returns cos(x) and sin(x) for |x| <= 4 with their power series.
+func bigCosSin(x *big.Float, prec uint) (cos, sin *big.Float) {
+	wp := prec + 64
+	eps := new(big.Float).SetMantExp(big.NewFloat(1), -int(wp))
+	cos = new(big.Float).SetPrec(wp).SetInt64(1)
+	sin = new(big.Float).SetPrec(wp)
+	term := new(big.Float).SetPrec(wp).SetInt64(1)
+	for k := int64(1); k < 8 || new(big.Float).Abs(term).Cmp(eps) > 0; k++ {
+		term.Mul(term, x)
+		term.Quo(term, new(big.Float).SetInt64(k))
+		// term = x^k/k!
+		switch k % 4 {
+		case 0:
+			cos.Add(cos, term)
+		case 1:
+			sin.Add(sin, term)
+		case 2:
+			cos.Sub(cos, term)
+		case 3:
+			sin.Sub(sin, term)
+		}
+	}
+	return cos.SetPrec(prec), sin.SetPrec(prec)
+}
@@ -0,0 +1,319 @@
+package intfact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// Method is a factoring method that can be scheduled by Complete.
+type Method interface {
+	// Name returns a short description of the method and its parameters.
+	Name() string
+	// Cost returns an estimate of the work needed for one run on n, measured
+	// in multiplications modulo n. Methods that are not applicable to n return +Inf.
+	Cost(n *big.Int) float64
+	// Run tries to find a factor of n.
+	// It returns a factor if one was found or otherwise an error.
+	Run(ctx context.Context, n *big.Int) (*big.Int, error)
+}
+
+// RhoMethod runs Pollard's rho method with Brent's cycle detection for a limited number of iterations.
+type RhoMethod struct {
+	Iterations int
+}
+
+// Name implements Method.
+func (m RhoMethod) Name() string {
+	return fmt.Sprintf("rho(%d)", m.Iterations)
+}
+
+// Cost implements Method.
+func (m RhoMethod) Cost(*big.Int) float64 {
+	return 2 * float64(m.Iterations)
+}
+
+// Run implements Method.
+func (m RhoMethod) Run(ctx context.Context, n *big.Int) (*big.Int, error) {
+	return RhoWithOptions(ctx, n, &RhoOptions{Iterations: m.Iterations})
+}
+
+// PmOneMethod runs Pollard's p-1 method with the bounds B and B1.
+type PmOneMethod struct {
+	B, B1 uint32
+}
+
+// Name implements Method.
+func (m PmOneMethod) Name() string {
+	return fmt.Sprintf("p-1(%d, %d)", m.B, m.B1)
+}
+
+// Cost implements Method.
+func (m PmOneMethod) Cost(*big.Int) float64 {
+	return 1.5*float64(m.B) + math.Max(0, primeCount(m.B1)-primeCount(m.B))
+}
+
+// Run implements Method.
+func (m PmOneMethod) Run(ctx context.Context, n *big.Int) (*big.Int, error) {
+	return PmOne(ctx, n, m.B, m.B1)
+}
+
+// PpOneMethod runs Williams' p+1 method with the bounds B and B1 and the start value Seed.
+type PpOneMethod struct {
+	B, B1 uint32
+	Seed  *big.Int
+}
+
+// Name implements Method.
+func (m PpOneMethod) Name() string {
+	return fmt.Sprintf("p+1(%d, %d, %v)", m.B, m.B1, m.Seed)
+}
+
+// Cost implements Method.
+func (m PpOneMethod) Cost(*big.Int) float64 {
+	// a Lucas chain needs two multiplications per bit, there is no phase 2 if B1 <= B
+	return 3*float64(m.B) + 0.25*math.Max(0, float64(m.B1)-float64(m.B))
+}
+
+// Run implements Method.
+func (m PpOneMethod) Run(ctx context.Context, n *big.Int) (*big.Int, error) {
+	return PpOne(ctx, n, m.B, m.B1, m.Seed)
+}
+
+// EcMethod runs EcWithOptions on a single curve.
+type EcMethod struct {
+	Random  io.Reader
+	B, B1   uint32
+	Options *EcOptions
+}
+
+// Name implements Method.
+func (m EcMethod) Name() string {
+	return fmt.Sprintf("ecm(%d, %d)", m.B, m.B1)
+}
+
+// Cost implements Method.
+func (m EcMethod) Cost(*big.Int) float64 {
+	return curveCost(m.B, m.B1)
+}
+
+// Run implements Method.
+func (m EcMethod) Run(ctx context.Context, n *big.Int) (*big.Int, error) {
+	res, err := EcWithOptions(ctx, m.Random, n, m.B, m.B1, m.Options)
+	if err != nil {
+		return nil, err
+	}
+	return res.Factor, nil
+}
+
+// EcParallelMethod runs EcParallelWithOptions until Curves curves have been tried.
+// The number of curves is rounded up to a multiple of Parallel.
+// If the options contain a sigma, the curves use consecutive values starting there.
+type EcParallelMethod struct {
+	Random   io.Reader
+	B, B1    uint32
+	Parallel int
+	Curves   int
+	Options  *EcOptions
+}
+
+// Name implements Method.
+func (m EcParallelMethod) Name() string {
+	return fmt.Sprintf("ecm(%d, %d) x %d", m.B, m.B1, m.Curves)
+}
+
+// Cost implements Method.
+func (m EcParallelMethod) Cost(*big.Int) float64 {
+	return float64(m.Curves) * curveCost(m.B, m.B1)
+}
+
+// Run implements Method.
+func (m EcParallelMethod) Run(ctx context.Context, n *big.Int) (*big.Int, error) {
+	parallel := m.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	opts := m.Options
+	var err error
+	for c := 0; c < m.Curves || c == 0; c += parallel {
+		var res *EcResult
+		res, err = EcParallelWithOptions(ctx, m.Random, n, m.B, m.B1, parallel, opts)
+		if err == nil {
+			return res.Factor, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if opts != nil && opts.Sigma != nil {
+			o := *opts
+			o.Sigma = new(big.Int).Add(o.Sigma, big.NewInt(int64(parallel)))
+			opts = &o
+		}
+	}
+	return nil, err
+}
+
+// QSMethod runs QuadraticSieve with the given options.
+type QSMethod struct {
+	Options *QSOptions
+}
+
+// Name implements Method.
+func (m QSMethod) Name() string {
+	return "siqs"
+}
+
+// Cost implements Method.
+// The estimate is a multiple of L(n) = exp(sqrt(ln n ln ln n)) fitted to the running time.
+func (m QSMethod) Cost(n *big.Int) float64 {
+	if n.BitLen() < qsMinBits {
+		return math.Inf(1)
+	}
+	ln := bigLog(n)
+	return 6e-4 * math.Exp(math.Sqrt(ln*math.Log(ln)))
+}
+
+// Run implements Method.
+func (m QSMethod) Run(ctx context.Context, n *big.Int) (*big.Int, error) {
+	return QuadraticSieve(ctx, n, m.Options)
+}
+
+// NFSMethod runs NFS with the given options. It is not part of DefaultMethods.
+type NFSMethod struct {
+	Options *NFSOptions
+}
+
+// Name implements Method.
+func (m NFSMethod) Name() string {
+	return "nfs"
+}
+
+// Cost implements Method.
+// The estimate is a multiple of exp((64/9)^(1/3) (ln n)^(1/3) (ln ln n)^(2/3)) fitted to the running time.
+func (m NFSMethod) Cost(n *big.Int) float64 {
+	if n.BitLen() < nfsMinBits {
+		return math.Inf(1)
+	}
+	ln := bigLog(n)
+	return 2e-4 * math.Exp(math.Cbrt(64.0/9*ln)*math.Pow(math.Log(ln), 2.0/3))
+}
+
+// Run implements Method.
+func (m NFSMethod) Run(ctx context.Context, n *big.Int) (*big.Int, error) {
+	return NFS(ctx, n, m.Options)
+}
+
+// SNFSMethod runs SNFS with the given form and options. It is not part of DefaultMethods.
+// If Form is nil, the form is detected with DetectSNFSForm.
+type SNFSMethod struct {
+	Form    *SNFSForm
+	Options *NFSOptions
+}
+
+// Name implements Method.
+func (m SNFSMethod) Name() string {
+	return "snfs"
+}
+
+// Cost implements Method. The cost is infinite if n has no special form.
+// The estimate is a multiple of exp((32/9)^(1/3) (ln v)^(1/3) (ln ln v)^(2/3)) for the
+// value v of the form fitted to the running time.
+func (m SNFSMethod) Cost(n *big.Int) float64 {
+	if n.BitLen() < nfsMinBits {
+		return math.Inf(1)
+	}
+	form := m.Form
+	if form == nil {
+		f, ok := DetectSNFSForm(n)
+		if !ok {
+			return math.Inf(1)
+		}
+		form = &f
+	}
+	ln := bigLog(form.value())
+	return 4e-3 * math.Exp(math.Cbrt(32.0/9*ln)*math.Pow(math.Log(ln), 2.0/3))
+}
+
+// Run implements Method.
+func (m SNFSMethod) Run(ctx context.Context, n *big.Int) (*big.Int, error) {
+	return SNFS(ctx, n, m.Form, m.Options)
+}
+
+// curveCost estimates the cost of one curve in Ec.
+// A group operation is weighted as 8 multiplications.
+func curveCost(b, b1 uint32) float64 {
+	return 8 * (1.5*float64(b) + math.Max(0, primeCount(b1)-primeCount(b)))
+}
+
+// primeCount approximates the number of primes up to x.
+func primeCount(x uint32) float64 {
+	if x < 3 {
+		return 0
+	}
+	return float64(x) / math.Log(float64(x))
+}
+
+// DefaultMethods returns the methods used by Complete if no methods are configured.
+// They implement the escalation from cheap to expensive methods with growing bounds.
+// The elliptic curve method uses Montgomery curves. The quadratic sieve is scheduled
+// by its cost estimate between the levels, so that it takes over from the elliptic curve
+// method for balanced factors.
+func DefaultMethods(random io.Reader, parallel int) []Method {
+	ecOpts := &EcOptions{Model: Montgomery}
+	var ms []Method
+	for _, lv := range levels {
+		if lv.rho > 0 {
+			ms = append(ms, RhoMethod{Iterations: lv.rho})
+		}
+		ms = append(ms,
+			PmOneMethod{B: lv.pb, B1: lv.pb1},
+			PpOneMethod{B: lv.pb / 2, B1: lv.pb1 / 2, Seed: big.NewInt(7)},
+			EcParallelMethod{Random: random, B: lv.eb, B1: lv.eb1, Parallel: parallel, Curves: lv.curves, Options: ecOpts})
+	}
+	return append(ms, QSMethod{})
+}
+
+var registry struct {
+	mutex   sync.Mutex
+	methods []Method
+}
+
+// RegisterMethod adds m to the methods that Complete uses in addition to DefaultMethods.
+// It is safe to call RegisterMethod concurrently.
+func RegisterMethod(m Method) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.methods = append(registry.methods, m)
+}
+
+// RegisteredMethods returns a copy of the methods added with RegisterMethod.
+func RegisteredMethods() []Method {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return append([]Method(nil), registry.methods...)
+}
+
+// schedule returns the applicable methods from ms in order of increasing cost for n.
+func schedule(ms []Method, n *big.Int) []Method {
+	type entry struct {
+		m    Method
+		cost float64
+	}
+	var es []entry
+	for _, m := range ms {
+		c := m.Cost(n)
+		if !math.IsInf(c, 1) && !math.IsNaN(c) {
+			es = append(es, entry{m, c})
+		}
+	}
+	sort.SliceStable(es, func(i, j int) bool { return es[i].cost < es[j].cost })
+	r := make([]Method, len(es))
+	for i, e := range es {
+		r[i] = e.m
+	}
+	return r
+}
@@ -0,0 +1,157 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestMethods(t *testing.T) {
+	n := big.NewInt(43217358712783469)
+	tests := []Method{
+		RhoMethod{Iterations: 100000},
+		PmOneMethod{B: 1000, B1: 10000},
+		PpOneMethod{B: 1000, B1: 10000, Seed: big.NewInt(7)},
+		EcMethod{Random: &lcRandom{x: 10}, B: 2000, B1: 50000},
+		EcParallelMethod{Random: &lcRandom{x: 10}, B: 2000, B1: 50000, Parallel: 4, Curves: 20},
+		QSMethod{},
+	}
+	for _, m := range tests {
+		t.Run(m.Name(), func(t *testing.T) {
+			if c := m.Cost(n); c <= 0 || math.IsInf(c, 0) {
+				t.Errorf("invalid cost %v", c)
+			}
+			fac, err := m.Run(context.Background(), n)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !isProper(fac, n) || new(big.Int).Mod(n, fac).Sign() != 0 {
+				t.Error("invalid factor", fac)
+			}
+		})
+	}
+}
+
+func TestMethodCostSmallB1(t *testing.T) {
+	// without phase 2 the cost is that of phase 1
+	if c := (PpOneMethod{B: 1000, B1: 10}).Cost(nil); c != 3000 {
+		t.Errorf("got cost %v, want 3000", c)
+	}
+	if c := (PmOneMethod{B: 1000, B1: 10}).Cost(nil); c != 1500 {
+		t.Errorf("got cost %v, want 1500", c)
+	}
+	if c := (EcMethod{B: 1000, B1: 10}).Cost(nil); c != 12000 {
+		t.Errorf("got cost %v, want 12000", c)
+	}
+}
+
+func TestNFSMethods(t *testing.T) {
+	small := big.NewInt(43217358712783469)
+	n := SNFSForm{2, 128, 1}.value()
+	for _, m := range []Method{NFSMethod{}, SNFSMethod{}, SNFSMethod{Form: &SNFSForm{2, 128, 1}}} {
+		if c := m.Cost(small); !math.IsInf(c, 1) {
+			t.Errorf("%v: got cost %v for a small n", m.Name(), c)
+		}
+		if c := m.Cost(n); c <= 0 || math.IsInf(c, 0) {
+			t.Errorf("%v: invalid cost %v", m.Name(), c)
+		}
+	}
+	if c := (SNFSMethod{}).Cost(intval("84055899507848841139275608657")); !math.IsInf(c, 1) {
+		t.Errorf("got cost %v for a number without special form", c)
+	}
+	// the special form makes SNFS cheaper than NFS
+	if (SNFSMethod{}).Cost(n) >= (NFSMethod{}).Cost(n) {
+		t.Error("SNFS is not cheaper than NFS")
+	}
+	fac, err := SNFSMethod{}.Run(context.Background(), n)
+	if err != nil || !isProper(fac, n) || new(big.Int).Mod(n, fac).Sign() != 0 {
+		t.Errorf("got %v, %v", fac, err)
+	}
+}
+
+type fixedMethod struct {
+	cost  float64
+	fac   *big.Int
+	calls int
+}
+
+func (m *fixedMethod) Name() string { return "fixed" }
+
+func (m *fixedMethod) Cost(*big.Int) float64 { return m.cost }
+
+func (m *fixedMethod) Run(_ context.Context, n *big.Int) (*big.Int, error) {
+	m.calls++
+	if m.fac == nil || new(big.Int).Mod(n, m.fac).Sign() != 0 {
+		return nil, errors.New("no factor found")
+	}
+	return m.fac, nil
+}
+
+func TestSchedule(t *testing.T) {
+	a := &fixedMethod{cost: 3}
+	b := &fixedMethod{cost: 1}
+	c := &fixedMethod{cost: math.Inf(1)}
+	ms := schedule([]Method{a, b, c}, big.NewInt(10))
+	if len(ms) != 2 || ms[0] != b || ms[1] != a {
+		t.Errorf("wrong schedule %v", ms)
+	}
+}
+
+func TestCustomMethod(t *testing.T) {
+	p := intval("1000000000000000000000000000057")
+	q := intval("1000000000000000000000000000099")
+	n := new(big.Int).Mul(p, q)
+	m := &fixedMethod{cost: 1, fac: p}
+	l, err := Factor(context.Background(), n, &FactorOptions{Methods: []Method{m}})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if m.calls != 1 {
+		t.Errorf("method called %v times, want 1", m.calls)
+	}
+	if l.First.Fac.Cmp(p) != 0 || l.First.Next.Fac.Cmp(q) != 0 {
+		t.Error("wrong factorisation")
+	}
+}
+
+// wrongMethod returns a number that does not divide n.
+type wrongMethod struct{}
+
+func (wrongMethod) Name() string { return "wrong" }
+
+func (wrongMethod) Cost(*big.Int) float64 { return 0 }
+
+func (wrongMethod) Run(_ context.Context, n *big.Int) (*big.Int, error) {
+	return new(big.Int).Sub(n, bigOne), nil
+}
+
+func TestWrongMethod(t *testing.T) {
+	p := intval("1000000000000000000000000000057")
+	q := intval("1000000000000000000000000000099")
+	n := new(big.Int).Mul(p, q)
+	m := &fixedMethod{cost: 1, fac: p}
+	l, err := Factor(context.Background(), n, &FactorOptions{Methods: []Method{wrongMethod{}, m}})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if err := l.Verify(n); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisterMethod(t *testing.T) {
+	m := &fixedMethod{cost: 1}
+	before := len(RegisteredMethods())
+	t.Cleanup(func() {
+		registry.mutex.Lock()
+		registry.methods = registry.methods[:before]
+		registry.mutex.Unlock()
+	})
+	RegisterMethod(m)
+	ms := RegisteredMethods()
+	if len(ms) != before+1 || ms[len(ms)-1] != m {
+		t.Error("method not registered")
+	}
+}
@@ -0,0 +1,328 @@
+package intfact
+
+import (
+	"context"
+	"github.com/ghhenry/primes"
+	"math/big"
+	"math/bits"
+)
+
+// fitsUint64 reports whether n is odd, larger than 1 and below 2^64, so that
+// it can use the Montgomery arithmetic of mont64.
+func fitsUint64(n *big.Int) bool {
+	return n.BitLen() > 1 && n.BitLen() <= 64 && n.Bit(0) == 1
+}
+
+// mont64 is the Montgomery arithmetic modulo an odd n < 2^64 with R = 2^64.
+// Values are kept in Montgomery form aR mod n, which does not change gcds with n.
+type mont64 struct {
+	n    uint64
+	ninv uint64 // -1/n mod R
+	one  uint64 // R mod n
+	r2   uint64 // R^2 mod n
+}
+
+func newMont64(n uint64) *mont64 {
+	// Newton iteration for 1/n mod 2^64, n is its own inverse mod 8
+	inv := n
+	for i := 0; i < 5; i++ {
+		inv *= 2 - n*inv
+	}
+	one := -n % n
+	return &mont64{n: n, ninv: -inv, one: one, r2: mulMod64(one, one, n)}
+}
+
+// redc returns (hi*R + lo)/R mod n for hi < n.
+func (m *mont64) redc(hi, lo uint64) uint64 {
+	q := lo * m.ninv
+	qhi, qlo := bits.Mul64(q, m.n)
+	_, c := bits.Add64(lo, qlo, 0)
+	t, c := bits.Add64(hi, qhi, c)
+	if c != 0 || t >= m.n {
+		t -= m.n
+	}
+	return t
+}
+
+func (m *mont64) mul(a, b uint64) uint64 {
+	return m.redc(bits.Mul64(a, b))
+}
+
+// to converts a to Montgomery form.
+func (m *mont64) to(a uint64) uint64 {
+	return m.mul(a%m.n, m.r2)
+}
+
+// from converts a from Montgomery form.
+func (m *mont64) from(a uint64) uint64 {
+	return m.redc(0, a)
+}
+
+func (m *mont64) add(a, b uint64) uint64 {
+	s, c := bits.Add64(a, b, 0)
+	if c != 0 || s >= m.n {
+		s -= m.n
+	}
+	return s
+}
+
+func (m *mont64) sub(a, b uint64) uint64 {
+	if a >= b {
+		return a - b
+	}
+	return a - b + m.n
+}
+
+// exp returns a^e for a in Montgomery form.
+func (m *mont64) exp(a, e uint64) uint64 {
+	x := m.one
+	for ; e > 0; e >>= 1 {
+		if e&1 != 0 {
+			x = m.mul(x, a)
+		}
+		a = m.mul(a, a)
+	}
+	return x
+}
+
+// sprp reports whether n is a strong probable prime to the base a, for n > a.
+func (m *mont64) sprp(a uint64) bool {
+	n := m.n
+	d := n - 1
+	e := bits.TrailingZeros64(d)
+	x := m.exp(m.to(a), d>>uint(e))
+	minus := n - m.one
+	if x == m.one || x == minus {
+		return true
+	}
+	for i := 1; i < e; i++ {
+		x = m.mul(x, x)
+		if x == minus {
+			return true
+		}
+	}
+	return false
+}
+
+// gcd returns the gcd of a and n if it is a proper factor, nil if it is 1
+// or ErrTrivialGCD if it is n.
+func (m *mont64) gcd(a uint64) (*big.Int, error) {
+	switch g := gcdU64(a, m.n); g {
+	case 1:
+		return nil, nil
+	case m.n:
+		return nil, ErrTrivialGCD
+	default:
+		return new(big.Int).SetUint64(g), nil
+	}
+}
+
+// rhoUint64 is RhoWithOptions for an odd n < 2^64. It computes the same sequence
+// with Montgomery arithmetic and finds the same factors.
+func rhoUint64(ctx context.Context, n uint64, o RhoOptions) (fac *big.Int, err error) {
+	m := newMont64(n)
+	c := m.to(new(big.Int).Mod(o.C, new(big.Int).SetUint64(n)).Uint64())
+	x0 := m.to(new(big.Int).Mod(o.X0, new(big.Int).SetUint64(n)).Uint64())
+	f := func(x uint64) uint64 {
+		return m.add(m.mul(x, x), c)
+	}
+	limit := o.Iterations
+	for restarts := 0; ; restarts++ {
+		var s rho64State
+		var step func(*rho64State)
+		if o.Floyd {
+			s.a = f(x0)
+			s.b = f(s.a)
+			step = func(s *rho64State) {
+				s.a = f(s.a)
+				s.b = f(f(s.b))
+			}
+		} else {
+			s.a = x0
+			s.b = f(s.a)
+			s.r = 1
+			s.k = 1
+			step = func(s *rho64State) {
+				if s.k == s.r {
+					s.a = s.b
+					s.r *= 2
+					s.k = 0
+				}
+				s.b = f(s.b)
+				s.k++
+			}
+		}
+		var steps int
+		fac, steps, err = rho64Run(ctx, m, s, step, o.Batch, limit)
+		if err != ErrTrivialGCD || o.NoRestart || restarts == rhoRestarts {
+			return
+		}
+		if o.Iterations > 0 {
+			limit -= steps
+			if limit <= 0 {
+				return nil, ErrNoFactor
+			}
+		}
+		// c = 0 and c = -2 give degenerate sequences
+		mtwo := m.sub(0, m.add(m.one, m.one))
+		for {
+			c = m.add(c, m.one)
+			if c != 0 && c != mtwo {
+				break
+			}
+		}
+	}
+}
+
+// rho64State is rhoState with values in Montgomery form.
+type rho64State struct {
+	a, b uint64
+	r, k int
+}
+
+func (s *rho64State) diff() uint64 {
+	if s.b > s.a {
+		return s.b - s.a
+	}
+	return s.a - s.b
+}
+
+// rho64Run is rhoRun for the Montgomery arithmetic.
+func rho64Run(ctx context.Context, m *mont64, s rho64State, step func(*rho64State), batch, limit int) (fac *big.Int, steps int, err error) {
+	saved := s
+	acc := m.one
+	it := 0
+	for ; limit == 0 || steps < limit; steps++ {
+		if it == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, steps, cancelled(ctx)
+			default:
+			}
+			saved = s
+		}
+		acc = m.mul(acc, s.diff())
+		if it++; it >= batch {
+			fac, err = m.gcd(acc)
+			if err == ErrTrivialGCD {
+				fac, err = rho64Backtrack(m, saved, step)
+				return
+			}
+			if fac != nil {
+				return
+			}
+			it = 0
+			acc = m.one
+		}
+		step(&s)
+	}
+	fac, err = m.gcd(acc)
+	if err == ErrTrivialGCD {
+		fac, err = rho64Backtrack(m, saved, step)
+		return
+	}
+	if fac == nil && err == nil {
+		err = ErrNoFactor
+	}
+	return
+}
+
+// rho64Backtrack is rhoBacktrack for the Montgomery arithmetic.
+func rho64Backtrack(m *mont64, s rho64State, step func(*rho64State)) (*big.Int, error) {
+	for {
+		if fac, err := m.gcd(s.diff()); fac != nil || err != nil {
+			return fac, err
+		}
+		step(&s)
+	}
+}
+
+// pmOneUint64 is PmOne for an odd n < 2^64 with Montgomery arithmetic.
+// Phase1 is the same as for big numbers, phase2 steps from prime to prime
+// with a table of the powers for the prime gaps.
+func pmOneUint64(ctx context.Context, n uint64, b uint32, b2 uint64) (fac *big.Int, err error) {
+	m := newMont64(n)
+	a := m.to(3)
+	acc := m.one
+	it := 0
+	phase1 := func(p uint32) bool {
+		select {
+		case <-ctx.Done():
+			err = cancelled(ctx)
+			return true
+		default:
+		}
+		exp := uint64(p)
+		for exp*uint64(p) <= uint64(b) {
+			exp *= uint64(p)
+		}
+		a = m.exp(a, exp)
+		acc = m.mul(acc, m.sub(a, m.one))
+		if it++; it >= 20 {
+			fac, err = m.gcd(acc)
+			it = 0
+			acc = m.one
+		}
+		return fac != nil || err != nil
+	}
+	primes.Iterate(2, b, phase1)
+	if fac != nil || err != nil {
+		return
+	}
+	if fac, err = m.gcd(acc); fac != nil || err != nil {
+		return
+	}
+	// a is not invertible if n has a factor 3
+	if fac, err = m.gcd(m.from(a)); fac != nil || err != nil {
+		return
+	}
+
+	// phase2
+	if b2 <= uint64(b) {
+		return nil, ErrNoFactor
+	}
+	// gaps[i] = a^(2i)
+	gaps := []uint64{m.one}
+	a2 := m.mul(a, a)
+	var q0 uint32
+	var aq uint64
+	acc = m.one
+	it = 0
+	phase2 := func(q uint32) bool {
+		if q0 == 0 || q-q0 > 1000 {
+			aq = m.exp(a, uint64(q))
+		} else {
+			g := int((q - q0) / 2)
+			for len(gaps) <= g {
+				gaps = append(gaps, m.mul(gaps[len(gaps)-1], a2))
+			}
+			aq = m.mul(aq, gaps[g])
+		}
+		q0 = q
+		acc = m.mul(acc, m.sub(aq, m.one))
+		if it++; it >= 1000 {
+			select {
+			case <-ctx.Done():
+				err = cancelled(ctx)
+				return true
+			default:
+			}
+			fac, err = m.gcd(acc)
+			it = 0
+			acc = m.one
+		}
+		return fac != nil || err != nil
+	}
+	lo := b + 1
+	if lo < 3 {
+		lo = 3
+	}
+	primes.Iterate(lo, uint32(b2), phase2)
+	if fac != nil || err != nil {
+		return
+	}
+	if fac, err = m.gcd(acc); fac != nil || err != nil {
+		return
+	}
+	return nil, ErrNoFactor
+}
@@ -0,0 +1,135 @@
+package intfact
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func TestMont64(t *testing.T) {
+	for _, n := range []uint64{3, 101, 1000003 * 1000033, 1<<63 + 29, 18446744073709551557, 1<<64 - 1} {
+		m := newMont64(n)
+		x := uint64(12345)
+		for i := 0; i < 1000; i++ {
+			x = x*6364136223846793005 + 1442695040888963407
+			a, b := x%n, (x>>17)%n
+			if got := m.from(m.to(a)); got != a {
+				t.Fatalf("n=%v: from(to(%v)) = %v", n, a, got)
+			}
+			if got, want := m.from(m.mul(m.to(a), m.to(b))), mulMod64(a, b, n); got != want {
+				t.Fatalf("n=%v: %v*%v = %v, want %v", n, a, b, got, want)
+			}
+			if got, want := m.from(m.add(m.to(a), m.to(b))), (a%n+b%n)%n; a+b >= a && got != want {
+				t.Fatalf("n=%v: %v+%v = %v, want %v", n, a, b, got, want)
+			}
+			if got, want := m.from(m.sub(m.to(a), m.to(b))), a-b; a >= b && got != want {
+				t.Fatalf("n=%v: %v-%v = %v, want %v", n, a, b, got, want)
+			}
+		}
+		e := uint64(1000001)
+		want := new(big.Int).Exp(big.NewInt(7), new(big.Int).SetUint64(e), new(big.Int).SetUint64(n)).Uint64()
+		if got := m.from(m.exp(m.to(7), e)); got != want {
+			t.Errorf("n=%v: 7^%v = %v, want %v", n, e, got, want)
+		}
+	}
+}
+
+func TestRhoUint64(t *testing.T) {
+	// the uint64 version computes the same sequence as the big.Int version
+	ns := []uint64{149 * 181, 1000003 * 1000033, 43217358712783469, 65537 * 4294967291, 4294967291 * 4294967279}
+	opts := []*RhoOptions{
+		nil,
+		{Floyd: true},
+		{X0: big.NewInt(7), C: big.NewInt(-3), Batch: 10},
+		{Iterations: 100},
+		{NoRestart: true},
+		{Floyd: true, NoRestart: true},
+	}
+	for _, n := range ns {
+		for _, opt := range opts {
+			o := opt.withDefaults()
+			bn := new(big.Int).SetUint64(n)
+			fac, err := rhoUint64(context.Background(), n, o)
+			want, wantErr := rhoBig(context.Background(), bn, o)
+			if err != wantErr || (fac == nil) != (want == nil) || fac != nil && fac.Cmp(want) != 0 {
+				t.Errorf("n=%v %+v: got %v, %v, want %v, %v", n, opt, fac, err, want, wantErr)
+			}
+		}
+	}
+}
+
+func TestPmOneUint64(t *testing.T) {
+	tests := []struct {
+		n     uint64
+		b, b1 uint32
+		want  uint64
+		err   error
+	}{
+		// 11-1 = 2*5
+		{11 * 3803, 2, 100, 11, nil},
+		// 3607-1 = 2*3*601
+		{3607 * 3803, 10, 700, 3607, nil},
+		{3607 * 3803, 10, 100, 0, ErrNoFactor},
+		{41 * 43, 1000, 1000, 0, ErrTrivialGCD},
+		// 7420146347-1 = 2*503*853*8647, 5824327-1 = 2*3*970721
+		{43217358712783469, 900, 100000, 7420146347, nil},
+		{43217358712783469, 10000, 0, 7420146347, nil},
+		{3 * 1000003, 10, 100, 3, nil},
+	}
+	for _, tt := range tests {
+		fac, err := pmOneUint64(context.Background(), tt.n, tt.b, uint64(tt.b1))
+		if err != tt.err || tt.err == nil && fac.Uint64() != tt.want {
+			t.Errorf("%v: got %v, %v, want %v, %v", tt.n, fac, err, tt.want, tt.err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pmOneUint64(ctx, 4294967291*4294967279, 100000, 1000000); err == nil || err == ErrNoFactor {
+		t.Errorf("got %v, want ErrCancelled", err)
+	}
+}
+
+// the product of two primes of 32 bits, p-1 finds no factor
+const benchN64 = 4294967291 * 4294967279
+
+func BenchmarkRhoUint64(b *testing.B) {
+	o := (*RhoOptions)(nil).withDefaults()
+	for i := 0; i < b.N; i++ {
+		_, _ = rhoUint64(context.Background(), benchN64, o)
+	}
+}
+
+func BenchmarkRhoBig(b *testing.B) {
+	o := (*RhoOptions)(nil).withDefaults()
+	n := new(big.Int).SetUint64(benchN64)
+	for i := 0; i < b.N; i++ {
+		_, _ = rhoBig(context.Background(), n, o)
+	}
+}
+
+func BenchmarkPmOneUint64(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, _ = pmOneUint64(context.Background(), benchN64, 10000, 100000)
+	}
+}
+
+func BenchmarkPmOneBig(b *testing.B) {
+	o, _ := (*PmOneOptions)(nil).withDefaults()
+	n := new(big.Int).SetUint64(benchN64)
+	for i := 0; i < b.N; i++ {
+		_, _ = pmOneBig(context.Background(), n, 10000, 100000, o)
+	}
+}
+
+func BenchmarkIsPrime64(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		isPrime64(18446744073709551557)
+	}
+}
+
+func BenchmarkProbablyPrime64(b *testing.B) {
+	n := new(big.Int).SetUint64(18446744073709551557)
+	for i := 0; i < b.N; i++ {
+		n.ProbablyPrime(12)
+	}
+}
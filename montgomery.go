@@ -0,0 +1,256 @@
+package intfact
+
+import (
+	"context"
+	"github.com/ghhenry/primes"
+	"math/big"
+	"math/bits"
+)
+
+// mcurve is a Montgomery curve By^2 = x^3 + Ax^2 + x modulo n.
+// The arithmetic only uses XZ coordinates, so B is not needed and
+// A is given as a24 = (A+2)/4.
+// The temporaries t make the arithmetic free of allocations, so a curve
+// must not be used concurrently.
+type mcurve struct {
+	n   *big.Int
+	a24 *big.Int
+	t   [4]big.Int
+}
+
+// xzPoint is a point in XZ coordinates, the point at infinity has z = 0.
+// A point and its negative have the same representation.
+type xzPoint struct {
+	x, z *big.Int
+}
+
+func newXZ() xzPoint {
+	return xzPoint{new(big.Int), new(big.Int)}
+}
+
+func (p xzPoint) set(q xzPoint) {
+	p.x.Set(q.x)
+	p.z.Set(q.z)
+}
+
+// suyamaCurve returns the curve and start point for the parameter sigma with Suyama's parametrization
+// as used by GMP-ECM. The group order of the curve modulo a prime is divisible by 12.
+// If the parametrization needs an inverse that does not exist modulo n, a FactorFoundError is returned.
+func suyamaCurve(sigma, n *big.Int) (*mcurve, xzPoint, error) {
+	// u = sigma^2-5, v = 4*sigma
+	u := new(big.Int).Mul(sigma, sigma)
+	u.Sub(u, big.NewInt(5))
+	u.Mod(u, n)
+	v := new(big.Int).Lsh(sigma, 2)
+	v.Mod(v, n)
+	// x0 = u^3, z0 = v^3
+	x0 := new(big.Int).Exp(u, bigThree, n)
+	z0 := new(big.Int).Exp(v, bigThree, n)
+	// a24 = (v-u)^3 (3u+v) / (16 u^3 v)
+	t0 := new(big.Int).Sub(v, u)
+	t0.Exp(t0, bigThree, n)
+	t1 := new(big.Int).Mul(u, bigThree)
+	t1.Add(t1, v)
+	t0.Mul(t0, t1)
+	t0.Mod(t0, n)
+	t1.Lsh(x0, 4)
+	t1.Mul(t1, v)
+	t1, err := inverse(t1, n)
+	if err != nil {
+		return nil, xzPoint{}, err
+	}
+	t0.Mul(t0, t1)
+	t0.Mod(t0, n)
+	return &mcurve{n: n, a24: t0}, xzPoint{x0, z0}, nil
+}
+
+// inverse returns the inverse of a modulo n or a FactorFoundError if it does not exist.
+func inverse(a, n *big.Int) (*big.Int, error) {
+	inv := new(big.Int)
+	d := new(big.Int).GCD(inv, nil, new(big.Int).Mod(a, n), n)
+	if d.Cmp(bigOne) != 0 {
+		return nil, FactorFoundError{d}
+	}
+	return inv.Mod(inv, n), nil
+}
+
+// weierstrass returns the curve in short Weierstrass form and the point on it that corresponds to p.
+// Since p has no y-coordinate, the curve is chosen such that y = 1, i.e. B = x^3 + Ax^2 + x.
+// This is the curve or its quadratic twist on which the XZ arithmetic computes.
+// The curve is v^2 = u^3 + au + b with a = (3-A^2)/(3B^2), b = (2A^3-9A)/(27B^3)
+// and the point is u = (3x+A)/(3B), v = 1/B.
+func (c *mcurve) weierstrass(p xzPoint) (*curve, point, error) {
+	n := c.n
+	x, err := inverse(p.z, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	x.Mul(x, p.x)
+	x.Mod(x, n)
+	// A = 4a24 - 2
+	a := new(big.Int).Lsh(c.a24, 2)
+	a.Sub(a, bigTwo)
+	a.Mod(a, n)
+	// B = x(x(x+A)+1)
+	bb := new(big.Int).Add(x, a)
+	bb.Mul(bb, x)
+	bb.Add(bb, bigOne)
+	bb.Mul(bb, x)
+	bb.Mod(bb, n)
+	// i = 1/(3B)
+	i, err := inverse(new(big.Int).Mul(bb, bigThree), n)
+	if err != nil {
+		return nil, nil, err
+	}
+	// v = 1/B = 3i
+	v := new(big.Int).Mul(i, bigThree)
+	v.Mod(v, n)
+	u := new(big.Int).Mul(x, bigThree)
+	u.Add(u, a)
+	u.Mul(u, i)
+	u.Mod(u, n)
+	a2 := new(big.Int).Mul(a, a)
+	// wa = (3-A^2) i v
+	wa := new(big.Int).Sub(bigThree, a2)
+	wa.Mul(wa, i)
+	wa.Mod(wa, n)
+	wa.Mul(wa, v)
+	wa.Mod(wa, n)
+	// wb = (2A^3-9A) i^3
+	wb := new(big.Int).Add(a2, a2)
+	wb.Sub(wb, big.NewInt(9))
+	wb.Mul(wb, a)
+	wb.Mod(wb, n)
+	i3 := new(big.Int).Exp(i, bigThree, n)
+	wb.Mul(wb, i3)
+	wb.Mod(wb, n)
+	return &curve{n, wa, wb}, ordinary{u, v}, nil
+}
+
+// double computes 2p.
+func (c *mcurve) double(p xzPoint) xzPoint {
+	r := newXZ()
+	c.doubleTo(r, p)
+	return r
+}
+
+// doubleTo sets r = 2p, r may be p.
+func (c *mcurve) doubleTo(r, p xzPoint) {
+	s, d, t := &c.t[0], &c.t[1], &c.t[2]
+	s.Add(p.x, p.z)
+	s.Mul(s, s)
+	s.Mod(s, c.n)
+	d.Sub(p.x, p.z)
+	d.Mul(d, d)
+	d.Mod(d, c.n)
+	// t = 4xz
+	t.Sub(s, d)
+	r.x.Mul(s, d)
+	r.x.Mod(r.x, c.n)
+	r.z.Mul(c.a24, t)
+	r.z.Add(r.z, d)
+	r.z.Mul(r.z, t)
+	r.z.Mod(r.z, c.n)
+}
+
+// add computes p+q given diff = p-q.
+func (c *mcurve) add(p, q, diff xzPoint) xzPoint {
+	r := newXZ()
+	c.addTo(r, p, q, diff)
+	return r
+}
+
+// addTo sets r = p+q given diff = p-q. r may be any of the other points.
+func (c *mcurve) addTo(r, p, q, diff xzPoint) {
+	u, v, t := &c.t[0], &c.t[1], &c.t[2]
+	u.Sub(p.x, p.z)
+	t.Add(q.x, q.z)
+	u.Mul(u, t)
+	v.Add(p.x, p.z)
+	t.Sub(q.x, q.z)
+	v.Mul(v, t)
+	t.Add(u, v)
+	t.Mul(t, t)
+	t.Mod(t, c.n)
+	v.Sub(u, v)
+	v.Mul(v, v)
+	v.Mod(v, c.n)
+	u.Mul(t, diff.z)
+	v.Mul(v, diff.x)
+	r.x.Mod(u, c.n)
+	r.z.Mod(v, c.n)
+}
+
+// mult computes m*p with the Montgomery ladder.
+func (c *mcurve) mult(p xzPoint, m uint64) xzPoint {
+	if m == 0 {
+		return xzPoint{big.NewInt(1), big.NewInt(0)}
+	}
+	// invariant: r1 - r0 = p
+	r0 := newXZ()
+	r0.set(p)
+	r1 := c.double(p)
+	for i := bits.Len64(m) - 2; i >= 0; i-- {
+		if m>>uint(i)&1 == 1 {
+			c.addTo(r0, r1, r0, p)
+			c.doubleTo(r1, r1)
+		} else {
+			c.addTo(r1, r1, r0, p)
+			c.doubleTo(r0, r0)
+		}
+	}
+	return r0
+}
+
+// ecMontgomery runs the elliptic curve method on the Montgomery curve for sigma.
+// See EcWithOptions for the parameters and the return values.
+func ecMontgomery(ctx context.Context, sigma, n *big.Int, b, b1, d uint32) (res *EcResult, err error) {
+	c, pt, err := suyamaCurve(sigma, n)
+	if err != nil {
+		return stageResult(err, n, sigma, 1, 0)
+	}
+	gcd := newGcdtest(n, 20)
+	var last uint32
+	phase1 := func(p uint32) bool {
+		select {
+		case <-ctx.Done():
+			err = cancelled(ctx)
+			return true
+		default:
+		}
+		mult := uint64(p)
+		for {
+			ne := mult * uint64(p)
+			if ne > uint64(b) {
+				break
+			}
+			mult = ne
+		}
+		pt = c.mult(pt, mult)
+		last = p
+		var fac *big.Int
+		fac, err = gcd.test(pt.z)
+		if fac != nil {
+			res = &EcResult{Factor: fac, Sigma: sigma, Stage: 1, Prime: p}
+		}
+		return res != nil || err != nil
+	}
+	primes.Iterate(2, b, phase1)
+	if res != nil || err != nil {
+		return
+	}
+
+	// phase2 continues on the curve in Weierstrass form
+	fac, err := gcd.finish()
+	if err != nil {
+		return nil, err
+	}
+	if fac != nil {
+		return &EcResult{Factor: fac, Sigma: sigma, Stage: 1, Prime: last}, nil
+	}
+	wc, wpt, err := c.weierstrass(pt)
+	if err != nil {
+		return stageResult(err, n, sigma, 1, last)
+	}
+	return ecPhase2(ctx, wc, wpt, sigma, b, b1, d)
+}
@@ -0,0 +1,110 @@
+package intfact
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func xzEqual(c *mcurve, p, q xzPoint) bool {
+	l := new(big.Int).Mul(p.x, q.z)
+	l.Mod(l, c.n)
+	r := new(big.Int).Mul(q.x, p.z)
+	r.Mod(r, c.n)
+	return l.Cmp(r) == 0
+}
+
+func TestSuyamaCurve(t *testing.T) {
+	n := big.NewInt(1000003)
+	c, p, err := suyamaCurve(big.NewInt(11), n)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	// u = 116, v = 44
+	if p.x.Int64() != 560893 || p.z.Int64() != 85184 {
+		t.Errorf("got start point (%v, %v), want (560893, 85184)", p.x, p.z)
+	}
+	if c.a24.Int64() != 229739 {
+		t.Errorf("got a24 %v, want 229739", c.a24)
+	}
+	// 16u^3v is not invertible modulo 29*101
+	_, _, err = suyamaCurve(big.NewInt(11), big.NewInt(29*101))
+	if e, ok := err.(FactorFoundError); !ok || e.Factor.Int64() != 29 {
+		t.Errorf("got %v, want a FactorFoundError", err)
+	}
+}
+
+func TestMcurveArithmetic(t *testing.T) {
+	n := big.NewInt(1000003)
+	c, p, err := suyamaCurve(big.NewInt(17), n)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	for m := uint64(2); m < 50; m++ {
+		prev := c.mult(p, m-1)
+		cur := c.mult(p, m)
+		next := c.add(cur, p, prev)
+		if !xzEqual(c, next, c.mult(p, m+1)) {
+			t.Errorf("add and mult differ for %v", m+1)
+		}
+		if !xzEqual(c, c.double(cur), c.mult(p, 2*m)) {
+			t.Errorf("double and mult differ for %v", 2*m)
+		}
+		if !xzEqual(c, c.mult(cur, 7), c.mult(c.mult(p, 7), m)) {
+			t.Errorf("mult is not associative for %v", m)
+		}
+	}
+}
+
+func TestMcurveWeierstrass(t *testing.T) {
+	n := big.NewInt(1000003)
+	c, p, err := suyamaCurve(big.NewInt(17), n)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	p = c.mult(p, 5)
+	wc, wp, err := c.weierstrass(p)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !onCurve(wc, wp) {
+		t.Fatal("point is not on the curve")
+	}
+	// u = (3x+A)/(3B), so x = Bu - A/3
+	x, _ := inverse(p.z, n)
+	x.Mul(x, p.x)
+	a := new(big.Int).Lsh(c.a24, 2)
+	a.Sub(a, bigTwo)
+	bb := new(big.Int).Add(x, a)
+	bb.Mul(bb, x)
+	bb.Add(bb, bigOne)
+	bb.Mul(bb, x)
+	i3, _ := inverse(bigThree, n)
+	for m := int64(2); m < 30; m++ {
+		q, err := wc.mult(wp, big.NewInt(m))
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		got := new(big.Int).Mul(bb, q.x())
+		got.Sub(got, new(big.Int).Mul(a, i3))
+		got.Mod(got, n)
+		mq := c.mult(p, uint64(m))
+		want, _ := inverse(mq.z, n)
+		want.Mul(want, mq.x)
+		want.Mod(want, n)
+		if got.Cmp(want) != 0 {
+			t.Errorf("multiple %v: got x %v, want %v", m, got, want)
+		}
+	}
+}
+
+func TestEcMontgomery(t *testing.T) {
+	n := big.NewInt(43217358712783469)
+	res, err := EcWithOptions(context.Background(), &lcRandom{x: 10}, n, 2000, 50000, &EcOptions{Model: Montgomery})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if new(big.Int).Mod(n, res.Factor).Sign() != 0 {
+		t.Error("factor does not divide n:", res.Factor)
+	}
+}
@@ -0,0 +1,489 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"github.com/ghhenry/intfact/gf2"
+	"github.com/ghhenry/primes"
+	"math"
+	"math/big"
+)
+
+// NFSOptions contains the parameters of NFS.
+// A nil pointer or zero fields select defaults that depend on the size of n.
+type NFSOptions struct {
+	// Poly is the polynomial pair. By default it is chosen by a search over base m expansions of n.
+	Poly *NFSPoly
+	// Degree is the degree of the algebraic polynomial for the polynomial selection.
+	Degree int
+	// RationalBound and AlgebraicBound are the bounds of the factor bases.
+	RationalBound, AlgebraicBound uint32
+	// LargePrimeBits bounds the large primes to 2^LargePrimeBits. It is at most 31.
+	LargePrimeBits int
+	// LargePrimes is the number of large primes allowed on each side, at most 2.
+	// By default it is 1 below 75 digits and 2 above. A negative value disables the
+	// large prime variation.
+	LargePrimes int
+	// LogI is the binary logarithm of the width of the sieve region of a special-q.
+	// The height is half the width.
+	LogI int
+	// QStart is the first special-q (default AlgebraicBound).
+	QStart uint32
+	// RelationFile is the name of a file collecting the relations. If the file exists,
+	// the polynomial pair and the relations are loaded from it, and sieving resumes
+	// after the last completed special-q. New relations are appended.
+	RelationFile string
+}
+
+// nfsParams are the default parameters by the number of decimal digits of n.
+var nfsParams = []struct {
+	digits int
+	fb     uint32
+	lpBits int
+	lp     int
+	logI   int
+}{
+	{0, 10000, 19, 1, 10},
+	{35, 20000, 20, 1, 10},
+	{45, 40000, 21, 1, 11},
+	{55, 80000, 22, 1, 11},
+	{65, 150000, 23, 1, 12},
+	{75, 300000, 24, 2, 12},
+	{85, 600000, 25, 2, 12},
+	{95, 1000000, 26, 2, 13},
+	{110, 2000000, 27, 2, 13},
+	{130, 5000000, 28, 2, 14},
+	{150, 10000000, 29, 2, 14},
+}
+
+// nfsMinBits is the smallest size of n accepted by NFS.
+const nfsMinBits = 64
+
+func (o *NFSOptions) withDefaults(n *big.Int) (NFSOptions, error) {
+	return o.withDigits(n, int(float64(n.BitLen())*math.Log10(2)))
+}
+
+// withDigits sets the defaults for a number of the difficulty of a general
+// number with the given number of digits.
+func (o *NFSOptions) withDigits(n *big.Int, digits int) (NFSOptions, error) {
+	var r NFSOptions
+	if o != nil {
+		r = *o
+	}
+	i := 0
+	for i < len(nfsParams)-1 && nfsParams[i+1].digits <= digits {
+		i++
+	}
+	par := nfsParams[i]
+	if r.Degree == 0 {
+		r.Degree = nfsDegree(n)
+	}
+	if r.RationalBound == 0 {
+		r.RationalBound = par.fb
+	}
+	if r.AlgebraicBound == 0 {
+		r.AlgebraicBound = par.fb
+	}
+	if r.LargePrimeBits == 0 {
+		r.LargePrimeBits = par.lpBits
+	}
+	if r.LargePrimes == 0 {
+		r.LargePrimes = par.lp
+	}
+	if r.LogI == 0 {
+		r.LogI = par.logI
+	}
+	if r.QStart == 0 {
+		r.QStart = r.AlgebraicBound
+	}
+	switch {
+	case r.Degree < 2 || r.Degree > 8:
+		return r, errors.New("degree out of range")
+	case r.RationalBound < 1000 || r.AlgebraicBound < 1000 || r.RationalBound >= 1<<31 || r.AlgebraicBound >= 1<<31:
+		return r, errors.New("factor base bound out of range")
+	case r.LargePrimeBits > 31:
+		return r, errors.New("large primes too large")
+	case r.LargePrimes > 2:
+		return r, errors.New("too many large primes")
+	case r.LogI < 8 || r.LogI > 15:
+		return r, errors.New("LogI out of range")
+	}
+	// the cofactors below the large prime bound must be prime
+	for _, b := range []uint32{r.RationalBound, r.AlgebraicBound} {
+		if float64(uint64(1)<<uint(r.LargePrimeBits)) > float64(b)*float64(b) {
+			return r, errors.New("large prime bound above the square of a factor base bound")
+		}
+	}
+	return r, nil
+}
+
+// nfsCharacters is the number of quadratic characters.
+const nfsCharacters = 32
+
+type nfs struct {
+	n    *big.Int
+	o    NFSOptions
+	poly *NFSPoly
+	skew float64
+	// the polynomials as floating point values for the sieve thresholds
+	ff       []float64
+	g0f, g1f float64
+	rfb, afb nfsFB
+	lpb      uint64
+	lpCount  int
+	// qc are the primes and roots of the quadratic characters
+	qc     [][2]uint32
+	rels   []nfsRelation
+	seen   map[[2]int64]bool
+	qDone  uint32
+	file   *relationFile
+	ratArr []uint8
+	algArr []uint8
+}
+
+// NFS tries to find a factor of n with the general number field sieve (experimental).
+//
+// The pipeline consists of a polynomial selection with a search over base m
+// expansions, lattice sieving over special-q primes of the algebraic side with
+// Franke-Kleinjung enumeration of the large factor base primes, up to two large
+// primes on each side, filtering and linear algebra with package gf2, quadratic
+// characters, and a square root computed by Newton iteration modulo a power of
+// an inert prime.
+//
+// With a RelationFile in the options the relations are saved, so that an interrupted
+// computation resumes where it stopped.
+//
+// n must have at least 64 bits. The function returns a factor if one was found or
+// otherwise ErrNoFactor if n is prime, or an error matching ErrCancelled.
+func NFS(ctx context.Context, n *big.Int, opts *NFSOptions) (*big.Int, error) {
+	if fac, err := nfsPrecheck(n); fac != nil || err != nil {
+		return fac, err
+	}
+	o, err := opts.withDefaults(n)
+	if err != nil {
+		return nil, err
+	}
+	return nfsRun(ctx, n, o)
+}
+
+// nfsPrecheck handles the inputs that need no sieving. It returns nil, nil
+// if n is an odd composite that is no square.
+func nfsPrecheck(n *big.Int) (*big.Int, error) {
+	if n.BitLen() < nfsMinBits {
+		return nil, errors.New("n too small for the number field sieve")
+	}
+	if n.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	r := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(r, r).Cmp(n) == 0 {
+		return r, nil
+	}
+	if prime, _ := IsPrime(n); prime {
+		return nil, ErrNoFactor
+	}
+	return nil, nil
+}
+
+func nfsRun(ctx context.Context, n *big.Int, o NFSOptions) (*big.Int, error) {
+	s, fac, err := newNFS(n, o)
+	if s == nil {
+		return fac, err
+	}
+	if s.file != nil {
+		defer s.file.close()
+	}
+	return s.run(ctx)
+}
+
+// newNFS selects the polynomial pair, builds the factor bases and loads the
+// relations of the relation file. If it returns a factor or an error, the
+// nfs is nil.
+func newNFS(n *big.Int, o NFSOptions) (*nfs, *big.Int, error) {
+	s := &nfs{n: n, o: o, seen: make(map[[2]int64]bool)}
+	fac, err := s.setup()
+	if fac != nil || err != nil {
+		if s.file != nil {
+			s.file.close()
+		}
+		return nil, fac, err
+	}
+	return s, nil, nil
+}
+
+func (s *nfs) setup() (*big.Int, error) {
+	var err error
+	o := s.o
+	if o.RelationFile != "" {
+		if s.file, err = openRelationFile(o.RelationFile, s.n, o.Poly); err != nil {
+			return nil, err
+		}
+		if s.file.poly != nil {
+			o.Poly = s.file.poly
+		}
+	}
+	s.poly = o.Poly
+	if s.poly == nil {
+		if s.poly, err = nfsSelectPoly(s.n, o.Degree, 200); err != nil {
+			return nil, err
+		}
+	}
+	if fac, err := s.poly.check(s.n); fac != nil || err != nil {
+		return fac, err
+	}
+	if fac := s.init(); fac != nil {
+		return fac, nil
+	}
+	if s.file != nil {
+		if err := s.file.writeHeader(s.n, s.poly); err != nil {
+			return nil, err
+		}
+		for _, rel := range s.file.rels {
+			if s.validRelation(rel) {
+				s.addRelation(rel)
+			}
+		}
+		s.qDone = s.file.qDone
+	}
+	return nil, nil
+}
+
+// init builds the factor bases and the quadratic characters.
+// It returns a factor if a factor base prime divides n.
+func (s *nfs) init() *big.Int {
+	f := s.poly.F
+	d := len(f) - 1
+	s.skew, _ = nfsSkew(f)
+	s.ff = make([]float64, d+1)
+	for i, a := range f {
+		s.ff[i], _ = new(big.Float).SetInt(a).Float64()
+	}
+	s.g0f, _ = new(big.Float).SetInt(s.poly.G[0]).Float64()
+	s.g1f, _ = new(big.Float).SetInt(s.poly.G[1]).Float64()
+	s.lpb = uint64(1) << uint(s.o.LargePrimeBits)
+	s.lpCount = s.o.LargePrimes
+	if s.lpCount < 0 {
+		s.lpCount = 0
+	}
+	var fac *big.Int
+	primes.Iterate(2, s.o.RationalBound, func(p uint32) bool {
+		if bigModSmall(s.n, p) == 0 {
+			fac = big.NewInt(int64(p))
+			return true
+		}
+		g1, g0 := bigModSigned(s.poly.G[1], p), bigModSigned(s.poly.G[0], p)
+		switch {
+		case g1 == 0 && g0 != 0:
+			s.rfb.add(p, p)
+		case g1 != 0:
+			s.rfb.add(p, uint32(uint64(p-g0)%uint64(p)*uint64(invMod(g1, p))%uint64(p)))
+		}
+		return false
+	})
+	if fac != nil {
+		return fac
+	}
+	primes.Iterate(2, s.o.AlgebraicBound, func(p uint32) bool {
+		fp := ppFromBig(f, p)
+		if len(fp) == 0 {
+			return false
+		}
+		for _, r := range ppRoots(fp, uint64(p)) {
+			s.afb.add(p, uint32(r))
+		}
+		if len(fp) < len(f) {
+			s.afb.add(p, p)
+		}
+		return false
+	})
+	// the quadratic characters use primes above the large primes
+	for q := uint32(s.lpb) + 1; len(s.qc) < nfsCharacters; q++ {
+		if !isPrime64(uint64(q)) {
+			continue
+		}
+		fp := ppFromBig(f, q)
+		if len(fp) < len(f) {
+			continue
+		}
+		df := ppDeriv(fp, uint64(q))
+		for _, r := range ppRoots(fp, uint64(q)) {
+			if ppEval(df, r, uint64(q)) != 0 && len(s.qc) < nfsCharacters {
+				s.qc = append(s.qc, [2]uint32{q, uint32(r)})
+			}
+		}
+	}
+	return nil
+}
+
+// addRelation adds a relation unless it is a duplicate.
+func (s *nfs) addRelation(r nfsRelation) bool {
+	key := [2]int64{r.a, r.b}
+	if s.seen[key] {
+		return false
+	}
+	s.seen[key] = true
+	s.rels = append(s.rels, r)
+	return true
+}
+
+// run sieves special-q primes and tries the linear algebra whenever enough
+// relations are collected.
+func (s *nfs) run(ctx context.Context) (*big.Int, error) {
+	target := (len(s.rfb.p) + len(s.afb.p)) / 2
+	q := s.o.QStart - 1
+	if s.qDone > q {
+		q = s.qDone
+	}
+	for {
+		if q = s.nextQ(q); q == 0 {
+			return nil, ErrNoFactor
+		}
+		select {
+		case <-ctx.Done():
+			return nil, cancelled(ctx)
+		default:
+		}
+		if err := s.sieveSpecialQ(q); err != nil {
+			return nil, err
+		}
+		if len(s.rels) < target {
+			continue
+		}
+		fac, err := s.linearAlgebra(ctx)
+		if fac != nil || err != nil {
+			return fac, err
+		}
+		target = len(s.rels) + len(s.rels)/20
+	}
+}
+
+// nextQ returns the next special-q after q, a prime where F has a root and
+// the leading coefficient of F is not zero, or 0 if there is none below 2^31.
+func (s *nfs) nextQ(q uint32) uint32 {
+	for q++; q < 1<<31; q++ {
+		if !isPrime64(uint64(q)) {
+			continue
+		}
+		fp := ppFromBig(s.poly.F, q)
+		if len(fp) == len(s.poly.F) && len(ppRoots(fp, uint64(q))) > 0 {
+			return q
+		}
+	}
+	return 0
+}
+
+// sieveSpecialQ sieves all roots of the special-q and saves the new relations.
+func (s *nfs) sieveSpecialQ(q uint32) error {
+	var found []nfsRelation
+	for _, rho := range ppRoots(ppFromBig(s.poly.F, q), uint64(q)) {
+		for _, r := range s.sieveQ(q, uint32(rho)) {
+			if s.addRelation(r) {
+				found = append(found, r)
+			}
+		}
+	}
+	s.qDone = q
+	if s.file != nil {
+		return s.file.write(found, q)
+	}
+	return nil
+}
+
+// matrix returns the matrix of the relations: a column per relation with the
+// rows for the sign of the rational value, the parity of the number of
+// relations, the quadratic characters and the prime ideals. Without chars the
+// rows of the quadratic characters are zero.
+func (s *nfs) matrix(chars bool) *gf2.Matrix {
+	ratRow := make(map[uint64]int)
+	algRow := make(map[[2]uint64]int)
+	next := 2 + len(s.qc)
+	cols := make([][]int, len(s.rels))
+	ba, bb := new(big.Int), new(big.Int)
+	v, t := new(big.Int), new(big.Int)
+	for k, r := range s.rels {
+		col := []int{1}
+		ba.SetInt64(r.a)
+		bb.SetInt64(r.b)
+		v.Mul(s.poly.G[1], ba)
+		if v.Add(v, t.Mul(s.poly.G[0], bb)).Sign() < 0 {
+			col = append(col, 0)
+		}
+		for i, c := range s.qc {
+			if !chars {
+				break
+			}
+			q := uint64(c[0])
+			x := (modInt(r.a, c[0]) + q - modInt(r.b, c[0])*uint64(c[1])%q) % q
+			if x != 0 && big.Jacobi(t.SetUint64(x), v.SetUint64(q)) < 0 {
+				col = append(col, 2+i)
+			}
+		}
+		for _, p := range r.rat {
+			row, ok := ratRow[p]
+			if !ok {
+				row = next
+				next++
+				ratRow[p] = row
+			}
+			col = append(col, row)
+		}
+		for _, p := range r.alg {
+			id := [2]uint64{p, idealRoot(r.a, r.b, p)}
+			row, ok := algRow[id]
+			if !ok {
+				row = next
+				next++
+				algRow[id] = row
+			}
+			col = append(col, row)
+		}
+		cols[k] = col
+	}
+	m := gf2.NewMatrix(next)
+	for _, c := range cols {
+		m.AddColumn(c)
+	}
+	return m
+}
+
+// idealRoot returns a/b modulo p, or p if p divides b.
+func idealRoot(a, b int64, p uint64) uint64 {
+	bm := uint64(b) % p
+	if bm == 0 {
+		return p
+	}
+	am := modInt(a, uint32(p))
+	return am * uint64(invMod(uint32(bm), uint32(p))) % p
+}
+
+// linearAlgebra finds dependencies of the relations and tries the square roots.
+// It returns nil without error if more relations are needed.
+func (s *nfs) linearAlgebra(ctx context.Context) (*big.Int, error) {
+	// the quadratic characters are expensive, check the excess without them first
+	red, _ := gf2.Reduce(s.matrix(false), 0)
+	if len(red.Cols) <= red.Rows {
+		return nil, nil
+	}
+	m := s.matrix(true)
+	deps, err := gf2.Nullspace(m, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range deps {
+		select {
+		case <-ctx.Done():
+			return nil, cancelled(ctx)
+		default:
+		}
+		x, y, err := s.nfsSqrt(dep)
+		if err != nil {
+			continue
+		}
+		d := new(big.Int).Sub(x, y)
+		d.GCD(nil, nil, d.Abs(d), s.n)
+		if isProper(d, s.n) {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
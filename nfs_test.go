@@ -0,0 +1,184 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNFS(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     string
+		short bool
+	}{
+		{"30 digits", "84055899507848841139275608657", true},
+		{"36 digits", "160961943761222174590288674584346103", true},
+		{"40 digits", "189391382557921221565472510185640578727", false},
+		{"50 digits", "15177663620088631960631251549094043006909424738007", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.short && testing.Short() {
+				t.Skip("skipped in short mode")
+			}
+			n := intval(tt.n)
+			fac, err := NFS(context.Background(), n, nil)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !isProper(fac, n) || new(big.Int).Mod(n, fac).Sign() != 0 {
+				t.Error("invalid factor", fac)
+			}
+		})
+	}
+}
+
+func TestNFSErrors(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NFS(ctx, big.NewInt(1000003*1009), nil); err == nil {
+		t.Error("expected an error for a small n")
+	}
+	// 2^89-1 is prime
+	if _, err := NFS(ctx, intval("618970019642690137449562111"), nil); err != ErrNoFactor {
+		t.Errorf("got %v, want ErrNoFactor", err)
+	}
+	p := intval("1000000000000000000000000000057")
+	fac, err := NFS(ctx, new(big.Int).Mul(p, p), nil)
+	if err != nil || fac.Cmp(p) != 0 {
+		t.Errorf("got %v, %v, want %v", fac, err, p)
+	}
+	// the factor is in the factor base
+	fac, err = NFS(ctx, new(big.Int).Mul(p, big.NewInt(1009)), nil)
+	if err != nil || fac.Int64() != 1009 {
+		t.Errorf("got %v, %v, want 1009", fac, err)
+	}
+	n := intval("84055899507848841139275608657")
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = NFS(cctx, n, nil); !errors.Is(err, ErrCancelled) {
+		t.Errorf("got %v, want ErrCancelled", err)
+	}
+	for _, o := range []NFSOptions{{Degree: 1}, {RationalBound: 100}, {LargePrimeBits: 32}, {LogI: 20}, {LargePrimes: 3}, {LargePrimeBits: 30, AlgebraicBound: 10000}} {
+		if _, err = NFS(ctx, n, &o); err == nil {
+			t.Errorf("expected an error for %+v", o)
+		}
+	}
+	// a polynomial pair without common root
+	poly := nfsBaseM(n, 3, 1)
+	poly.F[0] = new(big.Int).Add(poly.F[0], bigOne)
+	if _, err = NFS(ctx, n, &NFSOptions{Poly: poly}); err == nil {
+		t.Error("expected an error for an invalid polynomial pair")
+	}
+}
+
+func TestNFSRelationFile(t *testing.T) {
+	n := intval("84055899507848841139275608657")
+	name := filepath.Join(t.TempDir(), "rels")
+	// sieve some special-q and stop
+	o := &NFSOptions{RelationFile: name}
+	do, err := o.withDefaults(n)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	s, fac, err := newNFS(n, do)
+	if s == nil {
+		t.Fatal("unexpected result", fac, err)
+	}
+	q := do.QStart
+	for i := 0; i < 3; i++ {
+		if q = s.nextQ(q); q == 0 {
+			t.Fatal("no special-q")
+		}
+		if err := s.sieveSpecialQ(q); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+	s.file.close()
+	// resume
+	rf, err := openRelationFile(name, n, nil)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if rf.poly == nil || !samePoly(rf.poly, s.poly) {
+		t.Error("polynomial pair not restored")
+	}
+	if rf.qDone != q {
+		t.Errorf("got last special-q %v, want %v", rf.qDone, q)
+	}
+	if len(rf.rels) != len(s.rels) {
+		t.Errorf("got %v relations, want %v", len(rf.rels), len(s.rels))
+	}
+	rf.close()
+	// an incomplete last line is ignored
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("12,34:1f,")
+	f.Close()
+	fac, err = NFS(context.Background(), n, o)
+	if err != nil || !isProper(fac, n) || new(big.Int).Mod(n, fac).Sign() != 0 {
+		t.Fatalf("got %v, %v", fac, err)
+	}
+	if _, err := NFS(context.Background(), intval("160961943761222174590288674584346103"), o); err == nil {
+		t.Error("expected an error for a file of a different number")
+	}
+	o.Poly = nfsBaseM(n, 4, 1)
+	if _, err := NFS(context.Background(), n, o); err == nil {
+		t.Error("expected an error for a different polynomial pair")
+	}
+}
+
+func TestFormatRelation(t *testing.T) {
+	r := nfsRelation{a: -12345, b: 678, rat: []uint64{2, 3, 3, 1000003}, alg: []uint64{7, 65537}}
+	line := formatRelation(r)
+	if line != "-12345,678:2,3,3,f4243:7,10001" {
+		t.Error("got", line)
+	}
+	got, ok := parseRelation(line)
+	if !ok || got.a != r.a || got.b != r.b || len(got.rat) != len(r.rat) || len(got.alg) != len(r.alg) {
+		t.Errorf("got %v, %v", got, ok)
+	}
+	for _, bad := range []string{"1,2:3", "1,0::", "x,2::", "1,2:1:", "1,2:3,,5:"} {
+		if _, ok := parseRelation(bad); ok {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestGFSqrt(t *testing.T) {
+	// x^3 - 2 is irreducible modulo 7
+	f := pPoly{5, 0, 0, 1}
+	const p = 7
+	for _, a := range []pPoly{{3}, {1, 2}, {4, 5, 6}, {0, 1}} {
+		sq := ppMulMod(a, a, f, p)
+		r, ok := gfSqrt(sq, f, p)
+		if !ok {
+			t.Errorf("no square root of %v", sq)
+			continue
+		}
+		if got := ppMulMod(r, r, f, p); !ppEqual(got, sq) {
+			t.Errorf("(%v)^2 = %v, want %v", r, got, sq)
+		}
+	}
+	// 3 is not a square modulo 7, and the degree is odd
+	if _, ok := gfSqrt(pPoly{3}, f, p); ok {
+		t.Error("square root of 3 found")
+	}
+}
+
+func ppEqual(f, g pPoly) bool {
+	if len(f) != len(g) {
+		return false
+	}
+	for i := range f {
+		if f[i] != g[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,467 @@
+package intfact
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/cmplx"
+)
+
+// NFSPoly is a polynomial pair for the number field sieve. The algebraic polynomial
+// F(x) = F[d]x^d + ... + F[0] and the rational polynomial G(x) = G[1]x + G[0]
+// have a common root m modulo n.
+type NFSPoly struct {
+	F []*big.Int
+	G [2]*big.Int
+}
+
+func (f *NFSPoly) degree() int {
+	return len(f.F) - 1
+}
+
+// check verifies the common root of f modulo n. It returns a factor of n if the
+// leading coefficient of G or the content of F shares one with n.
+func (f *NFSPoly) check(n *big.Int) (*big.Int, error) {
+	if f.degree() < 2 || f.F[f.degree()].Sign() == 0 || f.G[0] == nil || f.G[1] == nil || f.G[1].Sign() == 0 {
+		return nil, errors.New("invalid polynomial pair")
+	}
+	g := new(big.Int).GCD(nil, nil, new(big.Int).Abs(f.G[1]), n)
+	if isProper(g, n) {
+		return g, nil
+	}
+	c := new(big.Int)
+	for _, a := range f.F {
+		c.GCD(nil, nil, c, new(big.Int).Abs(a))
+	}
+	if c.GCD(nil, nil, c, n); isProper(c, n) {
+		return c, nil
+	}
+	// the resultant sum F[i] (-G[0])^i G[1]^(d-i) is a multiple of n
+	res := new(big.Int)
+	t := new(big.Int)
+	ng0 := new(big.Int).Neg(f.G[0])
+	for i, a := range f.F {
+		t.Exp(ng0, big.NewInt(int64(i)), nil)
+		t.Mul(t, new(big.Int).Exp(f.G[1], big.NewInt(int64(f.degree()-i)), nil))
+		res.Add(res, t.Mul(t, a))
+	}
+	if res.Mod(res, n).Sign() != 0 {
+		return nil, errors.New("polynomials have no common root modulo n")
+	}
+	return nil, nil
+}
+
+// root returns the common root m modulo n.
+func (f *NFSPoly) root(n *big.Int) *big.Int {
+	m := new(big.Int).ModInverse(f.G[1], n)
+	m.Mul(m, f.G[0])
+	m.Neg(m)
+	return m.Mod(m, n)
+}
+
+// rootInt returns the integer d-th root of x >= 0, rounded down.
+func rootInt(x *big.Int, d int) *big.Int {
+	if x.Sign() == 0 {
+		return new(big.Int)
+	}
+	// Newton iteration from above
+	r := new(big.Int).Lsh(bigOne, uint(x.BitLen()/d+1))
+	bd := big.NewInt(int64(d))
+	t := new(big.Int)
+	for {
+		// (d-1)r + x/r^(d-1), divided by d
+		t.Exp(r, big.NewInt(int64(d-1)), nil)
+		t.Quo(x, t)
+		t.Add(t, new(big.Int).Mul(r, big.NewInt(int64(d-1))))
+		t.Quo(t, bd)
+		if t.Cmp(r) >= 0 {
+			return r
+		}
+		r.Set(t)
+	}
+}
+
+// nfsBaseM returns the base m expansion of n with balanced digits, where m is
+// chosen so that the leading coefficient is about lead.
+func nfsBaseM(n *big.Int, d int, lead int64) *NFSPoly {
+	m := rootInt(new(big.Int).Quo(n, big.NewInt(lead)), d)
+	f := make([]*big.Int, d+1)
+	r := new(big.Int).Set(n)
+	md := new(big.Int).Exp(m, big.NewInt(int64(d)), nil)
+	f[d] = new(big.Int).Quo(r, md)
+	r.Sub(r, new(big.Int).Mul(f[d], md))
+	half := new(big.Int)
+	for i := d - 1; i > 0; i-- {
+		mi := new(big.Int).Exp(m, big.NewInt(int64(i)), nil)
+		q, rem := new(big.Int).QuoRem(r, mi, new(big.Int))
+		if half.Lsh(rem, 1).Cmp(mi) >= 0 {
+			q.Add(q, bigOne)
+		}
+		f[i] = q
+		r.Sub(r, mi.Mul(mi, q))
+	}
+	f[0] = r
+	return &NFSPoly{F: f, G: [2]*big.Int{new(big.Int).Neg(m), big.NewInt(1)}}
+}
+
+// nfsSkew returns the skewness s minimizing the L2 size of F on a region
+// |a| <= s*A, 0 < b <= A/s, together with the logarithm of that size.
+func nfsSkew(f []*big.Int) (skew, size float64) {
+	d := len(f) - 1
+	c := make([]float64, len(f))
+	for i, a := range f {
+		c[i], _ = new(big.Float).SetInt(a).Float64()
+	}
+	l2 := func(ls float64) float64 {
+		var sum float64
+		for i, a := range c {
+			sum += a * a * math.Exp(ls*float64(2*i-d))
+		}
+		return math.Log(sum) / 2
+	}
+	// golden section search on the logarithm of the skewness
+	lo, hi := -20.0, 60.0
+	const g = 0.6180339887498949
+	x1, x2 := hi-g*(hi-lo), lo+g*(hi-lo)
+	f1, f2 := l2(x1), l2(x2)
+	for hi-lo > 1e-3 {
+		if f1 < f2 {
+			hi, x2, f2 = x2, x1, f1
+			x1 = hi - g*(hi-lo)
+			f1 = l2(x1)
+		} else {
+			lo, x1, f1 = x1, x2, f2
+			x2 = lo + g*(hi-lo)
+			f2 = l2(x2)
+		}
+	}
+	return math.Exp(x1), f1
+}
+
+// nfsAlpha estimates the contribution of the small primes to the size of the values of F.
+// Polynomials with many roots modulo small primes have a negative alpha, their values
+// are more likely to be smooth.
+func nfsAlpha(f []*big.Int) float64 {
+	var alpha float64
+	for _, p := range []uint32{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71,
+		73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151, 157, 163, 167, 173,
+		179, 181, 191, 193, 197, 199} {
+		fp := ppFromBig(f, p)
+		roots := 0
+		if len(fp) > 1 {
+			for x := uint64(0); x < uint64(p); x++ {
+				if ppEval(fp, x, uint64(p)) == 0 {
+					roots++
+				}
+			}
+		}
+		pf := float64(p)
+		alpha += (1/(pf-1) - float64(roots)*pf/(pf*pf-1)) * math.Log(pf)
+	}
+	return alpha
+}
+
+// nfsSelectPoly searches base m expansions of n with leading coefficients up to
+// tries and returns the pair with the smallest values of F, corrected by alpha.
+// Polynomials without an inert prime are skipped, the square root needs one.
+func nfsSelectPoly(n *big.Int, d int, tries int) (*NFSPoly, error) {
+	var best *NFSPoly
+	bestScore := math.Inf(1)
+	for lead := int64(1); lead <= int64(tries); lead++ {
+		f := nfsBaseM(n, d, lead)
+		if f.F[d].Sign() == 0 {
+			break
+		}
+		_, size := nfsSkew(f.F)
+		score := size + nfsAlpha(f.F)
+		if score >= bestScore {
+			continue
+		}
+		if _, ok := nfsInertPrime(f.F, 1<<20); !ok {
+			continue
+		}
+		best, bestScore = f, score
+	}
+	if best == nil {
+		return nil, errors.New("no irreducible polynomial found")
+	}
+	return best, nil
+}
+
+// nfsDegree returns the default degree of the algebraic polynomial for n.
+func nfsDegree(n *big.Int) int {
+	switch b := n.BitLen(); {
+	case b < 200:
+		return 3
+	case b < 350:
+		return 4
+	case b < 550:
+		return 5
+	}
+	return 6
+}
+
+// nfsInertPrime returns a prime p > from such that f is irreducible modulo p.
+// If f is irreducible modulo some prime, it is irreducible over the rationals.
+func nfsInertPrime(f []*big.Int, from uint32) (uint32, bool) {
+	tries := 0
+	for p := from | 1; tries < 300; p += 2 {
+		if !isPrime64(uint64(p)) {
+			continue
+		}
+		tries++
+		fp := ppFromBig(f, p)
+		if len(fp) == len(f) && ppIrreducible(fp, uint64(p)) {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// nfsComplexRoots returns the complex roots of f, computed with the
+// Durand-Kerner iteration.
+func nfsComplexRoots(f []*big.Int) []complex128 {
+	d := len(f) - 1
+	c := make([]complex128, d+1)
+	lead, _ := new(big.Float).SetInt(f[d]).Float64()
+	for i, a := range f {
+		x, _ := new(big.Float).SetInt(a).Float64()
+		c[i] = complex(x/lead, 0)
+	}
+	// start on a circle with the Cauchy bound as radius
+	bound := 0.0
+	for _, a := range c[:d] {
+		bound = math.Max(bound, cmplx.Abs(a))
+	}
+	bound++
+	z := make([]complex128, d)
+	for i := range z {
+		z[i] = cmplx.Rect(bound, 2*math.Pi*float64(i)/float64(d)+0.4)
+	}
+	for iter := 0; iter < 1000; iter++ {
+		change := 0.0
+		for i := range z {
+			num := horner(c, z[i])
+			den := complex(1, 0)
+			for j := range z {
+				if j != i {
+					den *= z[i] - z[j]
+				}
+			}
+			dz := num / den
+			z[i] -= dz
+			change = math.Max(change, cmplx.Abs(dz)/(1+cmplx.Abs(z[i])))
+		}
+		if change < 1e-15 {
+			break
+		}
+	}
+	return z
+}
+
+// horner evaluates the monic polynomial c at x.
+func horner(c []complex128, x complex128) complex128 {
+	v := complex(1, 0)
+	for j := len(c) - 2; j >= 0; j-- {
+		v = v*x + c[j]
+	}
+	return v
+}
+
+// pPoly is a polynomial over GF(p) for a prime p < 2^32, lowest coefficient first.
+// Leading zeros are trimmed.
+type pPoly []uint64
+
+func ppTrim(f pPoly) pPoly {
+	for len(f) > 0 && f[len(f)-1] == 0 {
+		f = f[:len(f)-1]
+	}
+	return f
+}
+
+// bigModSigned returns x mod p in [0, p).
+func bigModSigned(x *big.Int, p uint32) uint32 {
+	r := bigModSmall(x, p)
+	if x.Sign() < 0 && r != 0 {
+		r = p - r
+	}
+	return r
+}
+
+func ppFromBig(f []*big.Int, p uint32) pPoly {
+	fp := make(pPoly, len(f))
+	for i, a := range f {
+		fp[i] = uint64(bigModSigned(a, p))
+	}
+	return ppTrim(fp)
+}
+
+func ppEval(f pPoly, x, p uint64) uint64 {
+	var v uint64
+	for i := len(f) - 1; i >= 0; i-- {
+		v = (v*x + f[i]) % p
+	}
+	return v
+}
+
+func ppMul(f, g pPoly, p uint64) pPoly {
+	if len(f) == 0 || len(g) == 0 {
+		return nil
+	}
+	h := make(pPoly, len(f)+len(g)-1)
+	for i, a := range f {
+		for j, b := range g {
+			h[i+j] = (h[i+j] + a*b) % p
+		}
+	}
+	return ppTrim(h)
+}
+
+// ppDivRem returns the quotient and remainder of f divided by m != 0.
+func ppDivRem(f, m pPoly, p uint64) (q, r pPoly) {
+	r = append(pPoly(nil), f...)
+	dm := len(m) - 1
+	if len(r) <= dm {
+		return nil, r
+	}
+	inv := uint64(invMod(uint32(m[dm]), uint32(p)))
+	q = make(pPoly, len(r)-dm)
+	for i := len(r) - 1; i >= dm; i-- {
+		c := r[i] * inv % p
+		q[i-dm] = c
+		if c == 0 {
+			continue
+		}
+		for j := 0; j <= dm; j++ {
+			r[i-dm+j] = (r[i-dm+j] + (p-c)*m[j]) % p
+		}
+	}
+	return ppTrim(q), ppTrim(r[:dm])
+}
+
+func ppMulMod(f, g, m pPoly, p uint64) pPoly {
+	_, r := ppDivRem(ppMul(f, g, p), m, p)
+	return r
+}
+
+// ppPowMod returns f^e modulo m.
+func ppPowMod(f pPoly, e *big.Int, m pPoly, p uint64) pPoly {
+	r := pPoly{1}
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r = ppMulMod(r, r, m, p)
+		if e.Bit(i) != 0 {
+			r = ppMulMod(r, f, m, p)
+		}
+	}
+	_, r = ppDivRem(r, m, p)
+	return r
+}
+
+// ppGcd returns the monic gcd of f and g.
+func ppGcd(f, g pPoly, p uint64) pPoly {
+	f, g = ppTrim(f), ppTrim(g)
+	for len(g) > 0 {
+		_, r := ppDivRem(f, g, p)
+		f, g = g, r
+	}
+	if len(f) == 0 {
+		return f
+	}
+	inv := uint64(invMod(uint32(f[len(f)-1]), uint32(p)))
+	h := make(pPoly, len(f))
+	for i, c := range f {
+		h[i] = c * inv % p
+	}
+	return h
+}
+
+func ppSub(f, g pPoly, p uint64) pPoly {
+	l := len(f)
+	if len(g) > l {
+		l = len(g)
+	}
+	h := make(pPoly, l)
+	copy(h, f)
+	for i, c := range g {
+		h[i] = (h[i] + p - c) % p
+	}
+	return ppTrim(h)
+}
+
+func ppDeriv(f pPoly, p uint64) pPoly {
+	if len(f) < 2 {
+		return nil
+	}
+	h := make(pPoly, len(f)-1)
+	for i := range h {
+		h[i] = f[i+1] * uint64(i+1) % p
+	}
+	return ppTrim(h)
+}
+
+// ppRoots returns the roots of f in GF(p), without multiplicities.
+func ppRoots(f pPoly, p uint64) []uint64 {
+	f = ppTrim(f)
+	if len(f) < 2 {
+		return nil
+	}
+	if p < 64 {
+		var roots []uint64
+		for x := uint64(0); x < p; x++ {
+			if ppEval(f, x, p) == 0 {
+				roots = append(roots, x)
+			}
+		}
+		return roots
+	}
+	// the product of the linear factors is gcd(f, x^p - x)
+	xp := ppPowMod(pPoly{0, 1}, new(big.Int).SetUint64(p), f, p)
+	g := ppGcd(f, ppSub(xp, pPoly{0, 1}, p), p)
+	var roots []uint64
+	ppSplit(g, p, 1, &roots)
+	return roots
+}
+
+// ppSplit finds the roots of a monic product of distinct linear factors with the
+// Cantor-Zassenhaus method: gcd(g, (x+delta)^((p-1)/2) - 1) splits g.
+func ppSplit(g pPoly, p uint64, delta uint64, roots *[]uint64) {
+	switch len(g) {
+	case 0, 1:
+		return
+	case 2:
+		*roots = append(*roots, (p-g[0])%p)
+		return
+	}
+	e := new(big.Int).SetUint64((p - 1) / 2)
+	for ; ; delta++ {
+		h := ppPowMod(pPoly{delta % p, 1}, e, g, p)
+		k := ppGcd(g, ppSub(h, pPoly{1}, p), p)
+		if len(k) > 1 && len(k) < len(g) {
+			q, _ := ppDivRem(g, k, p)
+			ppSplit(k, p, delta+1, roots)
+			ppSplit(ppGcd(q, q, p), p, delta+1, roots)
+			return
+		}
+	}
+}
+
+// ppIrreducible reports whether f of degree d is irreducible over GF(p):
+// it is square free and has no factors of degree up to d/2.
+func ppIrreducible(f pPoly, p uint64) bool {
+	d := len(f) - 1
+	if d < 1 {
+		return false
+	}
+	if len(ppGcd(f, ppDeriv(f, p), p)) > 1 {
+		return false
+	}
+	bp := new(big.Int).SetUint64(p)
+	h := pPoly{0, 1}
+	for i := 1; i <= d/2; i++ {
+		h = ppPowMod(h, bp, f, p)
+		if len(ppGcd(f, ppSub(h, pPoly{0, 1}, p), p)) > 1 {
+			return false
+		}
+	}
+	return true
+}
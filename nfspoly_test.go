@@ -0,0 +1,137 @@
+package intfact
+
+import (
+	"math/big"
+	"math/cmplx"
+	"sort"
+	"testing"
+)
+
+func TestNFSBaseM(t *testing.T) {
+	n := intval("160961943761222174590288674584346103")
+	for d := 2; d <= 5; d++ {
+		for lead := int64(1); lead <= 3; lead++ {
+			f := nfsBaseM(n, d, lead)
+			if f.degree() != d {
+				t.Fatalf("degree %v, want %v", f.degree(), d)
+			}
+			// F(m) = n
+			m := new(big.Int).Neg(f.G[0])
+			v := new(big.Int)
+			for i := d; i >= 0; i-- {
+				v.Mul(v, m)
+				v.Add(v, f.F[i])
+			}
+			if v.Cmp(n) != 0 {
+				t.Errorf("d=%v lead=%v: F(m) = %v", d, lead, v)
+			}
+			if fac, err := f.check(n); fac != nil || err != nil {
+				t.Errorf("d=%v lead=%v: check returned %v, %v", d, lead, fac, err)
+			}
+		}
+	}
+	f := nfsBaseM(n, 3, 1)
+	f.F[0] = new(big.Int).Add(f.F[0], bigOne)
+	if _, err := f.check(n); err == nil {
+		t.Error("expected an error for a wrong polynomial")
+	}
+}
+
+func TestNFSSelectPoly(t *testing.T) {
+	n := intval("189391382557921221565472510185640578727")
+	f, err := nfsSelectPoly(n, 3, 50)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if fac, err := f.check(n); fac != nil || err != nil {
+		t.Fatal("check returned", fac, err)
+	}
+	if p, ok := nfsInertPrime(f.F, 1<<20); !ok {
+		t.Error("no inert prime")
+	} else if fp := ppFromBig(f.F, p); !ppIrreducible(fp, uint64(p)) {
+		t.Errorf("F is reducible modulo %v", p)
+	}
+	if s, _ := nfsSkew(f.F); s < 1 {
+		t.Error("skewness", s)
+	}
+}
+
+func TestPPRoots(t *testing.T) {
+	tests := []struct {
+		f []int64
+		p uint64
+	}{
+		{[]int64{-2, 0, 1}, 7},
+		{[]int64{1, 0, 1}, 13},
+		{[]int64{1, 0, 1}, 11},
+		{[]int64{-1, 0, 0, 1}, 31},
+		{[]int64{6, -11, 6, -1}, 101},
+		{[]int64{3, 5, 0, 7, 2}, 1009},
+		{[]int64{-8, 0, 0, 1}, 2},
+		{[]int64{1, 1, 1, 1, 1, 1}, 65537},
+	}
+	for _, tt := range tests {
+		f := make([]*big.Int, len(tt.f))
+		for i, c := range tt.f {
+			f[i] = big.NewInt(c)
+		}
+		fp := ppFromBig(f, uint32(tt.p))
+		var want []uint64
+		for x := uint64(0); x < tt.p; x++ {
+			if ppEval(fp, x, tt.p) == 0 {
+				want = append(want, x)
+			}
+		}
+		got := ppRoots(fp, tt.p)
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		if len(got) != len(want) {
+			t.Errorf("%v mod %v: got roots %v, want %v", tt.f, tt.p, got, want)
+			continue
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%v mod %v: got roots %v, want %v", tt.f, tt.p, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestPPIrreducible(t *testing.T) {
+	tests := []struct {
+		f    []int64
+		p    uint64
+		want bool
+	}{
+		{[]int64{1, 0, 1}, 7, true},
+		{[]int64{1, 0, 1}, 5, false},
+		{[]int64{2, 0, 0, 1}, 7, true},
+		{[]int64{1, 1, 0, 0, 1}, 2, true},
+		{[]int64{1, 0, 1, 0, 1}, 2, false},
+		{[]int64{1, 1, 1, 1, 1}, 2, true},
+		{[]int64{1, 0, 2, 0, 1}, 101, false},
+	}
+	for _, tt := range tests {
+		f := make([]*big.Int, len(tt.f))
+		for i, c := range tt.f {
+			f[i] = big.NewInt(c)
+		}
+		if got := ppIrreducible(ppFromBig(f, uint32(tt.p)), tt.p); got != tt.want {
+			t.Errorf("%v mod %v: got %v, want %v", tt.f, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestNFSComplexRoots(t *testing.T) {
+	f := []*big.Int{big.NewInt(-6), big.NewInt(11), big.NewInt(-6), big.NewInt(1)}
+	roots := nfsComplexRoots(f)
+	if len(roots) != 3 {
+		t.Fatal("got", len(roots), "roots")
+	}
+	c := []complex128{-6, 11, -6, 1}
+	for _, r := range roots {
+		if cmplx.Abs(horner(c, r)) > 1e-9 {
+			t.Errorf("f(%v) = %v", r, horner(c, r))
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package intfact
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// relationFile is a text file with the state of an NFS computation.
+// The header lines "n <n>", "f <i> <F[i]>" and "g <i> <G[i]>" give the number and the
+// polynomial pair. A relation is written as "a,b:p,p,...:p,p,..." with the rational and
+// the algebraic primes in hexadecimal, and a line "q <q>" marks a completed special-q.
+// Empty lines, lines starting with '#' and malformed lines are ignored.
+type relationFile struct {
+	f      *os.File
+	w      *bufio.Writer
+	header bool
+	poly   *NFSPoly
+	rels   []nfsRelation
+	qDone  uint32
+}
+
+// openRelationFile opens or creates the file and reads its content.
+// The number in the file must be n, and a polynomial pair in the file must match poly,
+// if poly is not nil.
+func openRelationFile(name string, n *big.Int, poly *NFSPoly) (*relationFile, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	rf := &relationFile{f: f, w: bufio.NewWriter(f)}
+	if err := rf.read(f, n); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if poly != nil && rf.poly != nil && !samePoly(poly, rf.poly) {
+		f.Close()
+		return nil, errors.New("relation file has a different polynomial pair")
+	}
+	// terminate an incomplete last line
+	if st, err := f.Stat(); err == nil && st.Size() > 0 {
+		b := make([]byte, 1)
+		if _, err := f.ReadAt(b, st.Size()-1); err == nil && b[0] != '\n' {
+			rf.w.WriteByte('\n')
+		}
+	}
+	return rf, nil
+}
+
+func (rf *relationFile) read(r io.Reader, n *big.Int) error {
+	var fs, gs []*big.Int
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.Contains(line, ":") {
+			if rel, ok := parseRelation(line); ok {
+				rf.rels = append(rf.rels, rel)
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		switch {
+		case fields[0] == "n" && len(fields) == 2:
+			fn, ok := new(big.Int).SetString(fields[1], 10)
+			if !ok || fn.Cmp(n) != 0 {
+				return errors.New("relation file is for a different number")
+			}
+			rf.header = true
+		case (fields[0] == "f" || fields[0] == "g") && len(fields) == 3:
+			i, err := strconv.Atoi(fields[1])
+			c, ok := new(big.Int).SetString(fields[2], 10)
+			if err != nil || !ok || i < 0 || i > 8 {
+				continue
+			}
+			cs := &fs
+			if fields[0] == "g" {
+				cs = &gs
+			}
+			for len(*cs) <= i {
+				*cs = append(*cs, new(big.Int))
+			}
+			(*cs)[i] = c
+		case fields[0] == "q" && len(fields) == 2:
+			if q, err := strconv.ParseUint(fields[1], 10, 32); err == nil && uint32(q) > rf.qDone {
+				rf.qDone = uint32(q)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if len(fs) > 0 || len(gs) > 0 {
+		if len(gs) != 2 {
+			return errors.New("invalid polynomial in relation file")
+		}
+		rf.poly = &NFSPoly{F: fs, G: [2]*big.Int{gs[0], gs[1]}}
+	}
+	return nil
+}
+
+func samePoly(a, b *NFSPoly) bool {
+	if len(a.F) != len(b.F) || a.G[0].Cmp(b.G[0]) != 0 || a.G[1].Cmp(b.G[1]) != 0 {
+		return false
+	}
+	for i, c := range a.F {
+		if c.Cmp(b.F[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRelation parses a line "a,b:p,p,...:p,p,...".
+func parseRelation(line string) (nfsRelation, bool) {
+	var r nfsRelation
+	parts := strings.Split(line, ":")
+	if len(parts) != 3 {
+		return r, false
+	}
+	ab := strings.Split(parts[0], ",")
+	if len(ab) != 2 {
+		return r, false
+	}
+	var err error
+	if r.a, err = strconv.ParseInt(ab[0], 10, 64); err != nil {
+		return r, false
+	}
+	if r.b, err = strconv.ParseInt(ab[1], 10, 64); err != nil || r.b <= 0 {
+		return r, false
+	}
+	for k, part := range parts[1:] {
+		var ps []uint64
+		if part != "" {
+			for _, h := range strings.Split(part, ",") {
+				p, err := strconv.ParseUint(h, 16, 32)
+				if err != nil || p < 2 {
+					return r, false
+				}
+				ps = append(ps, p)
+			}
+		}
+		if k == 0 {
+			r.rat = ps
+		} else {
+			r.alg = ps
+		}
+	}
+	return r, true
+}
+
+func formatRelation(r nfsRelation) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d,%d:", r.a, r.b)
+	for k, ps := range [][]uint64{r.rat, r.alg} {
+		if k > 0 {
+			sb.WriteByte(':')
+		}
+		for i, p := range ps {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(strconv.FormatUint(p, 16))
+		}
+	}
+	return sb.String()
+}
+
+// writeHeader writes the number and the polynomial pair if the file has no header yet.
+func (rf *relationFile) writeHeader(n *big.Int, poly *NFSPoly) error {
+	if rf.header {
+		return nil
+	}
+	fmt.Fprintf(rf.w, "n %v\n", n)
+	for i, c := range poly.F {
+		fmt.Fprintf(rf.w, "f %d %v\n", i, c)
+	}
+	for i, c := range poly.G {
+		fmt.Fprintf(rf.w, "g %d %v\n", i, c)
+	}
+	rf.header = true
+	return rf.w.Flush()
+}
+
+// write appends the relations and marks q as completed.
+func (rf *relationFile) write(rels []nfsRelation, q uint32) error {
+	for _, r := range rels {
+		rf.w.WriteString(formatRelation(r))
+		rf.w.WriteByte('\n')
+	}
+	fmt.Fprintf(rf.w, "q %d\n", q)
+	return rf.w.Flush()
+}
+
+func (rf *relationFile) close() error {
+	err := rf.w.Flush()
+	if cerr := rf.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// validRelation checks that the primes of r are the factorizations of the values
+// of the polynomials.
+func (s *nfs) validRelation(r nfsRelation) bool {
+	if r.b <= 0 || gcd64(r.a, r.b) != 1 {
+		return false
+	}
+	v := new(big.Int).Mul(s.poly.G[1], big.NewInt(r.a))
+	v.Add(v, new(big.Int).Mul(s.poly.G[0], big.NewInt(r.b)))
+	if !isProduct(v, r.rat) {
+		return false
+	}
+	return isProduct(s.algValue(r.a, r.b), r.alg)
+}
+
+func isProduct(v *big.Int, ps []uint64) bool {
+	prod := big.NewInt(1)
+	t := new(big.Int)
+	for _, p := range ps {
+		if !isPrime64(p) {
+			return false
+		}
+		prod.Mul(prod, t.SetUint64(p))
+	}
+	return prod.Cmp(t.Abs(v)) == 0
+}
@@ -0,0 +1,478 @@
+package intfact
+
+import (
+	"math"
+	"math/big"
+	"sort"
+)
+
+// nfsFB is a factor base of the number field sieve: the pairs (p, r) with
+// a - b*r = 0 modulo p for the roots r of the polynomial modulo p.
+// The projective root, i.e. b = 0 modulo p, is stored as r = p.
+type nfsFB struct {
+	p    []uint32
+	r    []uint32
+	logp []uint8
+}
+
+func (fb *nfsFB) add(p, r uint32) {
+	fb.p = append(fb.p, p)
+	fb.r = append(fb.r, r)
+	fb.logp = append(fb.logp, uint8(math.Round(math.Log2(float64(p)))))
+}
+
+// nfsRelation is a pair (a, b) with smooth values of both polynomials.
+// rat and alg are the prime factors of the rational and the algebraic value
+// with multiplicity, including the large primes and the special-q.
+type nfsRelation struct {
+	a, b     int64
+	rat, alg []uint64
+}
+
+// nfsSmallSieve is the bound for the primes which are not sieved but only trial divided.
+const nfsSmallSieve = 7
+
+// nfsLattice is the lattice of the pairs (a, b) divisible by a special-q,
+// spanned by the reduced vectors (a0, b0) and (a1, b1). The sieve region is
+// the set of the points i*(a0, b0) + j*(a1, b1) with -I/2 <= i < I/2 and 0 <= j < J.
+type nfsLattice struct {
+	q, rho         uint32
+	a0, b0, a1, b1 int64
+}
+
+// newNFSLattice reduces the basis (q, 0), (rho, 1) with the norm a^2/skew + b^2*skew.
+func newNFSLattice(q, rho uint32, skew float64) nfsLattice {
+	u := [2]int64{int64(q), 0}
+	v := [2]int64{int64(rho), 1}
+	dot := func(x, y [2]int64) float64 {
+		return float64(x[0])*float64(y[0])/skew + float64(x[1])*float64(y[1])*skew
+	}
+	if dot(u, u) < dot(v, v) {
+		u, v = v, u
+	}
+	for {
+		k := int64(math.Round(dot(u, v) / dot(v, v)))
+		u[0] -= k * v[0]
+		u[1] -= k * v[1]
+		if dot(u, u) >= dot(v, v) {
+			break
+		}
+		u, v = v, u
+	}
+	return nfsLattice{q: q, rho: rho, a0: v[0], b0: v[1], a1: u[0], b1: u[1]}
+}
+
+// modInt returns x mod p in [0, p).
+func modInt(x int64, p uint32) uint64 {
+	r := x % int64(p)
+	if r < 0 {
+		r += int64(p)
+	}
+	return uint64(r)
+}
+
+// sieveRoot returns the root R of the prime p with root r in lattice coordinates:
+// p divides a - b*r iff i = R*j modulo p. If the lattice points divisible by p
+// are the rows with j = 0 modulo p, ok is false and rows is true; if all points
+// are divisible, both are false.
+func (l *nfsLattice) sieveRoot(p, r uint32) (root uint32, ok, rows bool) {
+	var u, v uint64
+	pp := uint64(p)
+	if r == p {
+		u, v = modInt(l.b0, p), modInt(l.b1, p)
+	} else {
+		u = (modInt(l.a0, p) + pp - modInt(l.b0, p)*uint64(r)%pp) % pp
+		v = (modInt(l.a1, p) + pp - modInt(l.b1, p)*uint64(r)%pp) % pp
+	}
+	if u == 0 {
+		return 0, false, v != 0
+	}
+	return uint32((pp - v) % pp * uint64(invMod(uint32(u), p)) % pp), true, false
+}
+
+// fkBasis returns the basis (a0, b0), (a1, b1) of the lattice x = R*y modulo p
+// with -I < a0 <= 0 <= a1 < I, a1 - a0 >= I and b0, b1 > 0 for p >= I and 0 < R < p.
+// By Franke and Kleinjung the next lattice point in the strip 0 <= x < I with a
+// larger y is the current point plus (a0, b0), (a1, b1) or their sum.
+func fkBasis(p, R uint32, I int64) (a0, b0, a1, b1 int64) {
+	a0, b0, a1, b1 = -int64(p), 0, int64(R), 1
+	for {
+		if a1 < I {
+			if a0 <= -I {
+				k := (-I-a0)/a1 + 1
+				a0 += k * a1
+				b0 += k * b1
+			}
+			return
+		}
+		if -a0 < I {
+			k := (a1-I)/(-a0) + 1
+			a1 += k * a0
+			b1 += k * b0
+			return
+		}
+		if a1 >= -a0 {
+			k := a1 / (-a0)
+			a1 += k * a0
+			b1 += k * b0
+		} else {
+			k := (-a0) / a1
+			a0 += k * a1
+			b0 += k * b1
+		}
+	}
+}
+
+// sieveSide adds the logarithms of the factor base primes to the sieve array
+// of width I and height J. If hit is not nil, it is called instead for the points
+// of the primes of at least I which are marked in the array.
+func (l *nfsLattice) sieveSide(fb *nfsFB, arr []uint8, logI uint, J int, hit func(cell int, k int)) {
+	I := 1 << logI
+	half := I / 2
+	for k, p := range fb.p {
+		if p < nfsSmallSieve || (hit != nil && int(p) < I) {
+			continue
+		}
+		R, ok, rows := l.sieveRoot(p, fb.r[k])
+		lp := fb.logp[k]
+		if !ok {
+			if !rows {
+				continue
+			}
+			for j := int(p); j < J; j += int(p) {
+				row := arr[j*I : (j+1)*I]
+				for x := range row {
+					if hit == nil {
+						row[x] += lp
+					} else if row[x] != 0 {
+						hit(j*I+x, k)
+					}
+				}
+			}
+			continue
+		}
+		if int(p) < I {
+			start := uint32(half) % p
+			for j := 1; j < J; j++ {
+				start += R
+				if start >= p {
+					start -= p
+				}
+				row := arr[j*I : (j+1)*I]
+				for x := int(start); x < I; x += int(p) {
+					row[x] += lp
+				}
+			}
+			continue
+		}
+		if R == 0 {
+			for j := 1; j < J; j++ {
+				if c := j*I + half; hit == nil {
+					arr[c] += lp
+				} else if arr[c] != 0 {
+					hit(c, k)
+				}
+			}
+			continue
+		}
+		a0, b0, a1, b1 := fkBasis(p, R, int64(I))
+		x, y := int64(half), int64(0)
+		for {
+			switch {
+			case x >= -a0:
+				x += a0
+				y += b0
+			case x < int64(I)-a1:
+				x += a1
+				y += b1
+			default:
+				x += a0 + a1
+				y += b0 + b1
+			}
+			if y >= int64(J) {
+				break
+			}
+			if c := int(y)<<logI + int(x); hit == nil {
+				arr[c] += lp
+			} else if arr[c] != 0 {
+				hit(c, k)
+			}
+		}
+	}
+}
+
+// nfsCandidate is a sieve point that passed both thresholds.
+type nfsCandidate struct {
+	cell     int
+	a, b     int64
+	rat, alg []uint32
+}
+
+// sieveQ returns the relations found with the special-q (q, rho).
+func (s *nfs) sieveQ(q, rho uint32) []nfsRelation {
+	l := newNFSLattice(q, rho, s.skew)
+	logI := uint(s.o.LogI)
+	I := 1 << logI
+	J := I / 2
+	half := I / 2
+	if len(s.ratArr) != I*J {
+		s.ratArr = make([]uint8, I*J)
+		s.algArr = make([]uint8, I*J)
+	}
+	for i := range s.ratArr {
+		s.ratArr[i] = 0
+		s.algArr[i] = 0
+	}
+	l.sieveSide(&s.rfb, s.ratArr, logI, J, nil)
+	l.sieveSide(&s.afb, s.algArr, logI, J, nil)
+
+	// the values in lattice coordinates: G(a, b) = i*ru + j*rv
+	a0, b0, a1, b1 := float64(l.a0), float64(l.b0), float64(l.a1), float64(l.b1)
+	ru := s.g1f*a0 + s.g0f*b0
+	rv := s.g1f*a1 + s.g0f*b1
+	slack := float64(s.lpCount*s.o.LargePrimeBits) + 3
+	logq := math.Log2(float64(q))
+	d := len(s.ff) - 1
+	var cands []nfsCandidate
+	for j := 1; j < J; j++ {
+		row := j << logI
+		for x := 0; x < I; x++ {
+			c := row + x
+			i := float64(x - half)
+			_, e := math.Frexp(i*ru + float64(j)*rv)
+			if float64(s.ratArr[c]) < float64(e)-slack {
+				continue
+			}
+			// F(a, b) by Horner
+			fa := i*a0 + float64(j)*a1
+			fb := i*b0 + float64(j)*b1
+			v := s.ff[d]
+			bp := 1.0
+			for k := d - 1; k >= 0; k-- {
+				bp *= fb
+				v = v*fa + s.ff[k]*bp
+			}
+			_, e = math.Frexp(v)
+			if float64(s.algArr[c]) < float64(e)-logq-slack {
+				continue
+			}
+			ai := int64(x-half)*l.a0 + int64(j)*l.a1
+			bi := int64(x-half)*l.b0 + int64(j)*l.b1
+			if bi < 0 {
+				ai, bi = -ai, -bi
+			}
+			if bi == 0 || gcd64(ai, bi) != 1 {
+				continue
+			}
+			cands = append(cands, nfsCandidate{cell: c, a: ai, b: bi})
+		}
+	}
+	if len(cands) == 0 {
+		return nil
+	}
+	// resieve the large primes to find the divisors of the candidates
+	idx := make(map[int]int, len(cands))
+	for i := range s.ratArr {
+		s.ratArr[i] = 0
+	}
+	for k, c := range cands {
+		idx[c.cell] = k
+		s.ratArr[c.cell] = 1
+	}
+	l.sieveSide(&s.rfb, s.ratArr, logI, J, func(cell, k int) {
+		c := &cands[idx[cell]]
+		c.rat = append(c.rat, s.rfb.p[k])
+	})
+	l.sieveSide(&s.afb, s.ratArr, logI, J, func(cell, k int) {
+		c := &cands[idx[cell]]
+		c.alg = append(c.alg, s.afb.p[k])
+	})
+	rr := l.smallRoots(&s.rfb, I)
+	ar := l.smallRoots(&s.afb, I)
+	var rels []nfsRelation
+	for _, c := range cands {
+		if r, ok := s.factorCandidate(c, q, logI, rr, ar); ok {
+			r.alg = append(r.alg, uint64(q))
+			sort.Slice(r.alg, func(i, j int) bool { return r.alg[i] < r.alg[j] })
+			rels = append(rels, r)
+		}
+	}
+	return rels
+}
+
+func gcd64(a, b int64) int64 {
+	if a < 0 {
+		a = -a
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// latRoot is the root of a small factor base prime in lattice coordinates,
+// see sieveRoot. R is -1 if p divides the rows with j = 0 modulo p, and -2 if
+// p divides all points.
+type latRoot struct {
+	p uint32
+	R int32
+}
+
+// smallRoots returns the roots of the primes below I.
+func (l *nfsLattice) smallRoots(fb *nfsFB, I int) []latRoot {
+	var rs []latRoot
+	for k, p := range fb.p {
+		if int(p) >= I {
+			break
+		}
+		R, ok, rows := l.sieveRoot(p, fb.r[k])
+		switch {
+		case ok:
+			rs = append(rs, latRoot{p, int32(R)})
+		case rows:
+			rs = append(rs, latRoot{p, -1})
+		default:
+			rs = append(rs, latRoot{p, -2})
+		}
+	}
+	return rs
+}
+
+// divides reports whether p divides the value at the point (i, j).
+func (lr latRoot) divides(i, j int) bool {
+	switch lr.R {
+	case -1:
+		return j%int(lr.p) == 0
+	case -2:
+		return true
+	}
+	return (i-int(lr.R)*j)%int(lr.p) == 0
+}
+
+// factorCandidate factors the values of the candidate over the factor bases
+// and accepts up to lpCount large primes on each side. The special-q is
+// divided out, but not recorded. rr and ar are the roots of the primes below
+// the sieve width.
+func (s *nfs) factorCandidate(c nfsCandidate, q uint32, logI uint, rr, ar []latRoot) (nfsRelation, bool) {
+	r := nfsRelation{a: c.a, b: c.b}
+	ba, bb := big.NewInt(c.a), big.NewInt(c.b)
+	// the rational value G1*a + G0*b
+	rv := new(big.Int).Mul(s.poly.G[1], ba)
+	rv.Add(rv, new(big.Int).Mul(s.poly.G[0], bb))
+	rv.Abs(rv)
+	I := 1 << logI
+	i, j := c.cell&(I-1)-I/2, c.cell>>logI
+	ps := c.rat
+	for _, lr := range rr {
+		if lr.divides(i, j) {
+			ps = append(ps, lr.p)
+		}
+	}
+	var ok bool
+	if r.rat, ok = s.divideOut(rv, ps); !ok {
+		return r, false
+	}
+	av := s.algValue(c.a, c.b)
+	av.Abs(av)
+	t := new(big.Int).SetUint64(uint64(q))
+	if new(big.Int).Rem(av, t).Sign() != 0 {
+		return r, false
+	}
+	av.Quo(av, t)
+	ps = c.alg
+	for _, lr := range ar {
+		if lr.divides(i, j) {
+			ps = append(ps, lr.p)
+		}
+	}
+	if r.alg, ok = s.divideOut(av, ps); !ok {
+		return r, false
+	}
+	// split the cofactors only if both sides passed the size checks
+	for _, side := range []struct {
+		v     *big.Int
+		bound uint32
+		fs    *[]uint64
+	}{{rv, s.o.RationalBound, &r.rat}, {av, s.o.AlgebraicBound, &r.alg}} {
+		lp, ok := s.largePrimes(side.v, side.bound)
+		if !ok {
+			return r, false
+		}
+		*side.fs = append(*side.fs, lp...)
+	}
+	return r, true
+}
+
+// algValue returns F(a, b) = sum F[i] a^i b^(d-i).
+func (s *nfs) algValue(a, b int64) *big.Int {
+	ba, bb := big.NewInt(a), big.NewInt(b)
+	v := new(big.Int)
+	t := new(big.Int)
+	bpow := big.NewInt(1)
+	d := len(s.poly.F) - 1
+	// Horner in a with increasing powers of b
+	v.Set(s.poly.F[d])
+	for i := d - 1; i >= 0; i-- {
+		bpow.Mul(bpow, bb)
+		v.Mul(v, ba)
+		v.Add(v, t.Mul(s.poly.F[i], bpow))
+	}
+	return v
+}
+
+// divideOut divides v by the primes ps and returns the prime factors with
+// multiplicity. It fails if the cofactor left in v is too large for lpCount
+// large primes.
+func (s *nfs) divideOut(v *big.Int, ps []uint32) ([]uint64, bool) {
+	var fs []uint64
+	q, m := new(big.Int), new(big.Int)
+	bp := new(big.Int)
+	for _, p := range ps {
+		bp.SetUint64(uint64(p))
+		for {
+			q.QuoRem(v, bp, m)
+			if m.Sign() != 0 {
+				break
+			}
+			v.Set(q)
+			fs = append(fs, uint64(p))
+		}
+	}
+	if v.Cmp(bigOne) == 0 {
+		return fs, true
+	}
+	if s.lpCount == 0 || v.BitLen() > s.lpCount*s.o.LargePrimeBits {
+		return nil, false
+	}
+	if s.lpCount == 1 && v.Uint64() > s.lpb {
+		return nil, false
+	}
+	return fs, true
+}
+
+// largePrimes splits a cofactor left by divideOut into primes below the
+// large prime bound. bound is the factor base bound of the side.
+func (s *nfs) largePrimes(v *big.Int, bound uint32) ([]uint64, bool) {
+	if v.Cmp(bigOne) == 0 {
+		return nil, true
+	}
+	x := v.Uint64()
+	if x <= s.lpb {
+		// all primes below the factor base bound are divided out, and lpb is at
+		// most the square of the bound
+		return []uint64{x}, true
+	}
+	// a cofactor below bound^2 is prime, and a rare composite that passes
+	// the test is lost
+	if x < uint64(bound)*uint64(bound) || strongProbablePrime64(x, 2) {
+		return nil, false
+	}
+	f := rho64(x)
+	if f == 0 || f > s.lpb || x/f > s.lpb {
+		return nil, false
+	}
+	if f > x/f {
+		f = x / f
+	}
+	return []uint64{f, x / f}, true
+}
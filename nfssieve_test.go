@@ -0,0 +1,122 @@
+package intfact
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFKBasis(t *testing.T) {
+	const I = 64
+	for _, p := range []uint32{67, 101, 127, 1009, 4099} {
+		for _, R := range []uint32{1, 2, 31, p / 2, p - 1} {
+			a0, b0, a1, b1 := fkBasis(p, R, I)
+			if !(-I < a0 && a0 <= 0 && 0 <= a1 && a1 < I && a1-a0 >= I && b0 > 0 && b1 > 0) {
+				t.Fatalf("p=%v R=%v: invalid basis %v %v %v %v", p, R, a0, b0, a1, b1)
+			}
+			// enumerate the points x = R*y mod p in the strip 0 <= x < I
+			// and compare with the brute force
+			x, y := int64(0), int64(0)
+			for y < 3*int64(p) {
+				var nx, ny int64
+				for ny = y + 1; ; ny++ {
+					nx = (int64(R) * ny) % int64(p)
+					if nx < I {
+						break
+					}
+				}
+				switch {
+				case x >= -a0:
+					x += a0
+					y += b0
+				case x < I-a1:
+					x += a1
+					y += b1
+				default:
+					x += a0 + a1
+					y += b0 + b1
+				}
+				if x != nx || y != ny {
+					t.Fatalf("p=%v R=%v: got (%v, %v), want (%v, %v)", p, R, x, y, nx, ny)
+				}
+			}
+		}
+	}
+}
+
+func TestNFSLattice(t *testing.T) {
+	const q, rho = 10007, 1234
+	l := newNFSLattice(q, rho, 100)
+	for _, v := range [][2]int64{{l.a0, l.b0}, {l.a1, l.b1}} {
+		if modInt(v[0]-v[1]*rho, q) != 0 {
+			t.Errorf("%v is not in the lattice", v)
+		}
+	}
+	if det := l.a0*l.b1 - l.a1*l.b0; det != q && det != -q {
+		t.Errorf("determinant %v", det)
+	}
+	// the roots in lattice coordinates
+	for _, p := range []uint32{3, 5, 7, 101, 2003} {
+		for _, r := range []uint32{0, 2, p - 1, p} {
+			R, ok, rows := l.sieveRoot(p, r)
+			for i := int64(-20); i < 20; i++ {
+				for j := int64(0); j < 20; j++ {
+					a := i*l.a0 + j*l.a1
+					b := i*l.b0 + j*l.b1
+					var div bool
+					if r == p {
+						div = modInt(b, p) == 0
+					} else {
+						div = modInt(a-b*int64(r), p) == 0
+					}
+					var want bool
+					switch {
+					case ok:
+						want = modInt(i-int64(R)*j, p) == 0
+					case rows:
+						want = j%int64(p) == 0
+					default:
+						want = true
+					}
+					if div != want {
+						t.Fatalf("p=%v r=%v at (%v, %v): divisible %v, root %v %v %v", p, r, i, j, div, R, ok, rows)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSieveQ(t *testing.T) {
+	n := intval("84055899507848841139275608657")
+	o, err := (*NFSOptions)(nil).withDefaults(n)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	poly, err := nfsSelectPoly(n, o.Degree, 20)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	s := &nfs{n: n, o: o, poly: poly, seen: make(map[[2]int64]bool)}
+	if fac := s.init(); fac != nil {
+		t.Fatal("unexpected factor", fac)
+	}
+	q := uint32(10007)
+	for ; ; q++ {
+		if big.NewInt(int64(q)).ProbablyPrime(0) && len(ppRoots(ppFromBig(poly.F, q), uint64(q))) > 0 {
+			break
+		}
+	}
+	rho := ppRoots(ppFromBig(poly.F, q), uint64(q))[0]
+	rels := s.sieveQ(q, uint32(rho))
+	if len(rels) == 0 {
+		t.Fatal("no relations")
+	}
+	for _, r := range rels {
+		if !s.validRelation(r) {
+			t.Fatalf("invalid relation %v", formatRelation(r))
+		}
+		if modInt(r.a-r.b*int64(rho), q) != 0 {
+			t.Fatalf("relation %v is not divisible by q", formatRelation(r))
+		}
+	}
+}
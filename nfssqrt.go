@@ -0,0 +1,301 @@
+package intfact
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/cmplx"
+)
+
+// errNoSquare is returned by nfsSqrt if the product of a dependency is not a
+// square in the number field. Another dependency may succeed.
+var errNoSquare = errors.New("no square root")
+
+// nfsMonic returns the monic polynomial c^(d-1) F(x/c) for the leading coefficient c of F.
+// Its root c*alpha is an algebraic integer.
+func nfsMonic(f []*big.Int) []*big.Int {
+	d := len(f) - 1
+	c := f[d]
+	fh := make([]*big.Int, d+1)
+	cp := big.NewInt(1)
+	for i := d - 1; i >= 0; i-- {
+		fh[i] = new(big.Int).Mul(f[i], cp)
+		cp.Mul(cp, c)
+	}
+	fh[d] = big.NewInt(1)
+	return fh
+}
+
+// bpMulMod returns a*b modulo the monic polynomial fh and modulo M.
+func bpMulMod(a, b, fh []*big.Int, M *big.Int) []*big.Int {
+	d := len(fh) - 1
+	h := make([]*big.Int, 2*d-1)
+	for i := range h {
+		h[i] = new(big.Int)
+	}
+	t := new(big.Int)
+	for i, x := range a {
+		for j, y := range b {
+			h[i+j].Add(h[i+j], t.Mul(x, y))
+		}
+	}
+	for i := len(h) - 1; i >= d; i-- {
+		c := h[i].Mod(h[i], M)
+		for j := 0; j < d; j++ {
+			h[i-d+j].Sub(h[i-d+j], t.Mul(c, fh[j]))
+		}
+	}
+	h = h[:d]
+	for _, c := range h {
+		c.Mod(c, M)
+	}
+	return h
+}
+
+// nfsSqrt returns X and Y with X^2 = Y^2 modulo n for the relations of a dependency.
+// The rational side is a square of integers, Y is computed from the exponents of the
+// primes. On the algebraic side the square root of f'(alpha)^2 times the product of
+// the a - b*alpha is computed modulo a power of an inert prime by Newton iteration
+// and mapped to Z/nZ with alpha -> m.
+func (s *nfs) nfsSqrt(dep []int) (x, y *big.Int, err error) {
+	n := s.n
+	k := len(dep)
+	if k%2 != 0 {
+		return nil, nil, errors.New("odd number of relations")
+	}
+	d := s.poly.degree()
+	c := s.poly.F[d]
+	m := s.poly.root(n)
+
+	// rational side
+	exps := make(map[uint64]int)
+	for _, i := range dep {
+		for _, p := range s.rels[i].rat {
+			exps[p]++
+		}
+	}
+	y = big.NewInt(1)
+	bp := new(big.Int)
+	for p, e := range exps {
+		if e%2 != 0 {
+			return nil, nil, errors.New("rational side is no square")
+		}
+		bp.Exp(bp.SetUint64(p), big.NewInt(int64(e/2)), n)
+		y.Mul(y, bp)
+		y.Mod(y, n)
+	}
+	fh := nfsMonic(s.poly.F)
+	cm := new(big.Int).Mul(c, m)
+	cm.Mod(cm, n)
+	// Y' = fh'(cm) c^(k/2) G1^(-k/2) Y
+	t := new(big.Int)
+	dv := new(big.Int)
+	for i := d; i > 0; i-- {
+		dv.Mul(dv, cm)
+		dv.Add(dv, t.Mul(fh[i], big.NewInt(int64(i))))
+		dv.Mod(dv, n)
+	}
+	y.Mul(y, dv)
+	half := big.NewInt(int64(k / 2))
+	y.Mul(y, t.Exp(new(big.Int).Mod(c, n), half, n))
+	g1inv := new(big.Int).ModInverse(new(big.Int).Mod(s.poly.G[1], n), n)
+	y.Mul(y, t.Exp(g1inv, half, n))
+	y.Mod(y, n)
+
+	// algebraic side
+	bits := s.sqrtBits(dep, fh)
+	p, ok := nfsInertPrime(fh, 3<<30)
+	if !ok {
+		return nil, nil, errors.New("no inert prime")
+	}
+	for try := 0; try < 3; try++ {
+		beta, err := s.algSqrt(dep, fh, p, bits)
+		if err != nil {
+			return nil, nil, err
+		}
+		// map to Z/nZ
+		x = new(big.Int)
+		for i := d - 1; i >= 0; i-- {
+			x.Mul(x, cm)
+			x.Add(x, beta[i])
+			x.Mod(x, n)
+		}
+		x2 := new(big.Int).Mul(x, x)
+		y2 := new(big.Int).Mul(y, y)
+		if x2.Sub(x2, y2).Mod(x2, n).Sign() == 0 {
+			return x, y, nil
+		}
+		// the precision was too small
+		bits *= 2
+	}
+	return nil, nil, errors.New("square root failed")
+}
+
+// sqrtBits estimates the size in bits of the coefficients of the square root
+// from the complex embeddings of the product.
+func (s *nfs) sqrtBits(dep []int, fh []*big.Int) int {
+	roots := nfsComplexRoots(fh)
+	d := len(roots)
+	c, _ := new(big.Float).SetInt(s.poly.F[d]).Float64()
+	logMax := math.Inf(-1)
+	for i, r := range roots {
+		// log |fh'(r)|
+		der := complex(1, 0)
+		for j, q := range roots {
+			if j != i {
+				der *= r - q
+			}
+		}
+		l := math.Log(cmplx.Abs(der))
+		for _, k := range dep {
+			rel := s.rels[k]
+			v := complex(c*float64(rel.a), 0) - complex(float64(rel.b), 0)*r
+			l += math.Log(cmplx.Abs(v)) / 2
+		}
+		// the coefficients of the Lagrange polynomial of r
+		for j, q := range roots {
+			if j != i {
+				l += math.Log((1 + cmplx.Abs(q)) / cmplx.Abs(r-q))
+			}
+		}
+		logMax = math.Max(logMax, l)
+	}
+	return int((logMax+math.Log(float64(d)))/math.Ln2) + 64
+}
+
+// algSqrt returns the square root of fh'(x)^2 times the product of the c*a - b*x modulo fh
+// with coefficients in the symmetric range of p^k for p^k > 2^bits.
+func (s *nfs) algSqrt(dep []int, fh []*big.Int, p uint32, bits int) ([]*big.Int, error) {
+	d := len(fh) - 1
+	bpr := big.NewInt(int64(p))
+	// precisions p^(2^i) up to the final one
+	mods := []*big.Int{new(big.Int).Set(bpr)}
+	for mods[len(mods)-1].BitLen() <= bits+1 {
+		last := mods[len(mods)-1]
+		mods = append(mods, new(big.Int).Mul(last, last))
+	}
+	M := mods[len(mods)-1]
+	// gamma = fh'(x)^2 * product of (c*a - b*x)
+	der := make([]*big.Int, d)
+	for i := range der {
+		der[i] = new(big.Int).Mul(fh[i+1], big.NewInt(int64(i+1)))
+	}
+	gamma := bpMulMod(der, der, fh, M)
+	c := s.poly.F[d]
+	u, v, t := new(big.Int), new(big.Int), new(big.Int)
+	for _, k := range dep {
+		rel := s.rels[k]
+		u.Mul(c, big.NewInt(rel.a))
+		v.SetInt64(rel.b)
+		// multiply by u - v*x and reduce with the monic fh
+		top := new(big.Int).Mul(gamma[d-1], v)
+		top.Neg(top)
+		for i := d - 1; i > 0; i-- {
+			gamma[i].Mul(gamma[i], u)
+			gamma[i].Sub(gamma[i], t.Mul(gamma[i-1], v))
+		}
+		gamma[0].Mul(gamma[0], u)
+		top.Mod(top, M)
+		for i := 0; i < d; i++ {
+			gamma[i].Sub(gamma[i], t.Mul(top, fh[i]))
+			gamma[i].Mod(gamma[i], M)
+		}
+	}
+	// the inverse square root modulo p in GF(p^d)
+	fp := ppFromBig(fh, p)
+	gp := make(pPoly, d)
+	for i, a := range gamma {
+		gp[i] = uint64(bigModSmall(a, p))
+	}
+	gp = ppTrim(gp)
+	if len(gp) == 0 {
+		return nil, errNoSquare
+	}
+	sq, ok := gfSqrt(gp, fp, uint64(p))
+	if !ok {
+		return nil, errNoSquare
+	}
+	q := new(big.Int).Exp(bpr, big.NewInt(int64(d)), nil)
+	inv := ppPowMod(sq, q.Sub(q, big.NewInt(2)), fp, uint64(p))
+	r := make([]*big.Int, d)
+	for i := range r {
+		r[i] = new(big.Int)
+		if i < len(inv) {
+			r[i].SetUint64(inv[i])
+		}
+	}
+	// Newton iteration r = r + r(1 - gamma r^2)/2 for the inverse square root
+	for _, mod := range mods[1:] {
+		g := make([]*big.Int, d)
+		for i := range g {
+			g[i] = new(big.Int).Mod(gamma[i], mod)
+		}
+		e := bpMulMod(g, bpMulMod(r, r, fh, mod), fh, mod)
+		for i := range e {
+			e[i].Neg(e[i])
+		}
+		e[0].Add(e[0], bigOne)
+		e = bpMulMod(r, e, fh, mod)
+		half := new(big.Int).ModInverse(big.NewInt(2), mod)
+		for i := range r {
+			t.Mul(e[i], half)
+			r[i].Add(r[i], t)
+			r[i].Mod(r[i], mod)
+		}
+	}
+	beta := bpMulMod(gamma, r, fh, M)
+	halfM := new(big.Int).Rsh(M, 1)
+	for _, b := range beta {
+		if b.Cmp(halfM) > 0 {
+			b.Sub(b, M)
+		}
+	}
+	return beta, nil
+}
+
+// gfSqrt returns a square root of a in GF(p^d) = GF(p)[x]/(f) with the
+// Tonelli-Shanks algorithm, or false if a is not a square.
+func gfSqrt(a, f pPoly, p uint64) (pPoly, bool) {
+	d := len(f) - 1
+	q := new(big.Int).Exp(new(big.Int).SetUint64(p), big.NewInt(int64(d)), nil)
+	q1 := new(big.Int).Sub(q, bigOne)
+	e := 0
+	for q1.Bit(e) == 0 {
+		e++
+	}
+	t := new(big.Int).Rsh(q1, uint(e))
+	isOne := func(x pPoly) bool {
+		return len(x) == 1 && x[0] == 1
+	}
+	// a non-residue z
+	var z pPoly
+	hq := new(big.Int).Rsh(q1, 1)
+	for delta := uint64(1); ; delta++ {
+		z = pPoly{delta % p, 1}
+		if !isOne(ppPowMod(z, hq, f, p)) {
+			break
+		}
+	}
+	cz := ppPowMod(z, t, f, p)
+	tt := ppPowMod(a, t, f, p)
+	r := ppPowMod(a, new(big.Int).Rsh(new(big.Int).Add(t, bigOne), 1), f, p)
+	for mm := e; !isOne(tt); {
+		// the least i with tt^(2^i) = 1
+		i := 0
+		for x := tt; !isOne(x); i++ {
+			x = ppMulMod(x, x, f, p)
+		}
+		if i == mm {
+			return nil, false
+		}
+		b := cz
+		for j := 0; j < mm-i-1; j++ {
+			b = ppMulMod(b, b, f, p)
+		}
+		mm = i
+		cz = ppMulMod(b, b, f, p)
+		tt = ppMulMod(tt, cz, f, p)
+		r = ppMulMod(r, b, f, p)
+	}
+	return r, true
+}
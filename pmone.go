@@ -4,44 +4,105 @@ import (
 	"context"
 	"errors"
 	"github.com/ghhenry/primes"
+	"math"
 	"math/big"
 )
 
-type p2helper struct {
-	m      *big.Int
-	powers []*big.Int
-}
+// Phase2Algorithm selects the phase2 of PmOneWithOptions.
+type Phase2Algorithm int
 
-func newHelper(a, n *big.Int) *p2helper {
-	a2 := new(big.Int).Mul(a, a)
-	a2.Mod(a2, n)
-	return &p2helper{
-		m:      n,
-		powers: []*big.Int{a2},
-	}
+const (
+	// BabyGiant processes the primes of phase2 one at a time with baby-step giant-step differences.
+	BabyGiant Phase2Algorithm = iota
+	// Polynomial evaluates the product of all baby step differences as a polynomial
+	// at a batch of giant steps with a product tree and multipoint evaluation (FFT continuation).
+	// It covers all numbers coprime to D up to the bound and does not enumerate primes,
+	// which makes bounds of 10^12 and more feasible.
+	Polynomial
+)
+
+// PmOneOptions contains the parameters of PmOneWithOptions.
+// A nil pointer or zero fields select the defaults.
+type PmOneOptions struct {
+	// D is the giant step of phase2. It must be even and at least 4.
+	// A product of small primes like 210 or 2310 keeps the number of baby steps small.
+	// The default is 2310 for BabyGiant and depends on the bounds for Polynomial.
+	D uint32
+	// Phase2 is the phase2 algorithm (default BabyGiant).
+	Phase2 Phase2Algorithm
+	// B2 replaces the phase2 bound b1 if it is not zero.
+	// Bounds of 2^32 and more require the Polynomial phase2.
+	B2 uint64
 }
 
-func (h *p2helper) getPower(e uint32) *big.Int {
-	i := int(e/2 - 1)
-	for i >= len(h.powers) {
-		k := new(big.Int).Mul(h.powers[0], h.powers[len(h.powers)-1])
-		k.Mod(k, h.m)
-		h.powers = append(h.powers, k)
-	}
-	return h.powers[i]
+func (o *PmOneOptions) withDefaults() (PmOneOptions, error) {
+	var r PmOneOptions
+	if o != nil {
+		r = *o
+	}
+	switch r.Phase2 {
+	case BabyGiant:
+		if r.D == 0 {
+			r.D = defaultD
+		}
+		if r.B2 > math.MaxUint32 {
+			return r, errors.New("B2 too large for BabyGiant")
+		}
+	case Polynomial:
+		if r.D == 0 {
+			return r, nil
+		}
+	default:
+		return r, errors.New("unknown phase2 algorithm")
+	}
+	if r.D < 4 || r.D%2 != 0 {
+		return r, errors.New("D must be even and at least 4")
+	}
+	return r, nil
 }
 
 // PmOne tries to find a factor of n using Pollard's p-1 method.
 // b and b1 are the prime bounds used in phase1 and phase2 respectively.
+// It is PmOneWithOptions with the default options.
 //
-// The function returns a factor if one was found or otherwise an error.
+// The function returns a factor if one was found or otherwise ErrNoFactor, ErrTrivialGCD
+// or an error matching ErrCancelled.
 func PmOne(ctx context.Context, n *big.Int, b, b1 uint32) (fac *big.Int, err error) {
+	return PmOneWithOptions(ctx, n, b, b1, nil)
+}
+
+// PmOneWithOptions is PmOne with a choice of the phase2 parameters.
+// Phase2 uses baby steps V_j = a^j + a^-j and giant steps V_kD, where a is the result of phase1.
+// The difference V_kD - V_j vanishes modulo p if a^(kD-j) = 1 or a^(kD+j) = 1,
+// so one product covers both primes kD ± j. The BabyGiant phase2 takes these products
+// for the primes only, the Polynomial phase2 for all kD ± j with j coprime to D.
+// For odd n < 2^64, a phase2 bound below 2^32 and the default Phase2 and D both phases
+// run with Montgomery arithmetic on uint64, where phase2 takes a product for each prime.
+// Other choices of Phase2 and D are honoured with big.Int arithmetic.
+func PmOneWithOptions(ctx context.Context, n *big.Int, b, b1 uint32, opts *PmOneOptions) (fac *big.Int, err error) {
+	o, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	b2 := uint64(b1)
+	if o.B2 != 0 {
+		b2 = o.B2
+	}
+	defaults := opts == nil || opts.Phase2 == BabyGiant && opts.D == 0
+	if defaults && fitsUint64(n) && b2 <= math.MaxUint32 {
+		return pmOneUint64(ctx, n.Uint64(), b, b2)
+	}
+	return pmOneBig(ctx, n, b, b2, o)
+}
+
+// pmOneBig is PmOneWithOptions with big.Int arithmetic.
+func pmOneBig(ctx context.Context, n *big.Int, b uint32, b2 uint64, o PmOneOptions) (fac *big.Int, err error) {
 	var a = big.NewInt(3)
 	gcd := newGcdtest(n, 20)
 	phase1 := func(p uint32) bool {
 		select {
 		case <-ctx.Done():
-			err = errors.New("cancelled")
+			err = cancelled(ctx)
 			return true
 		default:
 		}
@@ -67,37 +128,143 @@ func PmOne(ctx context.Context, n *big.Int, b, b1 uint32) (fac *big.Int, err err
 	}
 
 	// phase2
-	var prev uint32
-	h := newHelper(a, n)
-	phase2 := func(p uint32) bool {
+	fac, err = gcd.finish()
+	if fac != nil || err != nil {
+		return
+	}
+	ainv, err := inverse(a, n)
+	if err != nil {
+		return foundFactor(err, n)
+	}
+	// x = a + 1/a, V_j = V_j(x)
+	x := new(big.Int).Add(a, ainv)
+	x.Mod(x, n)
+	if o.Phase2 == Polynomial {
+		return pmOnePoly(ctx, x, n, uint64(b), b2, o.D)
+	}
+	d := o.D
+	baby := lucasBaby(x, n, d)
+	vd := lucasV(x, uint64(d), n)
+	var last uint32
+	var gk, gk1 *big.Int
+	giant := func(k uint32) (*big.Int, error) {
+		if gk == nil || k != last+1 {
+			gk = lucasV(x, uint64(k)*uint64(d), n)
+			if k == 0 {
+				// V_{-d} = V_d
+				gk1 = vd
+			} else {
+				gk1 = lucasV(x, uint64(k-1)*uint64(d), n)
+			}
+		} else {
+			// V_{(k+1)d} = V_kd V_d - V_{(k-1)d}
+			next := new(big.Int).Mul(gk, vd)
+			next.Sub(next, gk1)
+			next.Mod(next, n)
+			gk, gk1 = next, gk
+		}
+		last = k
+		return gk, nil
+	}
+	fac, _, err = bsgsPhase2(ctx, n, b, uint32(b2), d, baby, giant)
+	return
+}
+
+// lucasBaby returns V_j(x) for the baby steps j of babySteps(d), indexed by j.
+func lucasBaby(x, n *big.Int, d uint32) []*big.Int {
+	baby := make([]*big.Int, d/2+1)
+	for _, j := range babySteps(d) {
+		baby[j] = new(big.Int)
+	}
+	// V_{j+1} = x V_j - V_{j-1}
+	prev, cur := big.NewInt(2), x
+	for j := range baby[1:] {
+		if baby[j+1] != nil {
+			baby[j+1].Set(cur)
+		}
+		next := new(big.Int).Mul(cur, x)
+		next.Sub(next, prev)
+		next.Mod(next, n)
+		prev, cur = cur, next
+	}
+	return baby
+}
+
+// polyD lists the candidates for the default D of the Polynomial phase2.
+var polyD = []uint32{210, 2310, 30030, 510510}
+
+// pmOnePoly runs the Polynomial phase2 of PmOneWithOptions for the bounds (b, b2].
+// F(X) is the product of X - V_j over the baby steps j. F(V_kD) vanishes modulo p
+// if the order of a modulo p is kD ± j, so the values of F at the giant steps V_kD
+// are multiplied into the gcd test. The giant steps are processed in batches of
+// deg F points.
+func pmOnePoly(ctx context.Context, x, n *big.Int, b, b2 uint64, d uint32) (fac *big.Int, err error) {
+	if b >= b2 {
+		return nil, ErrNoFactor
+	}
+	if d == 0 {
+		// the smallest D that needs a single batch, or the largest one
+		for _, d = range polyD {
+			if (b2-b)/uint64(d) <= uint64(len(babySteps(d))) {
+				break
+			}
+		}
+	}
+	js := babySteps(d)
+	baby := lucasBaby(x, n, d)
+	roots := make([]*big.Int, len(js))
+	for i, j := range js {
+		roots[i] = baby[j]
+	}
+	f := newPolyTree(roots, n).root()
+	// the primes q in (b, b2] have q = kD ± j with k in [k0, k1]
+	k0 := (b + 1 + uint64(d/2)) / uint64(d)
+	k1 := (b2 + uint64(d/2)) / uint64(d)
+	gcd := newGcdtest(n, len(js))
+	if k0 == 0 {
+		// the primes up to D/2 are baby steps themselves, and V_q - V_0 vanishes if a^q = 1
+		top := uint64(d / 2)
+		if top > b2 {
+			top = b2
+		}
+		primes.Iterate(uint32(b+1), uint32(top), func(q uint32) bool {
+			fac, err = gcd.test(new(big.Int).Sub(baby[q], bigTwo))
+			return fac != nil || err != nil
+		})
+		if fac != nil || err != nil {
+			return
+		}
+		k0 = 1
+	}
+	// V_{(k+1)D} = V_kD V_D - V_{(k-1)D}
+	vd := lucasV(x, uint64(d), n)
+	gk := lucasV(x, k0*uint64(d), n)
+	gk1 := lucasV(x, (k0-1)*uint64(d), n)
+	batch := make([]*big.Int, 0, len(js))
+	for k := k0; k <= k1; {
 		select {
 		case <-ctx.Done():
-			err = errors.New("cancelled")
-			return true
+			return nil, cancelled(ctx)
 		default:
 		}
-		if prev == 0 {
-			a.Exp(a, big.NewInt(int64(p)), n)
-		} else {
-			diff := p - prev
-			a.Mul(a, h.getPower(diff))
-			a.Mod(a, n)
+		batch = batch[:0]
+		for ; k <= k1 && len(batch) < cap(batch); k++ {
+			batch = append(batch, gk)
+			next := new(big.Int).Mul(gk, vd)
+			next.Sub(next, gk1)
+			next.Mod(next, n)
+			gk, gk1 = next, gk
 		}
-		d := new(big.Int).Sub(a, bigOne)
-		fac, err = gcd.test(d)
-		if fac != nil || err != nil {
-			return true
+		for _, v := range newPolyTree(batch, n).eval(f, n) {
+			fac, err = gcd.test(v)
+			if fac != nil || err != nil {
+				return
+			}
 		}
-		prev = p
-		return false
-	}
-	primes.Iterate(b+1, b1, phase2)
-	if fac != nil || err != nil {
-		return
 	}
 	fac, err = gcd.finish()
 	if fac != nil || err != nil {
 		return
 	}
-	return nil, errors.New("no factor found")
+	return nil, ErrNoFactor
 }
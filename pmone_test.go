@@ -2,6 +2,7 @@ package intfact
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"reflect"
 	"testing"
@@ -81,6 +82,82 @@ func TestPmOne(t *testing.T) {
 			if !reflect.DeepEqual(gotFac, tt.wantFac) {
 				t.Errorf("PmOne() gotFac = %v, want %v", gotFac, tt.wantFac)
 			}
+			// the polynomial phase2 finds the same factors
+			gotFac, err = PmOneWithOptions(tt.args.ctx, tt.args.n, tt.args.b, tt.args.b1, &PmOneOptions{Phase2: Polynomial})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PmOneWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotFac, tt.wantFac) {
+				t.Errorf("PmOneWithOptions() gotFac = %v, want %v", gotFac, tt.wantFac)
+			}
 		})
 	}
 }
+
+func TestPmOneErrors(t *testing.T) {
+	// 3607-1 = 2*3*601 and 3803-1 = 2*1901 are not smooth enough
+	_, err := PmOne(context.Background(), big.NewInt(3607*3803), 10, 100)
+	if err != ErrNoFactor {
+		t.Errorf("got %v, want ErrNoFactor", err)
+	}
+	// both p-1 are 1000-smooth
+	_, err = PmOne(context.Background(), big.NewInt(41*43), 1000, 1000)
+	if err != ErrTrivialGCD {
+		t.Errorf("got %v, want ErrTrivialGCD", err)
+	}
+}
+
+func TestPmOneWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       *big.Int
+		b, b1   uint32
+		wantFac int64
+	}{
+		// 11-1 = 2*5, 5 divides D
+		{"divisor of D", big.NewInt(11 * 3803), 2, 100, 11},
+		// 3607-1 = 2*3*601
+		{"phase2", big.NewInt(3607 * 3803), 10, 700, 3607},
+		// 7420146347-1 = 2*503*853*8647, 5824327-1 = 2*3*970721
+		{"n1", big.NewInt(43217358712783469), 900, 100000, 7420146347},
+	}
+	for _, tt := range tests {
+		for _, alg := range []Phase2Algorithm{BabyGiant, Polynomial} {
+			for _, d := range []uint32{0, 4, 30, 210, 2310, 30030} {
+				t.Run(fmt.Sprintf("%s %d D=%d", tt.name, alg, d), func(t *testing.T) {
+					fac, err := PmOneWithOptions(context.Background(), tt.n, tt.b, tt.b1, &PmOneOptions{D: d, Phase2: alg})
+					if err != nil {
+						t.Fatal("unexpected error", err)
+					}
+					if fac.Int64() != tt.wantFac {
+						t.Errorf("got %v, want %v", fac, tt.wantFac)
+					}
+				})
+			}
+		}
+	}
+	_, err := PmOneWithOptions(context.Background(), big.NewInt(3607*3803), 10, 700, &PmOneOptions{D: 15})
+	if err == nil {
+		t.Error("expected an error for odd D")
+	}
+	_, err = PmOneWithOptions(context.Background(), big.NewInt(3607*3803), 10, 700, &PmOneOptions{B2: 1 << 32})
+	if err == nil {
+		t.Error("expected an error for B2 >= 2^32 with BabyGiant")
+	}
+}
+
+func TestPmOnePolynomialB2(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long test in short mode")
+	}
+	// p-1 = 2^2*3*5*7^2*11*13*997*9000000001, q-1 = 2*500000000273
+	n := intval("3772428662482677218249279831327")
+	fac, err := PmOneWithOptions(context.Background(), n, 1000, 1000, &PmOneOptions{Phase2: Polynomial, B2: 1e10})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if fac.Cmp(intval("3772428660419158741")) != 0 {
+		t.Errorf("got %v, want 3772428660419158741", fac)
+	}
+}
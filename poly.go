@@ -0,0 +1,221 @@
+package intfact
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// poly is a polynomial over Z/nZ, lowest coefficient first.
+// The coefficients are kept in [0, n).
+type poly []*big.Int
+
+// polySmall is the size below which the schoolbook algorithms are used.
+const polySmall = 16
+
+func (f poly) degree() int {
+	return len(f) - 1
+}
+
+// polyMul returns f*g modulo n.
+// Large products use Kronecker substitution: the coefficients are packed into one
+// big integer with enough space between them, so that a single big.Int multiplication
+// does the work.
+func polyMul(f, g poly, n *big.Int) poly {
+	if len(f) == 0 || len(g) == 0 {
+		return nil
+	}
+	if len(f) < polySmall || len(g) < polySmall {
+		return polyMulSchool(f, g, n)
+	}
+	l := len(f)
+	if len(g) < l {
+		l = len(g)
+	}
+	// a coefficient of the product is less than l*n^2
+	w := (2*n.BitLen() + bits.Len(uint(l)) + bits.UintSize) / bits.UintSize
+	a := polyPack(f, w)
+	a.Mul(a, polyPack(g, w))
+	ab := a.Bits()
+	h := make(poly, len(f)+len(g)-1)
+	for i := range h {
+		c := new(big.Int)
+		if lo := i * w; lo < len(ab) {
+			hi := lo + w
+			if hi > len(ab) {
+				hi = len(ab)
+			}
+			c.SetBits(append([]big.Word(nil), ab[lo:hi]...))
+			c.Mod(c, n)
+		}
+		h[i] = c
+	}
+	return h
+}
+
+func polyPack(f poly, w int) *big.Int {
+	ws := make([]big.Word, len(f)*w)
+	for i, c := range f {
+		copy(ws[i*w:], c.Bits())
+	}
+	return new(big.Int).SetBits(ws)
+}
+
+func polyMulSchool(f, g poly, n *big.Int) poly {
+	h := make(poly, len(f)+len(g)-1)
+	for i := range h {
+		h[i] = new(big.Int)
+	}
+	t := new(big.Int)
+	for i, a := range f {
+		for j, b := range g {
+			h[i+j].Add(h[i+j], t.Mul(a, b))
+		}
+	}
+	for _, c := range h {
+		c.Mod(c, n)
+	}
+	return h
+}
+
+// polyInverse returns the power series inverse of h modulo X^k.
+// The constant coefficient of h must be 1.
+func polyInverse(h poly, k int, n *big.Int) poly {
+	// Newton iteration g' = g(2 - hg)
+	g := poly{big.NewInt(1)}
+	for prec := 1; prec < k; {
+		prec *= 2
+		if prec > k {
+			prec = k
+		}
+		e := polyMul(polyTrunc(h, prec), g, n)
+		e = polyTrunc(e, prec)
+		for i, c := range e {
+			if c.Sign() != 0 {
+				c.Sub(n, c)
+			}
+			if i == 0 {
+				c.Add(c, bigTwo)
+				c.Mod(c, n)
+			}
+		}
+		g = polyTrunc(polyMul(g, e, n), prec)
+	}
+	return g
+}
+
+func polyTrunc(f poly, k int) poly {
+	if len(f) > k {
+		return f[:k]
+	}
+	return f
+}
+
+func polyReverse(f poly, k int) poly {
+	r := make(poly, k)
+	for i := range r {
+		if j := len(f) - 1 - i; j >= 0 {
+			r[i] = f[j]
+		} else {
+			r[i] = new(big.Int)
+		}
+	}
+	return r
+}
+
+// polyRem returns f mod m for a monic polynomial m.
+func polyRem(f, m poly, n *big.Int) poly {
+	dm := m.degree()
+	if f.degree() < dm {
+		return f
+	}
+	ql := f.degree() - dm + 1
+	if ql < polySmall || dm < polySmall {
+		return polyRemSchool(f, m, n)
+	}
+	// the reversed quotient is rev(f)/rev(m) modulo X^ql
+	inv := polyInverse(polyReverse(m, ql), ql, n)
+	rq := polyTrunc(polyMul(polyReverse(f, ql), inv, n), ql)
+	q := polyReverse(rq, ql)
+	qm := polyMul(q, polyTrunc(m, dm), n)
+	r := make(poly, dm)
+	for i := range r {
+		r[i] = new(big.Int).Sub(f[i], qm[i])
+		r[i].Mod(r[i], n)
+	}
+	return r
+}
+
+func polyRemSchool(f, m poly, n *big.Int) poly {
+	dm := m.degree()
+	r := make(poly, len(f))
+	for i, c := range f {
+		r[i] = new(big.Int).Set(c)
+	}
+	t := new(big.Int)
+	for i := len(r) - 1; i >= dm; i-- {
+		q := r[i]
+		if q.Sign() == 0 {
+			continue
+		}
+		for j := 0; j < dm; j++ {
+			c := r[i-dm+j]
+			c.Sub(c, t.Mul(q, m[j]))
+			c.Mod(c, n)
+		}
+	}
+	return r[:dm]
+}
+
+// polyTree is a product tree of the polynomials X - r for the roots r.
+// level 0 holds the linear factors, and each further level holds the products
+// of pairs of the level below. The last level holds the product of all factors.
+type polyTree [][]poly
+
+func newPolyTree(roots []*big.Int, n *big.Int) polyTree {
+	level := make([]poly, len(roots))
+	for i, r := range roots {
+		c := new(big.Int).Neg(r)
+		level[i] = poly{c.Mod(c, n), big.NewInt(1)}
+	}
+	t := polyTree{level}
+	for len(level) > 1 {
+		next := make([]poly, (len(level)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(level) {
+				next[i] = polyMul(level[2*i], level[2*i+1], n)
+			} else {
+				next[i] = level[2*i]
+			}
+		}
+		t = append(t, next)
+		level = next
+	}
+	return t
+}
+
+func (t polyTree) root() poly {
+	return t[len(t)-1][0]
+}
+
+// eval returns the values of f at the roots of the tree.
+// It reduces f modulo the products down the tree (remainder tree).
+func (t polyTree) eval(f poly, n *big.Int) []*big.Int {
+	rems := []poly{polyRem(f, t.root(), n)}
+	for l := len(t) - 2; l >= 0; l-- {
+		level := t[l]
+		next := make([]poly, len(level))
+		for i := range level {
+			next[i] = polyRem(rems[i/2], level[i], n)
+		}
+		rems = next
+	}
+	vals := make([]*big.Int, len(rems))
+	for i, r := range rems {
+		if len(r) == 0 {
+			vals[i] = new(big.Int)
+		} else {
+			vals[i] = r[0]
+		}
+	}
+	return vals
+}
@@ -0,0 +1,85 @@
+package intfact
+
+import (
+	"math/big"
+	"testing"
+)
+
+func randPoly(r *lcRandom, l int, n *big.Int) poly {
+	f := make(poly, l)
+	buf := make([]byte, (n.BitLen()+7)/8+1)
+	for i := range f {
+		r.Read(buf)
+		f[i] = new(big.Int).SetBytes(buf)
+		f[i].Mod(f[i], n)
+	}
+	return f
+}
+
+func polyEqual(f, g poly) bool {
+	for len(f) > 0 && f[len(f)-1].Sign() == 0 {
+		f = f[:len(f)-1]
+	}
+	for len(g) > 0 && g[len(g)-1].Sign() == 0 {
+		g = g[:len(g)-1]
+	}
+	if len(f) != len(g) {
+		return false
+	}
+	for i := range f {
+		if f[i].Cmp(g[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPolyMul(t *testing.T) {
+	r := &lcRandom{x: 1}
+	n := intval("1000000000000000000000000000000000000000000000000000000000007")
+	for _, l := range [][2]int{{1, 1}, {3, 20}, {20, 20}, {33, 100}, {257, 128}} {
+		f, g := randPoly(r, l[0], n), randPoly(r, l[1], n)
+		if !polyEqual(polyMul(f, g, n), polyMulSchool(f, g, n)) {
+			t.Errorf("product of lengths %v differs", l)
+		}
+	}
+}
+
+func TestPolyRem(t *testing.T) {
+	r := &lcRandom{x: 2}
+	n := intval("1000000000000000000000000000000000000000000000000000000000007")
+	for _, l := range [][2]int{{5, 3}, {40, 20}, {100, 40}, {300, 150}, {300, 290}} {
+		f := randPoly(r, l[0], n)
+		m := randPoly(r, l[1], n)
+		m[len(m)-1].SetInt64(1)
+		rem := polyRem(f, m, n)
+		if rem.degree() >= m.degree() {
+			t.Fatalf("%v: degree of remainder %v too large", l, rem.degree())
+		}
+		if !polyEqual(rem, polyRemSchool(f, m, n)) {
+			t.Errorf("%v: remainder differs", l)
+		}
+	}
+}
+
+func TestPolyTreeEval(t *testing.T) {
+	r := &lcRandom{x: 3}
+	n := big.NewInt(1000003)
+	for _, l := range [][2]int{{1, 1}, {10, 7}, {100, 33}, {40, 200}} {
+		f := randPoly(r, l[0], n)
+		pts := randPoly(r, l[1], n)
+		vals := newPolyTree(pts, n).eval(f, n)
+		for i, x := range pts {
+			// Horner
+			v := new(big.Int)
+			for j := len(f) - 1; j >= 0; j-- {
+				v.Mul(v, x)
+				v.Add(v, f[j])
+				v.Mod(v, n)
+			}
+			if vals[i].Cmp(v) != 0 {
+				t.Errorf("%v: f(%v) = %v, want %v", l, x, vals[i], v)
+			}
+		}
+	}
+}
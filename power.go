@@ -0,0 +1,49 @@
+package intfact
+
+import (
+	"math/big"
+)
+
+// PerfectPower returns m and the largest k with n = m^k for n > 1, and n and 1
+// otherwise. It tries the integer k-th roots for the primes k up to log2 n, and
+// again for the root found.
+func PerfectPower(n *big.Int) (m *big.Int, k uint) {
+	m, k = n, 1
+	if n.Cmp(bigOne) <= 0 {
+		return
+	}
+	for p := 2; p < m.BitLen(); p++ {
+		if !isPrime64(uint64(p)) {
+			continue
+		}
+		for {
+			r := rootInt(m, p)
+			if r.Cmp(bigOne) <= 0 || new(big.Int).Exp(r, big.NewInt(int64(p)), nil).Cmp(m) != 0 {
+				break
+			}
+			m, k = r, k*uint(p)
+		}
+	}
+	return
+}
+
+// PerfectPowers replaces the factors that are not known to be prime and are
+// perfect powers m^k by m with the exponent multiplied by k.
+// Like RecordSplit it marks m as prime if it is below PBound^2.
+func (l *Factors) PerfectPowers() {
+	var powers []*Fact
+	for fp := &l.First; *fp != nil; {
+		f := *fp
+		if f.Stat == Unknown || f.Stat == Composite {
+			if m, k := PerfectPower(f.Fac); k > 1 {
+				*fp = f.Next
+				powers = append(powers, l.newFact(m, f.Exp*k))
+				continue
+			}
+		}
+		fp = &f.Next
+	}
+	for _, f := range powers {
+		l.insert(f, true)
+	}
+}
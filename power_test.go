@@ -0,0 +1,82 @@
+package intfact
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestPerfectPower(t *testing.T) {
+	m89 := intval("618970019642690137449562111")
+	tests := []struct {
+		n *big.Int
+		m *big.Int
+		k uint
+	}{
+		{big.NewInt(1), big.NewInt(1), 1},
+		{big.NewInt(2), big.NewInt(2), 1},
+		{big.NewInt(4), big.NewInt(2), 2},
+		{big.NewInt(8), big.NewInt(2), 3},
+		{big.NewInt(64), big.NewInt(2), 6},
+		{big.NewInt(1 << 62), big.NewInt(2), 62},
+		{big.NewInt(36), big.NewInt(6), 2},
+		{big.NewInt(37), big.NewInt(37), 1},
+		{big.NewInt(1000003 * 1000003), big.NewInt(1000003), 2},
+		{new(big.Int).Exp(m89, big.NewInt(15), nil), m89, 15},
+		{new(big.Int).Exp(big.NewInt(3), big.NewInt(1001), nil), big.NewInt(3), 1001},
+		{new(big.Int).Add(new(big.Int).Exp(m89, big.NewInt(5), nil), bigOne), nil, 1},
+	}
+	for _, tt := range tests {
+		m, k := PerfectPower(tt.n)
+		want := tt.m
+		if want == nil {
+			want = tt.n
+		}
+		if m.Cmp(want) != 0 || k != tt.k {
+			t.Errorf("%v: got %v^%v, want %v^%v", tt.n, m, k, want, tt.k)
+		}
+	}
+}
+
+func TestFactorsPerfectPowers(t *testing.T) {
+	m61 := intval("2305843009213693951")
+	m89 := intval("618970019642690137449562111")
+	pq := new(big.Int).Mul(m61, m89)
+	l := NewFactors(new(big.Int).Exp(pq, big.NewInt(6), nil))
+	l.Insert(&Fact{Fac: big.NewInt(1000003 * 1000003), Exp: 1, Stat: Composite})
+	l.Insert(&Fact{Fac: big.NewInt(4294967291), Exp: 1, Stat: Unknown})
+	l.PerfectPowers()
+	want := []struct {
+		f *big.Int
+		e uint
+	}{{big.NewInt(1000003), 2}, {big.NewInt(4294967291), 1}, {pq, 6}}
+	i := 0
+	for f := l.First; f != nil; f = f.Next {
+		if i >= len(want) || f.Fac.Cmp(want[i].f) != 0 || f.Exp != want[i].e {
+			t.Fatalf("factor %v: got %v^%v", i, f.Fac, f.Exp)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Errorf("got %v factors, want %v", i, len(want))
+	}
+}
+
+func TestFactorPerfectPower(t *testing.T) {
+	// the cube of a product of a 10 and a 27 digit prime
+	p := big.NewInt(4294967291)
+	q := intval("618970019642690137449562111")
+	n := new(big.Int).Mul(p, q)
+	n.Exp(n, big.NewInt(3), nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	l, err := Factor(ctx, n, &FactorOptions{Random: &lcRandom{x: 10}})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	f := l.First
+	if f == nil || f.Fac.Cmp(p) != 0 || f.Exp != 3 || f.Next == nil || f.Next.Fac.Cmp(q) != 0 || f.Next.Exp != 3 {
+		t.Errorf("got first factor %+v", f)
+	}
+}
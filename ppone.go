@@ -0,0 +1,172 @@
+package intfact
+
+import (
+	"context"
+	"github.com/ghhenry/primes"
+	"math/big"
+	"math/bits"
+)
+
+var bigTwo = big.NewInt(2)
+
+// lucasV computes V_m(x) mod n with a Lucas chain, where V_0 = 2, V_1 = x and
+// V_{k+1} = x V_k - V_{k-1}. It uses V_{2k} = V_k^2 - 2 and V_{2k+1} = V_k V_{k+1} - x.
+func lucasV(x *big.Int, m uint64, n *big.Int) *big.Int {
+	if m == 0 {
+		return big.NewInt(2)
+	}
+	vk := new(big.Int).Set(x)
+	vk1 := new(big.Int).Mul(x, x)
+	vk1.Sub(vk1, bigTwo)
+	vk1.Mod(vk1, n)
+	for i := bits.Len64(m) - 2; i >= 0; i-- {
+		if m>>uint(i)&1 == 1 {
+			vk.Mul(vk, vk1)
+			vk.Sub(vk, x)
+			vk.Mod(vk, n)
+			vk1.Mul(vk1, vk1)
+			vk1.Sub(vk1, bigTwo)
+			vk1.Mod(vk1, n)
+		} else {
+			vk1.Mul(vk, vk1)
+			vk1.Sub(vk1, x)
+			vk1.Mod(vk1, n)
+			vk.Mul(vk, vk)
+			vk.Sub(vk, bigTwo)
+			vk.Mod(vk, n)
+		}
+	}
+	return vk
+}
+
+// p2lucas computes V_q(x) for the increasing primes q of phase2.
+// The primes are split into residue classes modulo d, and within a class the values
+// are computed with V_{k+d} = V_k V_d - V_{k-d}.
+type p2lucas struct {
+	n, x   *big.Int
+	d      uint32
+	vd     *big.Int
+	chains []*lucasChain
+}
+
+// lucasChain holds V_k and V_{k-d} for one residue class.
+type lucasChain struct {
+	k         uint32
+	cur, prev *big.Int
+}
+
+func newLucasHelper(x, n *big.Int) *p2lucas {
+	const d = 210
+	return &p2lucas{
+		n:      n,
+		x:      x,
+		d:      d,
+		vd:     lucasV(x, d, n),
+		chains: make([]*lucasChain, d),
+	}
+}
+
+func (h *p2lucas) get(q uint32) *big.Int {
+	r := q % h.d
+	if r%2 == 0 || r%3 == 0 || r%5 == 0 || r%7 == 0 {
+		// only happens for the primes dividing d
+		return lucasV(h.x, uint64(q), h.n)
+	}
+	c := h.chains[r]
+	if c == nil {
+		// V_{-m} = V_m
+		prev := uint64(h.d) - uint64(q)
+		if q > h.d {
+			prev = uint64(q - h.d)
+		}
+		c = &lucasChain{
+			k:    q,
+			cur:  lucasV(h.x, uint64(q), h.n),
+			prev: lucasV(h.x, prev, h.n),
+		}
+		h.chains[r] = c
+	}
+	for c.k < q {
+		next := new(big.Int).Mul(c.cur, h.vd)
+		next.Sub(next, c.prev)
+		next.Mod(next, h.n)
+		c.prev, c.cur = c.cur, next
+		c.k += h.d
+	}
+	return c.cur
+}
+
+// PpOne tries to find a factor of n using Williams' p+1 method.
+// b and b1 are the prime bounds used in phase1 and phase2 respectively.
+// seed is the start value A of the Lucas sequence V_k(A) = α^k + α^-k with α + 1/α = A.
+// A nil seed selects A = 7.
+//
+// A factor p is found if p+1 is smooth and A^2-4 is a quadratic non-residue modulo p.
+// If A^2-4 is a residue, the method finds p if p-1 is smooth like PmOne.
+// Since this is not known in advance, several seeds should be tried.
+//
+// The function returns a factor if one was found or otherwise ErrNoFactor, ErrTrivialGCD
+// or an error matching ErrCancelled.
+func PpOne(ctx context.Context, n *big.Int, b, b1 uint32, seed *big.Int) (fac *big.Int, err error) {
+	if seed == nil {
+		seed = big.NewInt(7)
+	}
+	v := new(big.Int).Mod(seed, n)
+	gcd := newGcdtest(n, 20)
+	phase1 := func(p uint32) bool {
+		select {
+		case <-ctx.Done():
+			err = cancelled(ctx)
+			return true
+		default:
+		}
+		mult := uint64(p)
+		for {
+			ne := mult * uint64(p)
+			if ne > uint64(b) {
+				break
+			}
+			mult = ne
+		}
+		v = lucasV(v, mult, n)
+		d := new(big.Int).Sub(v, bigTwo)
+		fac, err = gcd.test(d)
+		if fac != nil || err != nil {
+			return true
+		}
+		return false
+	}
+	primes.Iterate(2, b, phase1)
+	if fac != nil || err != nil {
+		return
+	}
+
+	// phase2
+	h := newLucasHelper(v, n)
+	phase2 := func(p uint32) bool {
+		select {
+		case <-ctx.Done():
+			err = cancelled(ctx)
+			return true
+		default:
+		}
+		d := new(big.Int).Sub(h.get(p), bigTwo)
+		fac, err = gcd.test(d)
+		if fac != nil || err != nil {
+			return true
+		}
+		return false
+	}
+	if b < b1 {
+		// b+1 would wrap for b = 2^32-1
+		primes.Iterate(b+1, b1, phase2)
+	}
+	if fac != nil || err != nil {
+		return
+	}
+	fac, err = gcd.finish()
+	if fac != nil || err != nil {
+		return
+	}
+	return nil, ErrNoFactor
+}
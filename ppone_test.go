@@ -0,0 +1,113 @@
+package intfact
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestLucasV(t *testing.T) {
+	n := big.NewInt(1000003)
+	x := big.NewInt(5)
+	// compute the sequence directly
+	prev, cur := big.NewInt(2), new(big.Int).Set(x)
+	for m := uint64(1); m < 100; m++ {
+		if got := lucasV(x, m, n); got.Cmp(cur) != 0 {
+			t.Fatalf("V_%v = %v, want %v", m, got, cur)
+		}
+		next := new(big.Int).Mul(x, cur)
+		next.Sub(next, prev)
+		next.Mod(next, n)
+		prev, cur = cur, next
+	}
+}
+
+func TestPpOne(t *testing.T) {
+	type args struct {
+		ctx  context.Context
+		n    *big.Int
+		b    uint32
+		b1   uint32
+		seed *big.Int
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantFac *big.Int
+		wantErr bool
+	}{
+		{
+			// 1000159+1 = 2^5*5*7*19*47
+			name: "phase1",
+			args: args{
+				ctx:  context.Background(),
+				n:    big.NewInt(1000159 * 3000131),
+				b:    100,
+				b1:   1000,
+				seed: big.NewInt(5),
+			},
+			wantFac: big.NewInt(1000159),
+			wantErr: false,
+		},
+		{
+			// 1000313+1 = 2*3^2*7*17*467
+			name: "phase2",
+			args: args{
+				ctx:  context.Background(),
+				n:    big.NewInt(1000313 * 3000131),
+				b:    20,
+				b1:   500,
+				seed: big.NewInt(3),
+			},
+			wantFac: big.NewInt(1000313),
+			wantErr: false,
+		},
+		{
+			// A^2-4 is a residue, so this works like p-1
+			name: "pmone",
+			args: args{
+				ctx:  context.Background(),
+				n:    big.NewInt(3607 * 3803),
+				b:    10,
+				b1:   700,
+				seed: big.NewInt(12),
+			},
+			wantFac: big.NewInt(3607),
+			wantErr: false,
+		},
+		{
+			name: "none",
+			args: args{
+				ctx:  context.Background(),
+				n:    big.NewInt(1000313 * 3000131),
+				b:    20,
+				b1:   400,
+				seed: big.NewInt(3),
+			},
+			wantFac: nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotFac, err := PpOne(tt.args.ctx, tt.args.n, tt.args.b, tt.args.b1, tt.args.seed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PpOne() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotFac, tt.wantFac) {
+				t.Errorf("PpOne() gotFac = %v, want %v", gotFac, tt.wantFac)
+			}
+		})
+	}
+}
+
+func TestPpOneNilSeed(t *testing.T) {
+	n := big.NewInt(1000313 * 3000131)
+	got, err := PpOne(context.Background(), n, 20, 500, nil)
+	want, werr := PpOne(context.Background(), n, 20, 500, big.NewInt(7))
+	if err != werr || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v, want %v, %v", got, err, want, werr)
+	}
+}
@@ -0,0 +1,173 @@
+package intfact
+
+import (
+	"math/big"
+)
+
+// mrPrimes are the bases of the deterministic Miller-Rabin tests.
+var mrPrimes = []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41}
+
+// mrBaseSets lists the bounds below which the first k primes are a
+// deterministic base set for the Miller-Rabin test (Jaeschke, Sorenson and Webster).
+var mrBaseSets = []struct {
+	bound *big.Int
+	k     int
+}{
+	{big.NewInt(2047), 1},
+	{big.NewInt(1373653), 2},
+	{big.NewInt(25326001), 3},
+	{big.NewInt(3215031751), 4},
+	{big.NewInt(2152302898747), 5},
+	{big.NewInt(3474749660383), 6},
+	{big.NewInt(341550071728321), 7},
+	{big.NewInt(3825123056546413051), 9},
+	{bigString("318665857834031151167461"), 12},
+	{bigString("3317044064679887385961981"), 13},
+}
+
+// bigString returns the value of the decimal constant s.
+func bigString(s string) *big.Int {
+	x, _ := new(big.Int).SetString(s, 10)
+	return x
+}
+
+// MillerRabinBases returns a set of bases for which the Miller-Rabin test is
+// deterministic for n, or nil if n is at least 3317044064679887385961981 (about 3.3·10^24).
+func MillerRabinBases(n *big.Int) []int64 {
+	for _, s := range mrBaseSets {
+		if n.Cmp(s.bound) < 0 {
+			return mrPrimes[:s.k]
+		}
+	}
+	return nil
+}
+
+// MillerRabin reports whether n is a strong probable prime to all of the
+// bases. The bases must be at least 2. Even n and n < 2 are only reported as
+// prime for n = 2, a base that is a multiple of n is skipped.
+func MillerRabin(n *big.Int, bases ...int64) bool {
+	if n.Cmp(big.NewInt(2)) <= 0 || n.Bit(0) == 0 {
+		return n.Cmp(big.NewInt(2)) == 0
+	}
+	nm1 := new(big.Int).Sub(n, bigOne)
+	s := nm1.TrailingZeroBits()
+	d := new(big.Int).Rsh(nm1, s)
+	x := new(big.Int)
+	a := new(big.Int)
+bases:
+	for _, b := range bases {
+		if a.Mod(a.SetInt64(b), n).Sign() == 0 {
+			continue
+		}
+		x.Exp(a, d, n)
+		if x.Cmp(bigOne) == 0 || x.Cmp(nm1) == 0 {
+			continue
+		}
+		for i := uint(1); i < s; i++ {
+			x.Mul(x, x)
+			x.Mod(x, n)
+			if x.Cmp(nm1) == 0 {
+				continue bases
+			}
+			if x.Cmp(bigOne) == 0 {
+				return false
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// StrongLucas reports whether n is a strong Lucas probable prime with the
+// parameters of Selfridge's method A: the first D in 5, -7, 9, -11, ... with
+// Jacobi(D, n) = -1, P = 1 and Q = (1-D)/4. Squares and even n are only
+// reported as prime for n = 2.
+func StrongLucas(n *big.Int) bool {
+	if n.Cmp(big.NewInt(2)) <= 0 || n.Bit(0) == 0 {
+		return n.Cmp(big.NewInt(2)) == 0
+	}
+	if s := new(big.Int).Sqrt(n); s.Mul(s, s).Cmp(n) == 0 {
+		return false
+	}
+	var dd int64
+	t := new(big.Int)
+	for d := int64(5); ; {
+		switch big.Jacobi(t.SetInt64(d), n) {
+		case -1:
+			dd = d
+		case 0:
+			if t.Abs(t).Cmp(n) != 0 {
+				return false
+			}
+		}
+		if dd != 0 {
+			break
+		}
+		if d > 0 {
+			d = -d - 2
+		} else {
+			d = -d + 2
+		}
+	}
+	// n+1 = d 2^s
+	np1 := new(big.Int).Add(n, bigOne)
+	s := np1.TrailingZeroBits()
+	d := new(big.Int).Rsh(np1, s)
+	u, v, qk := lucasUV(1, (1-dd)/4, d, n)
+	if u.Sign() == 0 || v.Sign() == 0 {
+		return true
+	}
+	for r := uint(1); r < s; r++ {
+		v.Mul(v, v)
+		v.Sub(v, t.Lsh(qk, 1))
+		v.Mod(v, n)
+		if v.Sign() == 0 {
+			return true
+		}
+		qk.Mul(qk, qk)
+		qk.Mod(qk, n)
+	}
+	return false
+}
+
+// BPSW is the Baillie-PSW test: a strong probable prime test to the base 2
+// followed by a strong Lucas test. No composite number passing it is known,
+// and it is proven correct for n < 2^64.
+func BPSW(n *big.Int) bool {
+	if n.Cmp(big.NewInt(2)) <= 0 {
+		return n.Cmp(big.NewInt(2)) == 0
+	}
+	for _, p := range mrPrimes {
+		if r := bigModSmall(n, uint32(p)); r == 0 {
+			return n.Cmp(big.NewInt(p)) == 0
+		}
+	}
+	return MillerRabin(n, 2) && StrongLucas(n)
+}
+
+// IsPrime tests n with a deterministic Miller-Rabin test if n is below
+// 3.3·10^24 and otherwise with BPSW. It reports whether n is at least
+// probably prime and whether the result is proven.
+func IsPrime(n *big.Int) (prime, proven bool) {
+	if n.IsUint64() {
+		return isPrime64(n.Uint64()), true
+	}
+	if bases := MillerRabinBases(n); bases != nil {
+		return MillerRabin(n, bases...), true
+	}
+	if !BPSW(n) {
+		return false, true
+	}
+	return true, false
+}
+
+// extraBases returns the first n primes from 43 on.
+func extraBases(n int) []int64 {
+	var bases []int64
+	for p := int64(43); len(bases) < n; p += 2 {
+		if isPrime64(uint64(p)) {
+			bases = append(bases, p)
+		}
+	}
+	return bases
+}
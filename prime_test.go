@@ -0,0 +1,108 @@
+package intfact
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMillerRabinBases(t *testing.T) {
+	tests := []struct {
+		n    string
+		want int
+	}{
+		{"2046", 1},
+		{"2047", 2},
+		{"3825123056546413050", 9},
+		{"3825123056546413051", 12},
+		{"3317044064679887385961980", 13},
+		{"3317044064679887385961981", 0},
+	}
+	for _, tt := range tests {
+		if got := MillerRabinBases(intval(tt.n)); len(got) != tt.want {
+			t.Errorf("%v: got %v bases, want %v", tt.n, len(got), tt.want)
+		}
+	}
+	// the bounds are composite strong pseudoprimes to their base sets
+	for _, s := range mrBaseSets {
+		if !MillerRabin(s.bound, mrPrimes[:s.k]...) || s.bound.ProbablyPrime(10) {
+			t.Errorf("%v is no strong pseudoprime to the first %v primes", s.bound, s.k)
+		}
+	}
+}
+
+func TestStrongLucas(t *testing.T) {
+	// strong Lucas pseudoprimes
+	for _, n := range []int64{5459, 5777, 10877, 16109, 18971, 22499} {
+		if !StrongLucas(big.NewInt(n)) {
+			t.Errorf("%v: got false", n)
+		}
+		if BPSW(big.NewInt(n)) {
+			t.Errorf("BPSW(%v): got true", n)
+		}
+	}
+	// strong pseudoprimes to the base 2
+	for _, n := range []int64{2047, 3277, 4033, 4681, 8321, 3215031751} {
+		if !MillerRabin(big.NewInt(n), 2) {
+			t.Errorf("%v: no strong pseudoprime", n)
+		}
+		if BPSW(big.NewInt(n)) {
+			t.Errorf("BPSW(%v): got true", n)
+		}
+	}
+	if StrongLucas(big.NewInt(1000003 * 1000003)) {
+		t.Error("square accepted")
+	}
+}
+
+func TestBPSW(t *testing.T) {
+	for i := int64(0); i < 20000; i++ {
+		n := big.NewInt(i)
+		if got, want := BPSW(n), n.ProbablyPrime(10); got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+	}
+	n := intval("340282366920938463463374607431768211297") // 2^128-159
+	for i := 0; i < 2000; i++ {
+		if got, want := BPSW(n), n.ProbablyPrime(10); got != want {
+			t.Errorf("%v: got %v, want %v", n, got, want)
+		}
+		n.Add(n, bigOne)
+	}
+}
+
+func TestIsPrime(t *testing.T) {
+	tests := []struct {
+		n             string
+		prime, proven bool
+	}{
+		{"1", false, true},
+		{"18446744073709551557", true, true},
+		{"18446744073709551629", true, true},       // 2^64+13
+		{"318665857834031151167461", false, true},  // strong pseudoprime to the bases up to 37
+		{"3317044064679887385961813", true, true},  // 3.3·10^24 - 168
+		{"3317044064679887385961981", false, true}, // strong pseudoprime to the bases up to 41
+		{"340282366920938463463374607431768211297", true, false},
+		{"340282366920938463463374607431768211457", false, true}, // 2^128+1
+	}
+	for _, tt := range tests {
+		prime, proven := IsPrime(intval(tt.n))
+		if prime != tt.prime || proven != tt.proven {
+			t.Errorf("%v: got %v, %v, want %v, %v", tt.n, prime, proven, tt.prime, tt.proven)
+		}
+	}
+}
+
+func TestPrimTest(t *testing.T) {
+	l := NewFactors(intval("340282366920938463463374607431768211297"))
+	l.Insert(&Fact{Fac: intval("18446744073709551629"), Exp: 1})
+	l.Insert(&Fact{Fac: intval("18446744073709551631"), Exp: 1})
+	l.PrimTest(5, false)
+	want := []Status{Prime, Composite, ProbPrime}
+	i := 0
+	for f := l.First; f != nil; f = f.Next {
+		if f.Stat != want[i] {
+			t.Errorf("%v: got %v, want %v", f.Fac, f.Stat, want[i])
+		}
+		i++
+	}
+}
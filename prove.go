@@ -0,0 +1,356 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+// ErrNoProof is reported by ProvePrime if neither a proof nor a witness of
+// compositeness was found.
+var ErrNoProof = errors.New("no primality proof found")
+
+// ProvePrime proves that p is prime or composite. Below 3.3·10^24 it uses
+// IsPrime. Above, it factors p-1 and p+1 alternately with the default methods
+// until one of them is factored far enough for a proof:
+//   - Pocklington's theorem if the factored part F of p-1 is larger than sqrt(p),
+//     or the Brillhart-Lehmer-Selfridge cube root test if F^3 > p,
+//   - Morrison's p+1 test with a Lucas sequence if the factored part of p+1
+//     is larger than sqrt(p)+1.
+//
+// The probable prime factors of p-1 and p+1 are proven recursively.
+// If a few rounds of factoring are not enough, it tries ECPP and gives up with
+// ErrNoProof if ECPP fails as well.
+//
+// The function returns true if p is prime, false if it is composite, or an error
+// matching ErrCancelled or ErrNoProof if it found neither.
+func ProvePrime(ctx context.Context, p *big.Int) (bool, error) {
+	o := (*FactorOptions)(nil).withDefaults()
+	prime, _, err := provePrime(ctx, p, &o)
+	return prime, err
+}
+
+// PrimeCertificate proves p prime like ProvePrime and returns the certificate of
+// the proof, which is empty below 3.3·10^24. It returns ErrComposite if p is
+// composite.
+func PrimeCertificate(ctx context.Context, p *big.Int) (Certificate, error) {
+	o := (*FactorOptions)(nil).withDefaults()
+	prime, cert, err := provePrime(ctx, p, &o)
+	if err == nil && !prime {
+		err = ErrComposite
+	}
+	return cert, err
+}
+
+func provePrime(ctx context.Context, n *big.Int, o *FactorOptions) (bool, Certificate, error) {
+	if prime, proven := IsPrime(n); proven || !prime {
+		return prime, nil, nil
+	}
+	nm1 := newProofSide(new(big.Int).Sub(n, bigOne), o)
+	np1 := newProofSide(new(big.Int).Add(n, bigOne), o)
+	for round := 0; ; round++ {
+		if round == proofRounds {
+			switch steps, err := ECPP(ctx, n); err {
+			case nil:
+				return true, ecppCertificate(steps), nil
+			case ErrComposite:
+				return false, nil, nil
+			default:
+				return false, nil, err
+			}
+		}
+		for _, s := range []*proofSide{nm1, np1} {
+			if ctx.Err() != nil {
+				return false, nil, cancelled(ctx)
+			}
+			if err := s.proveFactors(ctx, o); err != nil {
+				return false, nil, err
+			}
+			f, qs, sub := s.factored()
+			var prime bool
+			var step *CertStep
+			var err error
+			c := new(big.Int)
+			switch {
+			case s == nm1 && c.Mul(f, f).Mul(c, f).Cmp(n) > 0:
+				prime, step, err = pocklington(n, f, qs)
+			case s == np1 && c.Sub(f, bigOne).Mul(c, c).Cmp(n) > 0:
+				prime, step, err = morrison(n, qs)
+			default:
+				if err := s.step(ctx, o); err != nil {
+					return false, nil, err
+				}
+				continue
+			}
+			if !prime || err != nil {
+				return prime, nil, err
+			}
+			return true, append(Certificate{*step}, sub...).dedup(), nil
+		}
+	}
+}
+
+// proofRounds is the number of factoring steps on p-1 and p+1 before ProvePrime
+// tries ECPP.
+const proofRounds = 6
+
+// proofSide is the partial factorisation of n-1 or n+1 for a primality proof.
+// next is the index of the next method for the composite factor cur.
+type proofSide struct {
+	l    *Factors
+	cur  *big.Int
+	next int
+}
+
+func newProofSide(n *big.Int, o *FactorOptions) *proofSide {
+	l := NewFactors(n)
+	l.TrialDivision(o.TrialBound)
+	return &proofSide{l: l}
+}
+
+// proveFactors proves the probable prime factors.
+func (s *proofSide) proveFactors(ctx context.Context, o *FactorOptions) error {
+	s.l.PerfectPowers()
+	s.l.completeSmall()
+	s.l.PrimTest(o.Rounds, false)
+	return s.l.prove(ctx, o)
+}
+
+// factored returns the product of the prime factors, the primes and their
+// certificates.
+func (s *proofSide) factored() (*big.Int, []*big.Int, Certificate) {
+	f := big.NewInt(1)
+	var qs []*big.Int
+	var cert Certificate
+	for fa := s.l.First; fa != nil; fa = fa.Next {
+		if fa.Stat == Prime {
+			qs = append(qs, fa.Fac)
+			f.Mul(f, new(big.Int).Exp(fa.Fac, big.NewInt(int64(fa.Exp)), nil))
+			cert = append(cert, fa.Cert...)
+		}
+	}
+	return f, qs, cert
+}
+
+// step runs the next method on the first composite factor.
+func (s *proofSide) step(ctx context.Context, o *FactorOptions) error {
+	fp := &s.l.First
+	for *fp != nil && (*fp).Stat != Composite {
+		fp = &(*fp).Next
+	}
+	if *fp == nil {
+		return nil
+	}
+	n := (*fp).Fac
+	ms := schedule(o.Methods, n)
+	if len(ms) == 0 {
+		return errors.New("no applicable factoring method")
+	}
+	if s.cur == nil || s.cur.Cmp(n) != 0 {
+		s.cur, s.next = n, 0
+	}
+	m := ms[s.next%len(ms)]
+	s.next++
+	d, _ := m.Run(ctx, n)
+	if ctx.Err() != nil {
+		return cancelled(ctx)
+	}
+	if divides(d, n) {
+		s.l.RecordSplit(fp, d, new(big.Int).Div(n, d))
+	}
+	return nil
+}
+
+// maxWitness bounds the bases and Lucas parameters tried by the proofs.
+const maxWitness = 1000
+
+// pocklington proves n prime or composite with the factored part f of n-1,
+// f^3 > n, and its prime factors qs. For each q it looks for a base a with
+// a^(n-1) = 1 and gcd(a^((n-1)/q) - 1, n) = 1, so that every prime factor of n
+// is 1 modulo f. If f^2 <= n, the test of blsSquare completes the proof.
+// For a prime n it also returns the certificate step, a Pratt step if f = n-1
+// and the bases are the same.
+func pocklington(n, f *big.Int, qs []*big.Int) (bool, *CertStep, error) {
+	nm1 := new(big.Int).Sub(n, bigOne)
+	a := new(big.Int)
+	e := new(big.Int)
+	t := new(big.Int)
+	g := new(big.Int)
+	step := &CertStep{Type: CertPocklington, N: n, F: qs}
+	for _, q := range qs {
+		e.Div(nm1, q)
+		found := false
+		for b := int64(2); b < maxWitness && !found; b++ {
+			a.SetInt64(b)
+			if t.Exp(a, nm1, n).Cmp(bigOne) != 0 {
+				return false, nil, nil
+			}
+			t.Exp(a, e, n)
+			g.GCD(nil, nil, t.Sub(t, bigOne), n)
+			switch {
+			case g.Cmp(bigOne) == 0:
+				found = true
+				step.A = append(step.A, b)
+			case g.Cmp(n) != 0:
+				return false, nil, nil
+			}
+		}
+		if !found {
+			return false, nil, ErrNoProof
+		}
+	}
+	if t.Mul(f, f).Cmp(n) > 0 {
+		if f.Cmp(nm1) == 0 && len(step.A) > 0 {
+			pratt := true
+			for _, b := range step.A {
+				pratt = pratt && b == step.A[0]
+			}
+			if pratt {
+				step.Type, step.A = CertPratt, step.A[:1]
+			}
+		}
+		return true, step, nil
+	}
+	if !blsSquare(n, f) {
+		return false, nil, nil
+	}
+	step.Type = CertBLS
+	return true, step, nil
+}
+
+// blsSquare is the final test of Brillhart, Lehmer and Selfridge for n - 1 = fr
+// with f^3 > n >= f^2, if the prime factors of n are 1 modulo f: with
+// n = c2 f^2 + c1 f + 1, n is prime if and only if c1^2 - 4 c2 is no square.
+func blsSquare(n, f *big.Int) bool {
+	nm1 := new(big.Int).Sub(n, bigOne)
+	c2, c1 := new(big.Int).DivMod(nm1.Div(nm1, f), f, new(big.Int))
+	disc := c1.Mul(c1, c1)
+	disc.Sub(disc, c2.Lsh(c2, 2))
+	if disc.Sign() < 0 {
+		return true
+	}
+	t := new(big.Int).Sqrt(disc)
+	return t.Mul(t, t).Cmp(disc) != 0
+}
+
+// morrison proves n prime or composite with the prime factors qs of the
+// factored part f of n+1, (f-1)^2 > n. It looks for a Lucas sequence with
+// Jacobi(P^2-4Q, n) = -1 such that U_(n+1) = 0 and gcd(U_((n+1)/q), n) = 1
+// for all q. Q is chosen with Jacobi(Q, n) = -1, since otherwise U_((n+1)/2) = 0
+// for prime n. For a prime n it also returns the certificate step.
+func morrison(n *big.Int, qs []*big.Int) (bool, *CertStep, error) {
+	np1 := new(big.Int).Add(n, bigOne)
+	d := new(big.Int)
+	e := new(big.Int)
+	g := new(big.Int)
+	for p := int64(1); p < maxWitness; p++ {
+	params:
+		for q := int64(-p); q <= p; q++ {
+			for _, x := range []int64{q, p*p - 4*q} {
+				switch big.Jacobi(d.SetInt64(x), n) {
+				case 0:
+					if x != 0 && g.GCD(nil, nil, d.Abs(d), n).Cmp(n) != 0 {
+						return false, nil, nil
+					}
+					continue params
+				case 1:
+					continue params
+				}
+			}
+			if u, _, _ := lucasUV(p, q, np1, n); u.Sign() != 0 {
+				return false, nil, nil
+			}
+			for _, r := range qs {
+				u, _, _ := lucasUV(p, q, e.Div(np1, r), n)
+				g.GCD(nil, nil, u, n)
+				switch {
+				case g.Cmp(bigOne) == 0:
+				case g.Cmp(n) == 0:
+					continue params
+				default:
+					return false, nil, nil
+				}
+			}
+			return true, &CertStep{Type: CertMorrison, N: n, F: qs, P: p, Q: q}, nil
+		}
+	}
+	return false, nil, ErrNoProof
+}
+
+// lucasD returns the discriminant P^2 - 4Q of the Lucas sequences, which may
+// not fit into an int64 for the parameters of a decoded certificate.
+func lucasD(p, q int64) *big.Int {
+	d := big.NewInt(p)
+	d.Mul(d, d)
+	return d.Sub(d, new(big.Int).Lsh(big.NewInt(q), 2))
+}
+
+// lucasUV returns U_k, V_k and Q^k modulo the odd n for the Lucas sequences
+// with the parameters P and Q, k > 0.
+func lucasUV(p, q int64, k, n *big.Int) (u, v, qk *big.Int) {
+	bigP := big.NewInt(p)
+	bigQ := big.NewInt(q)
+	bigD := lucasD(p, q)
+	u = big.NewInt(1)
+	v = new(big.Int).Mod(bigP, n)
+	qk = new(big.Int).Mod(bigQ, n)
+	t := new(big.Int)
+	half := func(x *big.Int) {
+		if x.Bit(0) != 0 {
+			x.Add(x, n)
+		}
+		x.Rsh(x, 1)
+	}
+	for i := k.BitLen() - 2; i >= 0; i-- {
+		// k -> 2k
+		u.Mul(u, v)
+		u.Mod(u, n)
+		v.Mul(v, v)
+		v.Sub(v, t.Lsh(qk, 1))
+		v.Mod(v, n)
+		qk.Mul(qk, qk)
+		qk.Mod(qk, n)
+		if k.Bit(i) != 0 {
+			// k -> k+1
+			t.Mul(bigD, u)
+			u.Mul(u, bigP)
+			u.Add(u, v)
+			u.Mod(u, n)
+			half(u)
+			v.Mul(v, bigP)
+			v.Add(v, t)
+			v.Mod(v, n)
+			half(v)
+			qk.Mul(qk, bigQ)
+			qk.Mod(qk, n)
+		}
+	}
+	return
+}
+
+// Prove runs ProvePrime with the methods of opts on the probable prime factors and
+// marks them as Prime with their certificate or as Composite.
+// It returns the first error of ProvePrime, the remaining factors are left unchanged.
+func (l *Factors) Prove(ctx context.Context, opts *FactorOptions) error {
+	o := opts.withDefaults()
+	return l.prove(ctx, &o)
+}
+
+func (l *Factors) prove(ctx context.Context, o *FactorOptions) error {
+	for f := l.First; f != nil; f = f.Next {
+		if f.Stat != ProbPrime {
+			continue
+		}
+		prime, cert, err := provePrime(ctx, f.Fac, o)
+		if err != nil {
+			return err
+		}
+		if prime {
+			f.Stat = Prime
+			f.Cert = cert
+		} else {
+			f.Stat = Composite
+		}
+	}
+	return nil
+}
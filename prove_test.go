@@ -0,0 +1,113 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestProvePrime(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *big.Int
+		want bool
+	}{
+		{"small prime", big.NewInt(1000003), true},
+		{"m89", intval("618970019642690137449562111"), true},
+		{"m127", intval("170141183460469231731687303715884105727"), true},
+		{"40 digits", intval("1000000000000000000000000000000000000003"), true},
+		{"50 digits", intval("10000000000000000000000000000000000000000000000009"), true},
+		{"f7", intval("340282366920938463463374607431768211457"), false},
+		{"m67", intval("147573952589676412927"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.want != tt.n.ProbablyPrime(10) {
+				t.Fatal("wrong test value")
+			}
+			got, err := ProvePrime(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ProvePrime(ctx, intval("618970019642690137449562111")); !errors.Is(err, ErrCancelled) {
+		t.Errorf("got %v, want ErrCancelled", err)
+	}
+}
+
+func TestPocklington(t *testing.T) {
+	// 2^89-1 = 2*3*5*17*23*89*353*397*683*2113*2931542417 + 1
+	p := intval("618970019642690137449562111")
+	var qs []*big.Int
+	f := big.NewInt(1)
+	for _, q := range []int64{2, 3, 5, 17, 23, 89, 353, 397} {
+		qs = append(qs, big.NewInt(q))
+		f.Mul(f, big.NewInt(q))
+	}
+	// f is between the cube root and the square root
+	if got, _, err := pocklington(p, f, qs); !got || err != nil {
+		t.Errorf("got %v, %v", got, err)
+	}
+	for _, q := range []int64{683, 2113} {
+		qs = append(qs, big.NewInt(q))
+		f.Mul(f, big.NewInt(q))
+	}
+	if got, _, err := pocklington(p, f, qs); !got || err != nil {
+		t.Errorf("got %v, %v", got, err)
+	}
+	// a composite is detected by Fermat's test
+	n := new(big.Int).Mul(big.NewInt(1000003), big.NewInt(1000033))
+	if got, _, err := pocklington(n, big.NewInt(2), []*big.Int{big.NewInt(2)}); got || err != nil {
+		t.Errorf("got %v, %v", got, err)
+	}
+	// m89+1 = 2^89
+	if got, _, err := morrison(p, []*big.Int{big.NewInt(2)}); !got || err != nil {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestLucasUV(t *testing.T) {
+	n := big.NewInt(1000003)
+	for _, pq := range [][2]int64{{1, -1}, {3, 1}, {1, 2}, {5, -3}, {1<<40 + 1, -1 << 61}} {
+		p, q := pq[0], pq[1]
+		// U_0 = 0, U_1 = 1, V_0 = 2, V_1 = P
+		u0, u1 := big.NewInt(0), big.NewInt(1)
+		v0, v1 := big.NewInt(2), big.NewInt(p)
+		for k := int64(1); k < 100; k++ {
+			u, v, qk := lucasUV(p, q, big.NewInt(k), n)
+			wq := new(big.Int).Exp(big.NewInt(q), big.NewInt(k), n)
+			if wq.Sign() < 0 {
+				wq.Add(wq, n)
+			}
+			if u.Cmp(new(big.Int).Mod(u1, n)) != 0 || v.Cmp(new(big.Int).Mod(v1, n)) != 0 || qk.Cmp(wq) != 0 {
+				t.Fatalf("P=%v Q=%v k=%v: got %v, %v, %v", p, q, k, u, v, qk)
+			}
+			u0, u1 = u1, new(big.Int).Sub(new(big.Int).Mul(big.NewInt(p), u1), new(big.Int).Mul(big.NewInt(q), u0))
+			v0, v1 = v1, new(big.Int).Sub(new(big.Int).Mul(big.NewInt(p), v1), new(big.Int).Mul(big.NewInt(q), v0))
+		}
+	}
+}
+
+func TestFactorProve(t *testing.T) {
+	// (2^89-1)(2^61-1)
+	n := new(big.Int).Mul(intval("618970019642690137449562111"), intval("2305843009213693951"))
+	l, err := Factor(context.Background(), n, &FactorOptions{Prove: true, Random: &lcRandom{x: 10}})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if l.IsComplete() != 2 {
+		t.Error("factors are not proven prime")
+	}
+	for f := l.First; f != nil; f = f.Next {
+		if err := VerifyCertificate(f.Fac, f.Cert); err != nil {
+			t.Errorf("%v: %v", f.Fac, err)
+		}
+	}
+}
@@ -0,0 +1,655 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"github.com/ghhenry/intfact/gf2"
+	"github.com/ghhenry/primes"
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+)
+
+// QSOptions contains the parameters of QuadraticSieve.
+// A nil pointer or zero fields select defaults that depend on the size of n.
+type QSOptions struct {
+	// FactorBase is the number of primes in the factor base.
+	FactorBase int
+	// M is half the length of the sieve interval [-M, M).
+	M int
+	// LargePrimeMultiplier bounds the large prime of a partial relation to
+	// LargePrimeMultiplier times the largest prime of the factor base.
+	// A negative value disables the large prime variation.
+	LargePrimeMultiplier int
+	// Multiplier is the multiplier k, so that kn is sieved instead of n.
+	// It must be square free. The default is chosen with the Knuth-Schroeppel function.
+	Multiplier uint32
+}
+
+// qsParams are the default parameters by the size of n in bits.
+// The factor base size and the large prime multiplier are interpolated.
+var qsParams = []struct {
+	bits, fb, lpMult, m int
+}{
+	{64, 100, 40, 1 << 13},
+	{128, 450, 40, 1 << 15},
+	{183, 2000, 40, 1 << 16},
+	{200, 3000, 50, 1 << 16},
+	{212, 5400, 50, 3 << 15},
+	{233, 10000, 100, 3 << 16},
+	{249, 27000, 100, 3 << 16},
+	{266, 50000, 100, 3 << 16},
+	{283, 55000, 80, 3 << 16},
+	{298, 60000, 80, 9 << 16},
+	{315, 80000, 150, 9 << 16},
+	{332, 100000, 150, 9 << 16},
+}
+
+// qsMinBits is the smallest size of n accepted by QuadraticSieve.
+const qsMinBits = 40
+
+func (o *QSOptions) withDefaults(n *big.Int) (QSOptions, error) {
+	var r QSOptions
+	if o != nil {
+		r = *o
+	}
+	b := n.BitLen()
+	i := 0
+	for i < len(qsParams)-1 && qsParams[i+1].bits <= b {
+		i++
+	}
+	fb, lpMult, m := qsParams[i].fb, qsParams[i].lpMult, qsParams[i].m
+	if i < len(qsParams)-1 && b > qsParams[i].bits {
+		lo, hi := qsParams[i], qsParams[i+1]
+		f := float64(b-lo.bits) / float64(hi.bits-lo.bits)
+		fb += int(f * float64(hi.fb-lo.fb))
+		lpMult += int(f * float64(hi.lpMult-lo.lpMult))
+	}
+	if r.FactorBase == 0 {
+		r.FactorBase = fb
+	}
+	if r.M == 0 {
+		r.M = m
+	}
+	if r.LargePrimeMultiplier == 0 {
+		r.LargePrimeMultiplier = lpMult
+	}
+	if r.Multiplier == 0 {
+		r.Multiplier = knuthSchroeppel(n)
+	}
+	if r.FactorBase < 10 || r.M < 64 {
+		return r, errors.New("factor base or sieve interval too small")
+	}
+	return r, nil
+}
+
+// knuthSchroeppel returns the square free multiplier k < 100 that maximizes the
+// expected contribution of the small primes to the values of the sieve for kn.
+func knuthSchroeppel(n *big.Int) uint32 {
+	best, bestK := math.Inf(-1), uint32(1)
+	bp := new(big.Int)
+	for k := uint32(1); k < 100; k++ {
+		if !squareFree(k) {
+			continue
+		}
+		kn := new(big.Int).Mul(n, bp.SetUint64(uint64(k)))
+		f := -0.5 * math.Log(float64(k))
+		switch kn.Bits()[0] & 7 {
+		case 1:
+			f += 2 * math.Ln2
+		case 5:
+			f += math.Ln2
+		case 3, 7:
+			f += 0.5 * math.Ln2
+		}
+		primes.Iterate(3, 1000, func(p uint32) bool {
+			lp := math.Log(float64(p))
+			switch {
+			case k%p == 0:
+				f += lp / float64(p)
+			case big.Jacobi(bp.Mod(kn, bp.SetUint64(uint64(p))), big.NewInt(int64(p))) == 1:
+				f += 2 * lp / float64(p-1)
+			}
+			return false
+		})
+		if f > best {
+			best, bestK = f, k
+		}
+	}
+	return bestK
+}
+
+func squareFree(k uint32) bool {
+	for p := uint32(2); p*p <= k; p++ {
+		if k%(p*p) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// QuadraticSieve tries to find a factor of n with the self-initializing quadratic sieve (SIQS).
+// It collects relations (Ax+B)^2 = A*Q(x) modulo n with A*Q(x) factoring over the factor base,
+// allowing one large prime, and combines them into a congruence of squares X^2 = Y^2
+// with linear algebra over GF(2). gcd(X-Y, n) is then a factor of n with probability 1/2
+// for each combination.
+//
+// n must have at least 40 bits. The work grows quickly with n; numbers of 100 digits and more
+// are better handled with the number field sieve.
+//
+// The function returns a factor if one was found or otherwise ErrNoFactor, e.g. if n is prime
+// or a prime power, or an error matching ErrCancelled.
+func QuadraticSieve(ctx context.Context, n *big.Int, opts *QSOptions) (*big.Int, error) {
+	if n.BitLen() < qsMinBits {
+		return nil, errors.New("n too small for the quadratic sieve")
+	}
+	if n.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	r := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(r, r).Cmp(n) == 0 {
+		return r, nil
+	}
+	if prime, _ := IsPrime(n); prime {
+		return nil, ErrNoFactor
+	}
+	o, err := opts.withDefaults(n)
+	if err != nil {
+		return nil, err
+	}
+	s, fac := newQS(n, o)
+	if fac != nil {
+		return fac, nil
+	}
+	fac, err = s.collect(ctx)
+	if fac != nil || err != nil {
+		return fac, err
+	}
+	return s.combine()
+}
+
+// qsPrime is a prime of the factor base.
+type qsPrime struct {
+	p    uint32
+	logp uint8
+	// t is a square root of kn modulo p
+	t uint32
+	// ainv is the inverse of A modulo p, 0 for the primes dividing A.
+	ainv uint32
+	// r1 and r2 are the sieve positions of the roots of the current polynomial.
+	r1, r2 uint32
+}
+
+// qsRelation is a relation y^2 = product of the primes in cols times large^2 modulo n.
+type qsRelation struct {
+	y *big.Int
+	// cols are the columns of the prime factors with multiplicity, column 0 is -1
+	// and column i is the prime fb[i-1].
+	cols  []int
+	large uint64
+}
+
+// qsSmall is the bound for the primes which are not sieved but only trial divided.
+const qsSmall = 32
+
+type qs struct {
+	n, kn   *big.Int
+	m       int
+	fb      []qsPrime
+	lpBound uint64
+	rnd     *rand.Rand
+	usedA   map[string]bool
+	rels    []qsRelation
+	partial map[uint64]qsRelation
+	// seen contains the values y of the relations, since polynomials with different A can
+	// find the same relation
+	seen   map[string]bool
+	thresh uint8
+}
+
+// newQS builds the factor base. It returns a factor if one of the primes divides n.
+func newQS(n *big.Int, o QSOptions) (*qs, *big.Int) {
+	s := &qs{
+		n:       n,
+		kn:      new(big.Int).Mul(n, big.NewInt(int64(o.Multiplier))),
+		m:       o.M,
+		rnd:     rand.New(rand.NewSource(1)),
+		usedA:   make(map[string]bool),
+		partial: make(map[uint64]qsRelation),
+		seen:    make(map[string]bool),
+	}
+	s.fb = append(s.fb, qsPrime{p: 2, logp: 1})
+	var fac *big.Int
+	bp := new(big.Int)
+	primes.Iterate(3, math.MaxUint32, func(p uint32) bool {
+		bp.SetUint64(uint64(p))
+		if bigModSmall(n, p) == 0 {
+			fac = bp
+			return true
+		}
+		var t uint32
+		if o.Multiplier%p != 0 {
+			if big.Jacobi(bp.Mod(s.kn, bp), big.NewInt(int64(p))) != 1 {
+				return false
+			}
+			t = uint32(new(big.Int).ModSqrt(bp, big.NewInt(int64(p))).Uint64())
+		}
+		s.fb = append(s.fb, qsPrime{p: p, logp: uint8(math.Round(math.Log2(float64(p)))), t: t})
+		return len(s.fb) >= o.FactorBase
+	})
+	if fac != nil {
+		return nil, fac
+	}
+	pmax := uint64(s.fb[len(s.fb)-1].p)
+	s.lpBound = pmax
+	if o.LargePrimeMultiplier > 0 && uint64(o.LargePrimeMultiplier) < pmax {
+		s.lpBound = pmax * uint64(o.LargePrimeMultiplier)
+	}
+	// the values of the sieve polynomials are at most M*sqrt(kn/2),
+	// the unsieved small primes contribute about 4 bits
+	thresh := math.Log2(float64(s.m)) + bigLog(s.kn)/2/math.Ln2 - 0.5 - math.Log2(float64(s.lpBound)) - 4
+	if thresh < 1 {
+		thresh = 1
+	}
+	s.thresh = uint8(thresh)
+	return s, nil
+}
+
+// bigLog returns the natural logarithm of x > 0.
+func bigLog(x *big.Int) float64 {
+	mant := new(big.Float)
+	exp := new(big.Float).SetInt(x).MantExp(mant)
+	m, _ := mant.Float64()
+	return math.Log(m) + float64(exp)*math.Ln2
+}
+
+// bigModSmall returns x mod p for x >= 0.
+func bigModSmall(x *big.Int, p uint32) uint32 {
+	ws := x.Bits()
+	var r uint64
+	for i := len(ws) - 1; i >= 0; i-- {
+		w := uint64(ws[i])
+		if bits.UintSize == 64 {
+			r = bits.Rem64(r, w, uint64(p))
+		} else {
+			r = (r<<32 | w) % uint64(p)
+		}
+	}
+	return uint32(r)
+}
+
+// invMod returns the inverse of a modulo p for a coprime to p.
+func invMod(a, p uint32) uint32 {
+	var x0, x1 int64 = 1, 0
+	r0, r1 := int64(a%p), int64(p)
+	for r1 != 0 {
+		q := r0 / r1
+		r0, r1 = r1, r0-q*r1
+		x0, x1 = x1, x0-q*x1
+	}
+	if x0 < 0 {
+		x0 += int64(p)
+	}
+	return uint32(x0)
+}
+
+// needed returns the number of relations needed for the linear algebra.
+func (s *qs) needed() int {
+	return len(s.fb) + 1 + 32
+}
+
+// chooseA selects the primes of a new polynomial coefficient A close to sqrt(2kn)/M.
+// It returns the indices of the primes in the factor base.
+func (s *qs) chooseA() ([]int, error) {
+	logTarget := (bigLog(s.kn)+math.Ln2)/2 - math.Log(float64(s.m))
+	// the primes of A are not sieved, so the smallest primes are not used
+	lo := 0
+	for lo < len(s.fb) && (s.fb[lo].p < qsSmall || lo < len(s.fb)/16) {
+		lo++
+	}
+	hi := len(s.fb)
+	if hi-lo < 4 {
+		return nil, errors.New("factor base too small")
+	}
+	lnp := func(i int) float64 { return math.Log(float64(s.fb[i].p)) }
+	// primes around 2000 give enough polynomials per A
+	nq := int(math.Round(logTarget / math.Log(2000)))
+	if nq < 1 {
+		nq = 1
+	}
+	for nq < hi-lo && logTarget/float64(nq) > lnp(hi-1) {
+		nq++
+	}
+	for nq > 1 && logTarget/float64(nq) < lnp(lo) {
+		nq--
+	}
+	ideal := logTarget / float64(nq)
+	c := lo
+	for c < hi-1 && lnp(c) < ideal {
+		c++
+	}
+	w := 2*nq + 8
+	wlo, whi := c-w, c+w
+	if wlo < lo {
+		wlo = lo
+	}
+	if whi > hi {
+		whi = hi
+	}
+	for try := 0; try < 1000; try++ {
+		var qidx []int
+		used := make(map[int]bool)
+		rest := logTarget
+		for len(qidx) < nq-1 {
+			i := wlo + s.rnd.Intn(whi-wlo)
+			if used[i] || s.fb[i].t == 0 {
+				continue
+			}
+			used[i] = true
+			qidx = append(qidx, i)
+			rest -= lnp(i)
+		}
+		// the last prime brings A close to the target
+		best := -1
+		for i := lo; i < hi; i++ {
+			if used[i] || s.fb[i].t == 0 {
+				continue
+			}
+			if best < 0 || math.Abs(lnp(i)-rest) < math.Abs(lnp(best)-rest) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		qidx = append(qidx, best)
+		a := big.NewInt(1)
+		for _, i := range qidx {
+			a.Mul(a, big.NewInt(int64(s.fb[i].p)))
+		}
+		key := a.String()
+		if !s.usedA[key] {
+			s.usedA[key] = true
+			return qidx, nil
+		}
+	}
+	return nil, errors.New("no new polynomial found")
+}
+
+// collect sieves until enough relations are found.
+// It returns a factor if a large prime divides n.
+func (s *qs) collect(ctx context.Context) (*big.Int, error) {
+	sieve := make([]uint8, 2*s.m)
+	for len(s.rels) < s.needed() {
+		qidx, err := s.chooseA()
+		if err != nil {
+			return nil, err
+		}
+		p := s.newPoly(qidx)
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return nil, cancelled(ctx)
+			default:
+			}
+			if i > 0 {
+				if !p.next(s, i) {
+					break
+				}
+			}
+			s.sieve(sieve)
+			for j, v := range sieve {
+				if v >= s.thresh {
+					if fac := s.check(p, j); fac != nil {
+						return fac, nil
+					}
+				}
+			}
+			if len(s.rels) >= s.needed() {
+				break
+			}
+		}
+	}
+	return nil, nil
+}
+
+// qsPoly is the sieve polynomial Q(x) = Ax^2 + 2Bx + C with B^2 - AC = kn.
+type qsPoly struct {
+	a, b, c *big.Int
+	// qidx are the factor base indices of the primes of A
+	qidx []int
+	// bl are the values B_l with B = ±B_1 ± ... ± B_s
+	bl []*big.Int
+	// bainv[l][i] is 2*B_l/A modulo the i-th prime
+	bainv [][]uint32
+}
+
+// newPoly computes the first polynomial for A and the roots modulo the factor base.
+func (s *qs) newPoly(qidx []int) *qsPoly {
+	p := &qsPoly{a: big.NewInt(1), b: new(big.Int), qidx: qidx}
+	for _, i := range qidx {
+		p.a.Mul(p.a, big.NewInt(int64(s.fb[i].p)))
+	}
+	// B_l = A/q_l * gamma with gamma = t_l * (A/q_l)^-1 modulo q_l, so that B^2 = kn modulo A
+	for _, i := range qidx {
+		q := s.fb[i].p
+		aq := new(big.Int).Quo(p.a, big.NewInt(int64(q)))
+		gamma := uint64(s.fb[i].t) * uint64(invMod(bigModSmall(aq, q), q)) % uint64(q)
+		if gamma > uint64(q/2) {
+			gamma = uint64(q) - gamma
+		}
+		bl := aq.Mul(aq, new(big.Int).SetUint64(gamma))
+		p.bl = append(p.bl, bl)
+		p.b.Add(p.b, bl)
+	}
+	p.bainv = make([][]uint32, len(qidx))
+	for l := range p.bainv {
+		p.bainv[l] = make([]uint32, len(s.fb))
+	}
+	for i := range s.fb {
+		f := &s.fb[i]
+		f.ainv = 0
+		am := bigModSmall(p.a, f.p)
+		if f.p < qsSmall || am == 0 {
+			continue
+		}
+		f.ainv = invMod(am, f.p)
+		for l, bl := range p.bl {
+			p.bainv[l][i] = uint32(2 * uint64(bigModSmall(bl, f.p)) * uint64(f.ainv) % uint64(f.p))
+		}
+	}
+	s.roots(p)
+	return p
+}
+
+// roots computes the sieve positions of the roots ainv*(±t - B) + M.
+func (s *qs) roots(p *qsPoly) {
+	p.c = new(big.Int).Mul(p.b, p.b)
+	p.c.Sub(p.c, s.kn)
+	p.c.Quo(p.c, p.a)
+	for i := range s.fb {
+		f := &s.fb[i]
+		if f.ainv == 0 {
+			continue
+		}
+		pp := uint64(f.p)
+		bm := uint64(bigModSmall(p.b, f.p))
+		mm := uint64(s.m) % pp
+		f.r1 = uint32((uint64(f.ainv)*((uint64(f.t)+pp-bm)%pp) + mm) % pp)
+		f.r2 = uint32((uint64(f.ainv)*((2*pp-uint64(f.t)-bm)%pp) + mm) % pp)
+	}
+}
+
+// next switches to the i-th polynomial with the same A in Gray code order.
+// It returns false if all 2^(s-1) polynomials have been used.
+func (p *qsPoly) next(s *qs, i int) bool {
+	if i >= 1<<uint(len(p.qidx)-1) {
+		return false
+	}
+	// B_v changes its sign, the sign of B_s stays positive
+	v := bits.TrailingZeros(uint(i))
+	neg := (i^i>>1)>>uint(v)&1 == 1
+	d := new(big.Int).Lsh(p.bl[v], 1)
+	if neg {
+		p.b.Sub(p.b, d)
+	} else {
+		p.b.Add(p.b, d)
+	}
+	p.c.Mul(p.b, p.b)
+	p.c.Sub(p.c, s.kn)
+	p.c.Quo(p.c, p.a)
+	bainv := p.bainv[v]
+	for j := range s.fb {
+		f := &s.fb[j]
+		if f.ainv == 0 {
+			continue
+		}
+		// the roots ainv*(±t - B) move by ±2*B_v/A
+		delta := bainv[j]
+		if !neg {
+			delta = f.p - delta
+		}
+		f.r1 += delta
+		if f.r1 >= f.p {
+			f.r1 -= f.p
+		}
+		f.r2 += delta
+		if f.r2 >= f.p {
+			f.r2 -= f.p
+		}
+	}
+	return true
+}
+
+// sieve adds the logarithms of the factor base primes at the roots of the current polynomial.
+func (s *qs) sieve(sieve []uint8) {
+	for i := range sieve {
+		sieve[i] = 0
+	}
+	l := uint32(len(sieve))
+	for _, f := range s.fb {
+		if f.ainv == 0 {
+			continue
+		}
+		for j := f.r1; j < l; j += f.p {
+			sieve[j] += f.logp
+		}
+		if f.r2 != f.r1 {
+			for j := f.r2; j < l; j += f.p {
+				sieve[j] += f.logp
+			}
+		}
+	}
+}
+
+// check trial divides Q(x) at the sieve position j and records a relation.
+// It returns a factor if a large prime divides n.
+func (s *qs) check(p *qsPoly, j int) *big.Int {
+	x := big.NewInt(int64(j - s.m))
+	y := new(big.Int).Mul(p.a, x)
+	y.Add(y, p.b)
+	q := new(big.Int).Add(y, p.b)
+	q.Mul(q, x)
+	q.Add(q, p.c)
+	var cols []int
+	if q.Sign() < 0 {
+		cols = append(cols, 0)
+		q.Neg(q)
+	}
+	// A*Q(x) is a square modulo kn
+	for _, i := range p.qidx {
+		cols = append(cols, i+1)
+	}
+	bp := new(big.Int)
+	for i, f := range s.fb {
+		if f.ainv != 0 {
+			jm := uint32(j) % f.p
+			if jm != f.r1 && jm != f.r2 {
+				continue
+			}
+		} else if bigModSmall(q, f.p) != 0 {
+			continue
+		}
+		bp.SetUint64(uint64(f.p))
+		for bigModSmall(q, f.p) == 0 {
+			q.Quo(q, bp)
+			cols = append(cols, i+1)
+		}
+	}
+	if !q.IsUint64() || q.Uint64() >= s.lpBound {
+		return nil
+	}
+	r := qsRelation{y: y.Mod(y, s.n), cols: cols}
+	key := string(r.y.Bytes())
+	if s.seen[key] {
+		return nil
+	}
+	s.seen[key] = true
+	l := q.Uint64()
+	if l == 1 {
+		s.rels = append(s.rels, r)
+		return nil
+	}
+	if bigModSmall(s.n, uint32(l)) == 0 {
+		return q
+	}
+	// two partial relations with the same large prime give a full relation
+	r2, ok := s.partial[l]
+	if !ok {
+		s.partial[l] = r
+		return nil
+	}
+	r.y.Mul(r.y, r2.y)
+	r.y.Mod(r.y, s.n)
+	r.cols = append(r.cols, r2.cols...)
+	r.large = l
+	s.rels = append(s.rels, r)
+	return nil
+}
+
+// combine finds the congruences of squares and returns the first proper factor.
+func (s *qs) combine() (*big.Int, error) {
+	m := gf2.NewMatrix(len(s.fb) + 1)
+	for _, r := range s.rels {
+		m.AddColumn(r.cols)
+	}
+	deps, err := gf2.Nullspace(m, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range deps {
+		x := big.NewInt(1)
+		y := big.NewInt(1)
+		exps := make([]int, len(s.fb)+1)
+		for _, i := range dep {
+			r := s.rels[i]
+			x.Mul(x, r.y)
+			x.Mod(x, s.n)
+			for _, c := range r.cols {
+				exps[c]++
+			}
+			if r.large != 0 {
+				y.Mul(y, new(big.Int).SetUint64(r.large))
+				y.Mod(y, s.n)
+			}
+		}
+		bp := new(big.Int)
+		for c := 1; c < len(exps); c++ {
+			if exps[c]%2 != 0 {
+				panic(errors.New("dependency with an odd exponent"))
+			}
+			if exps[c] > 0 {
+				bp.Exp(bp.SetUint64(uint64(s.fb[c-1].p)), big.NewInt(int64(exps[c]/2)), s.n)
+				y.Mul(y, bp)
+				y.Mod(y, s.n)
+			}
+		}
+		d := new(big.Int).Sub(x, y)
+		d.GCD(nil, nil, d.Abs(d), s.n)
+		if isProper(d, s.n) {
+			return d, nil
+		}
+	}
+	return nil, ErrNoFactor
+}
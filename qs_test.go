@@ -0,0 +1,138 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestQuadraticSieve(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     string
+		short bool
+	}{
+		{"n1", "43217358712783469", true},
+		{"100 bits", "790517487536168049864781915013", true},
+		{"140 bits", "864562647475485390421481978564353897054967", true},
+		{"160 bits", "1202996177785000399840872738425823239418923394409", true},
+		{"180 bits", "1145644709045793541847679232402196083378112583976356253", false},
+		// a balanced 200 bit semiprime like in TestEcParallel2
+		{"200 bits", "1315829514646776606431252302739765950090740703473269998074467", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.short && testing.Short() {
+				t.Skip("skipped in short mode")
+			}
+			n := intval(tt.n)
+			fac, err := QuadraticSieve(context.Background(), n, nil)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !isProper(fac, n) || new(big.Int).Mod(n, fac).Sign() != 0 {
+				t.Error("invalid factor", fac)
+			}
+		})
+	}
+}
+
+func TestQuadraticSieveErrors(t *testing.T) {
+	ctx := context.Background()
+	if _, err := QuadraticSieve(ctx, big.NewInt(1000003*1009), nil); err == nil {
+		t.Error("expected an error for a small n")
+	}
+	// 2^61-1 is prime
+	if _, err := QuadraticSieve(ctx, intval("2305843009213693951"), nil); err != ErrNoFactor {
+		t.Errorf("got %v, want ErrNoFactor", err)
+	}
+	fac, err := QuadraticSieve(ctx, intval("2305843009213693952"), nil)
+	if err != nil || fac.Int64() != 2 {
+		t.Errorf("got %v, %v, want 2", fac, err)
+	}
+	p := intval("1000000000000000000000000000057")
+	fac, err = QuadraticSieve(ctx, new(big.Int).Mul(p, p), nil)
+	if err != nil || fac.Cmp(p) != 0 {
+		t.Errorf("got %v, %v, want %v", fac, err, p)
+	}
+	// the factor is in the factor base
+	fac, err = QuadraticSieve(ctx, new(big.Int).Mul(p, big.NewInt(1009)), nil)
+	if err != nil || fac.Int64() != 1009 {
+		t.Errorf("got %v, %v, want 1009", fac, err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = QuadraticSieve(cctx, intval("1202996177785000399840872738425823239418923394409"), nil)
+	if !errors.Is(err, ErrCancelled) {
+		t.Errorf("got %v, want ErrCancelled", err)
+	}
+	_, err = QuadraticSieve(ctx, intval("1202996177785000399840872738425823239418923394409"), &QSOptions{FactorBase: 5})
+	if err == nil {
+		t.Error("expected an error for a small factor base")
+	}
+}
+
+func TestQSRelations(t *testing.T) {
+	n := intval("1202996177785000399840872738425823239418923394409")
+	o, err := (*QSOptions)(nil).withDefaults(n)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	s, fac := newQS(n, o)
+	if fac != nil {
+		t.Fatal("unexpected factor", fac)
+	}
+	// the roots are zeros of the polynomials modulo the factor base primes
+	qidx, err := s.chooseA()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	p := s.newPoly(qidx)
+	for i := 0; i < 1<<uint(len(qidx)-1); i++ {
+		if i > 0 && !p.next(s, i) {
+			t.Fatal("too few polynomials")
+		}
+		for _, f := range s.fb {
+			if f.ainv == 0 {
+				continue
+			}
+			for _, r := range []uint32{f.r1, f.r2} {
+				x := big.NewInt(int64(r) - int64(s.m))
+				v := new(big.Int).Mul(p.a, x)
+				v.Add(v, p.b)
+				v.Add(v, p.b)
+				v.Mul(v, x)
+				v.Add(v, p.c)
+				if v.Mod(v, big.NewInt(int64(f.p))).Sign() != 0 {
+					t.Fatalf("polynomial %v: %v is no root modulo %v", i, r, f.p)
+				}
+			}
+		}
+	}
+	if p.next(s, 1<<uint(len(qidx)-1)) {
+		t.Error("too many polynomials")
+	}
+	// y^2 is the product of the factors
+	if fac, err := s.collect(context.Background()); fac != nil || err != nil {
+		t.Fatal("unexpected result", fac, err)
+	}
+	for _, r := range s.rels {
+		prod := big.NewInt(1)
+		for _, c := range r.cols {
+			if c == 0 {
+				prod.Neg(prod)
+			} else {
+				prod.Mul(prod, big.NewInt(int64(s.fb[c-1].p)))
+			}
+		}
+		l := new(big.Int).SetUint64(r.large)
+		if r.large != 0 {
+			prod.Mul(prod, l.Mul(l, l))
+		}
+		y2 := new(big.Int).Mul(r.y, r.y)
+		if y2.Sub(y2, prod).Mod(y2, n).Sign() != 0 {
+			t.Fatal("invalid relation", r)
+		}
+	}
+}
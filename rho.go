@@ -2,42 +2,192 @@ package intfact
 
 import (
 	"context"
-	"errors"
 	"math/big"
 )
 
+// RhoOptions contains the parameters of RhoWithOptions.
+// A nil pointer or zero fields select the defaults.
+type RhoOptions struct {
+	// X0 is the start value of the sequence (default 2).
+	X0 *big.Int
+	// C is the constant of the polynomial x^2+c (default 1).
+	C *big.Int
+	// Floyd selects Floyd's cycle detection instead of Brent's.
+	Floyd bool
+	// Batch is the number of differences multiplied before a gcd is calculated (default 100).
+	Batch int
+	// Iterations limits the total number of differences computed. A limit of 0 means no limit.
+	Iterations int
+	// NoRestart disables the restart with the next constant c if the gcd collapses to n.
+	NoRestart bool
+}
+
+func (o *RhoOptions) withDefaults() RhoOptions {
+	var r RhoOptions
+	if o != nil {
+		r = *o
+	}
+	if r.X0 == nil {
+		r.X0 = big.NewInt(2)
+	}
+	if r.C == nil {
+		r.C = big.NewInt(1)
+	}
+	if r.Batch <= 0 {
+		r.Batch = 100
+	}
+	return r
+}
+
 // Rho tries to factor n with Pollard's rho method.
+// It is RhoWithOptions with the default options, i.e. Brent's cycle detection
+// for the polynomial x^2+1 starting at 2.
 // The function does not return until a factor is found (or an error occurred) or the execution is
 // cancelled via the context.
 //
-// The function returns a factor if one was found or otherwise an error.
+// The function returns a factor if one was found or otherwise ErrTrivialGCD if
+// all restarts failed, e.g. because n is prime, or an error matching ErrCancelled.
 func Rho(ctx context.Context, n *big.Int) (fac *big.Int, err error) {
-	a := big.NewInt(2)
-	c := &big.Int{}
-	c.SetUint64(1)
-	f := func(x *big.Int) *big.Int {
-		r := new(big.Int).Mul(x, x)
-		r.Add(r, c)
-		r.Mod(r, n)
-		return r
-	}
-
-	l := f(a)
-	h := f(f(a))
-	gcd := newGcdtest(n, 20)
-	for {
+	return RhoWithOptions(ctx, n, nil)
+}
+
+// RhoWithOptions tries to factor n with Pollard's rho method using the polynomial x^2+c.
+// The differences of the sequence are multiplied in batches before a gcd is calculated.
+// If the gcd of a batch collapses to n, the batch is repeated with a gcd for every single
+// difference. If this still gives n, the computation is restarted with the constant c+1
+// unless the options say otherwise. After rhoRestarts restarts it gives up, so that
+// it returns for prime n even without an iteration limit.
+// For odd n < 2^64 the sequence is computed with Montgomery arithmetic on uint64.
+//
+// The function returns a factor if one was found or otherwise ErrNoFactor if the iteration limit was reached,
+// ErrTrivialGCD if no restart was allowed or all restarts failed or an error matching ErrCancelled.
+func RhoWithOptions(ctx context.Context, n *big.Int, opts *RhoOptions) (fac *big.Int, err error) {
+	o := opts.withDefaults()
+	if fitsUint64(n) {
+		return rhoUint64(ctx, n.Uint64(), o)
+	}
+	return rhoBig(ctx, n, o)
+}
+
+// rhoRestarts bounds the number of restarts with the next constant c.
+const rhoRestarts = 20
+
+// rhoBig is RhoWithOptions with big.Int arithmetic.
+func rhoBig(ctx context.Context, n *big.Int, o RhoOptions) (fac *big.Int, err error) {
+	c := new(big.Int).Mod(o.C, n)
+	nm2 := new(big.Int).Sub(n, big.NewInt(2))
+	limit := o.Iterations
+	for restarts := 0; ; restarts++ {
+		f := func(x *big.Int) *big.Int {
+			r := new(big.Int).Mul(x, x)
+			r.Add(r, c)
+			r.Mod(r, n)
+			return r
+		}
+		var s rhoState
+		var step func(*rhoState)
+		if o.Floyd {
+			s.a = f(o.X0)
+			s.b = f(s.a)
+			step = func(s *rhoState) {
+				s.a = f(s.a)
+				s.b = f(f(s.b))
+			}
+		} else {
+			s.a = new(big.Int).Mod(o.X0, n)
+			s.b = f(s.a)
+			s.r = 1
+			s.k = 1
+			step = func(s *rhoState) {
+				if s.k == s.r {
+					s.a = s.b
+					s.r *= 2
+					s.k = 0
+				}
+				s.b = f(s.b)
+				s.k++
+			}
+		}
+		var steps int
+		fac, steps, err = rhoRun(ctx, n, s, step, o.Batch, limit)
+		if err != ErrTrivialGCD || o.NoRestart || restarts == rhoRestarts {
+			return
+		}
+		if o.Iterations > 0 {
+			limit -= steps
+			if limit <= 0 {
+				return nil, ErrNoFactor
+			}
+		}
+		// c = 0 and c = -2 give degenerate sequences
+		for {
+			c = new(big.Int).Add(c, bigOne)
+			c.Mod(c, n)
+			if c.Sign() != 0 && c.Cmp(nm2) != 0 {
+				break
+			}
+		}
+	}
+}
+
+// rhoState describes the position in the sequence. The values a and b are compared.
+// For Brent's cycle detection a is replaced by b after r steps, and k counts the steps since then.
+// The values are never modified, so the state can be copied.
+type rhoState struct {
+	a, b *big.Int
+	r, k int
+}
+
+func (s *rhoState) diff() *big.Int {
+	d := new(big.Int).Sub(s.b, s.a)
+	return d.Abs(d)
+}
+
+// rhoRun iterates the sequence starting at s until a factor is found or limit steps are done.
+// It returns the factor or an error and the number of steps done.
+func rhoRun(ctx context.Context, n *big.Int, s rhoState, step func(*rhoState), batch, limit int) (fac *big.Int, steps int, err error) {
+	gcd := newGcdtest(n, batch)
+	saved := s
+	for ; limit == 0 || steps < limit; steps++ {
 		select {
 		case <-ctx.Done():
-			return nil, errors.New("cancelled")
+			return nil, steps, cancelled(ctx)
 		default:
 		}
-		d := new(big.Int).Sub(h, l)
-		d.Abs(d)
-		fac, err = gcd.test(d)
+		if gcd.it == 0 {
+			saved = s
+		}
+		fac, err = gcd.test(s.diff())
+		if err == ErrTrivialGCD {
+			fac, err = rhoBacktrack(n, saved, step)
+			return
+		}
 		if fac != nil || err != nil {
 			return
 		}
-		l = f(l)
-		h = f(f(h))
+		step(&s)
+	}
+	fac, err = gcd.finish()
+	if err == ErrTrivialGCD {
+		fac, err = rhoBacktrack(n, saved, step)
+		return
+	}
+	if fac == nil && err == nil {
+		err = ErrNoFactor
+	}
+	return
+}
+
+// rhoBacktrack repeats a batch starting at s with a gcd for every single difference.
+func rhoBacktrack(n *big.Int, s rhoState, step func(*rhoState)) (*big.Int, error) {
+	for {
+		d := new(big.Int).GCD(nil, nil, s.diff(), n)
+		if d.Cmp(bigOne) != 0 {
+			if d.Cmp(n) == 0 {
+				return nil, ErrTrivialGCD
+			}
+			return d, nil
+		}
+		step(&s)
 	}
 }
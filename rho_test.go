@@ -2,6 +2,8 @@ package intfact
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"math/big"
 	"testing"
@@ -14,6 +16,7 @@ func TestRho(t *testing.T) {
 	// n=2^2^7+1
 	// takes 1227.18s in original version
 	// takes 731.99s in optimized version
+	// takes 277.91s with Brent's cycle detection, see TestRhoF7
 	//n, _ := new(big.Int).SetString("340282366920938463463374607431768211457", 10)
 	// n=2^67-1
 	//n, _ := new(big.Int).SetString("147573952589676412927", 10)
@@ -21,3 +24,101 @@ func TestRho(t *testing.T) {
 	d, err := Rho(ctx, n)
 	fmt.Printf("d=%v, e=%v\n", d, err)
 }
+
+// long enables tests that take many minutes.
+var long = flag.Bool("long", false, "run tests that take many minutes")
+
+func TestRhoF7(t *testing.T) {
+	if !*long {
+		t.Skip("needs -long")
+	}
+	// n=2^2^7+1
+	n := intval("340282366920938463463374607431768211457")
+	start := time.Now()
+	d, err := Rho(context.Background(), n)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if d.String() != "59649589127497217" && d.String() != "5704689200685129054721" {
+		t.Error("invalid factor", d)
+	}
+	t.Log("took", time.Since(start))
+}
+
+func TestRhoCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	// n=2^2^7+1
+	n, _ := new(big.Int).SetString("340282366920938463463374607431768211457", 10)
+	_, err := Rho(ctx, n)
+	if !errors.Is(err, ErrCancelled) {
+		t.Errorf("got %v, want ErrCancelled", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRhoWithOptions(t *testing.T) {
+	n := intval("147573952589676412927") // 2^67-1
+	tests := []struct {
+		name string
+		opts *RhoOptions
+		want error
+	}{
+		{
+			name: "default",
+			opts: nil,
+		},
+		{
+			name: "floyd",
+			opts: &RhoOptions{Floyd: true},
+		},
+		{
+			name: "start",
+			opts: &RhoOptions{X0: big.NewInt(7), C: big.NewInt(3), Batch: 10},
+		},
+		{
+			name: "limit",
+			opts: &RhoOptions{Iterations: 100},
+			want: ErrNoFactor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fac, err := RhoWithOptions(context.Background(), n, tt.opts)
+			if err != tt.want {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if err == nil && fac.Cmp(big.NewInt(193707721)) != 0 && fac.Cmp(big.NewInt(761838257287)) != 0 {
+				t.Error("invalid factor", fac)
+			}
+		})
+	}
+}
+
+func TestRhoRestart(t *testing.T) {
+	// both cycles are detected at the same time for x^2+1
+	n := big.NewInt(149 * 181)
+	for _, floyd := range []bool{false, true} {
+		_, err := RhoWithOptions(context.Background(), n, &RhoOptions{Floyd: floyd, NoRestart: true})
+		if err != ErrTrivialGCD {
+			t.Errorf("got %v, want ErrTrivialGCD", err)
+		}
+		fac, err := RhoWithOptions(context.Background(), n, &RhoOptions{Floyd: floyd})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if fac.Int64() != 149 && fac.Int64() != 181 {
+			t.Error("invalid factor", fac)
+		}
+	}
+	// the restarts are bounded for prime n
+	p := big.NewInt(1000003)
+	if _, err := Rho(context.Background(), p); err != ErrTrivialGCD {
+		t.Errorf("got %v, want ErrTrivialGCD", err)
+	}
+	if _, err := rhoBig(context.Background(), p, (*RhoOptions)(nil).withDefaults()); err != ErrTrivialGCD {
+		t.Errorf("got %v, want ErrTrivialGCD", err)
+	}
+}
@@ -0,0 +1,198 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+)
+
+// SNFSForm describes n as a divisor of B^K + C with a small C, like the
+// Cunningham numbers b^k + 1 and b^k - 1.
+type SNFSForm struct {
+	B int64
+	K int
+	C int64
+}
+
+// value returns B^K + C.
+func (f SNFSForm) value() *big.Int {
+	v := new(big.Int).Exp(big.NewInt(f.B), big.NewInt(int64(f.K)), nil)
+	return v.Add(v, big.NewInt(f.C))
+}
+
+// snfsMaxBase is the largest base tried by DetectSNFSForm.
+const snfsMaxBase = 12
+
+// snfsMaxRatio bounds the size of b^k relative to n for DetectSNFSForm.
+// For a larger b^k the general number field sieve is faster.
+const snfsMaxRatio = 1.5
+
+// DetectSNFSForm returns a form b^k + 1 or b^k - 1 with b up to 12 of which n
+// is a divisor, if b^k has at most 1.5 times the size of n. Among several
+// forms it returns the one with the smallest b^k.
+func DetectSNFSForm(n *big.Int) (SNFSForm, bool) {
+	var best SNFSForm
+	bestBits := math.Inf(1)
+	if n.Cmp(big.NewInt(snfsMaxBase)) <= 0 {
+		return best, false
+	}
+	maxBits := snfsMaxRatio * float64(n.BitLen())
+	nm1 := new(big.Int).Sub(n, bigOne)
+	t := new(big.Int)
+	bb := new(big.Int)
+	for b := int64(2); b <= snfsMaxBase; b++ {
+		lb := math.Log2(float64(b))
+		bb.SetInt64(b)
+		t.SetInt64(1)
+		for k := 1; float64(k)*lb <= maxBits && float64(k)*lb < bestBits; k++ {
+			t.Mul(t, bb)
+			t.Mod(t, n)
+			if t.Sign() == 0 {
+				// n divides a power of b
+				break
+			}
+			var c int64
+			switch {
+			case t.Cmp(bigOne) == 0:
+				c = -1
+			case t.Cmp(nm1) == 0:
+				c = 1
+			default:
+				continue
+			}
+			best, bestBits = SNFSForm{B: b, K: k, C: c}, float64(k)*lb
+			break
+		}
+	}
+	return best, !math.IsInf(bestBits, 1)
+}
+
+// SNFSPoly returns the polynomial pair of the form for the given degree.
+// With K = d*m + r it is either B^r x^d + C with the root B^m or
+// x^d + C B^(d-r) with the root B^(m+1), whichever has the smaller coefficients.
+// If degree is 0, the degree is chosen by the estimated smoothness probability of
+// the values of the polynomials. The algebraic polynomial must be irreducible modulo some
+// prime for the square root, degrees where it is not are skipped.
+func SNFSPoly(form SNFSForm, degree int) (*NFSPoly, error) {
+	if form.B < 2 || form.C == 0 || form.K < 2 {
+		return nil, errors.New("invalid form")
+	}
+	if degree != 0 {
+		f := snfsPoly(form, degree)
+		if f == nil {
+			return nil, errors.New("no suitable polynomial of this degree")
+		}
+		return f, nil
+	}
+	bits := float64(form.K) * math.Log2(float64(form.B))
+	// the binary logarithms of the size of the sieve region and of the
+	// smoothness bound
+	logA := 10 + bits/30
+	logB := 12 + bits/30
+	var best *NFSPoly
+	bestScore := math.Inf(1)
+	for d := 3; d <= 8; d++ {
+		f := snfsPoly(form, d)
+		if f == nil {
+			continue
+		}
+		// the values of F are about sqrt(F[d] F[0]) A^d, the values of G
+		// about M A / skew with the skewness (F[0]/F[d])^(1/d)
+		ld := bigLog(new(big.Int).Abs(f.F[d])) / math.Ln2
+		l0 := bigLog(new(big.Int).Abs(f.F[0])) / math.Ln2
+		lm := bigLog(new(big.Int).Neg(f.G[0])) / math.Ln2
+		ua := ((ld+l0)/2 + float64(d)*logA) / logB
+		ur := (lm + logA - (l0-ld)/float64(d)) / logB
+		// the probability that both values are smooth is about u^-u for each
+		score := ua*math.Log(ua) + ur*math.Log(ur)
+		if score < bestScore {
+			best, bestScore = f, score
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no suitable polynomial")
+	}
+	return best, nil
+}
+
+// snfsPoly returns the polynomial pair of degree d, or nil if the degree is
+// too large for the form or F has no inert prime.
+func snfsPoly(form SNFSForm, d int) *NFSPoly {
+	m, r := form.K/d, form.K%d
+	if m < 1 {
+		return nil
+	}
+	b := big.NewInt(form.B)
+	c := big.NewInt(form.C)
+	pow := func(e int) *big.Int {
+		return new(big.Int).Exp(b, big.NewInt(int64(e)), nil)
+	}
+	f := make([]*big.Int, d+1)
+	for i := range f {
+		f[i] = new(big.Int)
+	}
+	root := pow(m)
+	f[d].Set(pow(r))
+	f[0].Set(c)
+	if r > 0 && d-r < r {
+		// x^d + C B^(d-r) with the root B^(m+1) has the smaller coefficients
+		f[d].SetInt64(1)
+		f[0].Mul(c, pow(d-r))
+		root = pow(m + 1)
+	}
+	if _, ok := nfsInertPrime(f, 1<<20); !ok {
+		return nil
+	}
+	return &NFSPoly{F: f, G: [2]*big.Int{root.Neg(root), big.NewInt(1)}}
+}
+
+// snfsDigits returns the number of digits of a general number with about the
+// same difficulty as a special number with the given number of digits.
+func snfsDigits(digits float64) int {
+	return int(digits * 0.7)
+}
+
+// SNFS tries to find a factor of n with the special number field sieve (experimental).
+// n must be a divisor of the value of the form. If form is nil, DetectSNFSForm is used.
+// The polynomial pair is built from the form with SNFSPoly and the sieving and the
+// linear algebra are those of NFS, with the default parameters chosen by the size
+// of the form instead of the size of n. Poly in opts is ignored, a degree in
+// opts selects the degree of the polynomial pair.
+//
+// The function returns a factor if one was found or otherwise ErrNoFactor if n is
+// prime, or an error matching ErrCancelled.
+func SNFS(ctx context.Context, n *big.Int, form *SNFSForm, opts *NFSOptions) (*big.Int, error) {
+	if fac, err := nfsPrecheck(n); fac != nil || err != nil {
+		return fac, err
+	}
+	var sf SNFSForm
+	if form != nil {
+		sf = *form
+	} else {
+		var ok bool
+		if sf, ok = DetectSNFSForm(n); !ok {
+			return nil, errors.New("n has no special form")
+		}
+	}
+	if sf.B < 2 || sf.K < 2 || sf.C == 0 {
+		return nil, errors.New("invalid form")
+	}
+	v := sf.value()
+	if v.Sign() <= 0 || new(big.Int).Mod(v, n).Sign() != 0 {
+		return nil, errors.New("n does not divide the value of the form")
+	}
+	var o NFSOptions
+	if opts != nil {
+		o = *opts
+	}
+	var err error
+	if o.Poly, err = SNFSPoly(sf, o.Degree); err != nil {
+		return nil, err
+	}
+	o.Degree = o.Poly.degree()
+	if o, err = o.withDigits(n, snfsDigits(bigLog(v)/math.Ln10)); err != nil {
+		return nil, err
+	}
+	return nfsRun(ctx, n, o)
+}
@@ -0,0 +1,134 @@
+package intfact
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestDetectSNFSForm(t *testing.T) {
+	tests := []struct {
+		n    *big.Int
+		want SNFSForm
+		ok   bool
+	}{
+		{SNFSForm{2, 128, 1}.value(), SNFSForm{2, 128, 1}, true},
+		{SNFSForm{2, 137, -1}.value(), SNFSForm{2, 137, -1}, true},
+		// a divisor of 2^167-1
+		{intval("79638304766856507377778616296087448490695649"), SNFSForm{2, 167, -1}, true},
+		{SNFSForm{10, 40, 1}.value(), SNFSForm{10, 40, 1}, true},
+		{SNFSForm{7, 60, -1}.value(), SNFSForm{7, 60, -1}, true},
+		{intval("84055899507848841139275608657"), SNFSForm{}, false},
+		{big.NewInt(7), SNFSForm{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := DetectSNFSForm(tt.n)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%v: got %v, %v, want %v, %v", tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSNFSPoly(t *testing.T) {
+	// the algebraic polynomials without inert prime
+	unsuitable := map[SNFSForm][]int{
+		{2, 128, 1}: {4},    // x^4+1
+		{3, 101, 1}: {6},    // x^6+3
+		{2, 400, 1}: {4, 5}, // x^4+1, x^5+1
+	}
+	for _, form := range []SNFSForm{{2, 128, 1}, {2, 137, -1}, {2, 149, -1}, {3, 101, 1}, {10, 61, -1}, {2, 400, 1}} {
+		for _, d := range []int{0, 3, 4, 5, 6} {
+			f, err := SNFSPoly(form, d)
+			bad := false
+			for _, u := range unsuitable[form] {
+				bad = bad || u == d
+			}
+			if bad {
+				if err == nil {
+					t.Errorf("%v degree %v: expected an error", form, d)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%v degree %v: unexpected error %v", form, d, err)
+				continue
+			}
+			if d != 0 && f.degree() != d {
+				t.Errorf("%v: got degree %v, want %v", form, f.degree(), d)
+			}
+			if fac, err := f.check(form.value()); fac != nil || err != nil {
+				t.Errorf("%v degree %v: check returned %v, %v", form, d, fac, err)
+			}
+		}
+	}
+	if f, err := SNFSPoly(SNFSForm{2, 400, 1}, 0); err != nil || f.degree() < 5 {
+		t.Errorf("got %v, %v, want a degree of at least 5", f, err)
+	}
+	for _, form := range []SNFSForm{{1, 100, 1}, {2, 100, 0}, {2, 1, 1}} {
+		if _, err := SNFSPoly(form, 0); err == nil {
+			t.Errorf("%v: expected an error", form)
+		}
+	}
+}
+
+func TestSNFS(t *testing.T) {
+	tests := []struct {
+		name  string
+		form  SNFSForm
+		div   int64
+		short bool
+	}{
+		{"2^128+1", SNFSForm{2, 128, 1}, 1, true},
+		{"2^149-1", SNFSForm{2, 149, -1}, 1, true},
+		{"2^157-1", SNFSForm{2, 157, -1}, 1, false},
+		{"(2^179+1)/3", SNFSForm{2, 179, 1}, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.short && testing.Short() {
+				t.Skip("skipped in short mode")
+			}
+			n := tt.form.value()
+			n.Quo(n, big.NewInt(tt.div))
+			for _, form := range []*SNFSForm{nil, &tt.form} {
+				fac, err := SNFS(context.Background(), n, form, nil)
+				if err != nil {
+					t.Fatal("unexpected error", err)
+				}
+				if !isProper(fac, n) || new(big.Int).Mod(n, fac).Sign() != 0 {
+					t.Error("invalid factor", fac)
+				}
+				if !tt.short {
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSNFSErrors(t *testing.T) {
+	ctx := context.Background()
+	n := SNFSForm{2, 128, 1}.value()
+	if _, err := SNFS(ctx, intval("84055899507848841139275608657"), nil, nil); err == nil {
+		t.Error("expected an error for a number without special form")
+	}
+	if _, err := SNFS(ctx, n, &SNFSForm{2, 129, 1}, nil); err == nil {
+		t.Error("expected an error for a wrong form")
+	}
+	if _, err := SNFS(ctx, n, &SNFSForm{2, 128, 0}, nil); err == nil {
+		t.Error("expected an error for an invalid form")
+	}
+	if _, err := SNFS(ctx, n, nil, &NFSOptions{Degree: 4}); err == nil {
+		t.Error("expected an error for x^4+1")
+	}
+	// 2^127-1 is prime
+	if _, err := SNFS(ctx, SNFSForm{2, 127, -1}.value(), nil, nil); err != ErrNoFactor {
+		t.Errorf("got %v, want ErrNoFactor", err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := SNFS(cctx, n, nil, nil); !errors.Is(err, ErrCancelled) {
+		t.Errorf("got %v, want ErrCancelled", err)
+	}
+}
@@ -8,6 +8,10 @@ import (
 
 // TrialDivision tries to factor the list by trial division with small primes.
 func (l *Factors) TrialDivision(bound uint32) {
+	var want *big.Int
+	if l.Debug {
+		want = l.Product()
+	}
 	oldlist := l.First
 	l.First = nil
 	for f := oldlist; f != nil; f = f.Next {
@@ -34,4 +38,7 @@ func (l *Factors) TrialDivision(bound uint32) {
 		}
 	}
 	l.PBound = big.NewInt(int64(bound))
+	if l.Debug {
+		l.check("TrialDivision", want)
+	}
 }
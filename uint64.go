@@ -0,0 +1,313 @@
+package intfact
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// FactorUint64 returns the prime factors of n in increasing order, with multiplicity.
+// It returns nil for n < 2. After trial division by small primes, it splits
+// the cofactors with HartOLF and Lehman below 2^42 and with Squfof above,
+// and falls back to Pollard rho. The primality of the factors is proven.
+func FactorUint64(n uint64) []uint64 {
+	if n < 2 {
+		return nil
+	}
+	var fs []uint64
+	for n%2 == 0 {
+		fs = append(fs, 2)
+		n /= 2
+	}
+	for d := uint64(3); d < smallTrialBound && d*d <= n; d += 2 {
+		for n%d == 0 {
+			fs = append(fs, d)
+			n /= d
+		}
+	}
+	if n > 1 {
+		fs = factor64(n, fs)
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i] < fs[j] })
+	return fs
+}
+
+// smallTrialBound is the bound of the trial division in FactorUint64.
+const smallTrialBound = 1000
+
+// factor64 appends the prime factors of n > 1 to fs.
+func factor64(n uint64, fs []uint64) []uint64 {
+	if isPrime64(n) {
+		return append(fs, n)
+	}
+	if s := isqrt64(n); s*s == n {
+		return factor64(s, factor64(s, fs))
+	}
+	d := split64(n)
+	return factor64(n/d, factor64(d, fs))
+}
+
+// split64 returns a proper factor of the composite n, which is no square.
+func split64(n uint64) uint64 {
+	var d uint64
+	if n < 1<<42 {
+		if d = HartOLF(n); d == 0 {
+			d = Lehman(n)
+		}
+	} else {
+		d = Squfof(n)
+	}
+	if d == 0 {
+		d = rho64(n)
+	}
+	if d == 0 {
+		// not reached in practice
+		for d = 2; n%d != 0; d++ {
+		}
+	}
+	return d
+}
+
+// isqrt64 returns the integer square root of x, rounded down.
+func isqrt64(x uint64) uint64 {
+	s := uint64(math.Sqrt(float64(x)))
+	for s > math.MaxUint32 || s*s > x {
+		s--
+	}
+	for s < math.MaxUint32 && (s+1)*(s+1) <= x {
+		s++
+	}
+	return s
+}
+
+// isPrime64 is a Miller-Rabin test with the first twelve primes as bases,
+// which is deterministic for all n < 2^64. It uses Montgomery arithmetic.
+func isPrime64(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	bases := []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37}
+	for _, p := range bases {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+	m := newMont64(n)
+	for _, a := range bases {
+		if !m.sprp(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// HartOLF returns a proper factor of the odd composite n < 2^42 with Hart's
+// one line factoring algorithm, or 0 if it fails. It needs O(n^(1/3)) steps for
+// most n, but fails for squares.
+func HartOLF(n uint64) uint64 {
+	if n < 4 || n >= 1<<42 {
+		return 0
+	}
+	if n%2 == 0 {
+		return 2
+	}
+	limit := 4*uint64(math.Cbrt(float64(n))) + 100
+	if m := math.MaxUint64 / n; limit > m {
+		limit = m
+	}
+	for i := uint64(1); i <= limit; i++ {
+		ni := n * i
+		s := isqrt64(ni)
+		if s*s != ni {
+			s++
+		}
+		m := mulMod64(s, s, n)
+		if t := isqrt64(m); t*t == m {
+			if g := gcdU64(s-t, n); g > 1 && g < n {
+				return g
+			}
+		}
+	}
+	return 0
+}
+
+// Lehman returns a proper factor of the composite n < 2^45 with Lehman's
+// method, or 0 if n is prime. It needs O(n^(1/3)) steps.
+func Lehman(n uint64) uint64 {
+	if n < 4 || n >= 1<<45 {
+		return 0
+	}
+	if n%2 == 0 {
+		return 2
+	}
+	c := uint64(math.Cbrt(float64(n))) + 1
+	for d := uint64(3); d <= c; d += 2 {
+		if n%d == 0 {
+			return d
+		}
+	}
+	// without factors up to n^(1/3) there is a k <= n^(1/3) with
+	// a^2 - 4kn = b^2 for some a <= sqrt(4kn) + n^(1/6)/(4 sqrt(k))
+	sixth := math.Pow(float64(n), 1.0/6)
+	for k := uint64(1); k <= c; k++ {
+		fk := 4 * k * n
+		a := isqrt64(fk)
+		if a*a < fk {
+			a++
+		}
+		amax := uint64(math.Sqrt(float64(fk))+sixth/(4*math.Sqrt(float64(k)))) + 1
+		for ; a <= amax; a++ {
+			b2 := a*a - fk
+			if b := isqrt64(b2); b*b == b2 {
+				if g := gcdU64(a+b, n); g > 1 && g < n {
+					return g
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// squfofMultipliers are the multipliers tried by Squfof.
+var squfofMultipliers = []uint64{1, 3, 5, 7, 11, 3 * 5, 3 * 7, 3 * 11, 5 * 7, 5 * 11, 7 * 11,
+	3 * 5 * 7, 3 * 5 * 11, 3 * 7 * 11, 5 * 7 * 11, 3 * 5 * 7 * 11}
+
+// Squfof returns a proper factor of the odd composite n with Shanks' square
+// forms factorization, or 0 if it fails. The multipliers k with k*n < 2^62
+// are tried in turn. It needs O(n^(1/4)) steps.
+func Squfof(n uint64) uint64 {
+	if n < 4 {
+		return 0
+	}
+	if n%2 == 0 {
+		return 2
+	}
+	if s := isqrt64(n); s*s == n {
+		return s
+	}
+	for _, k := range squfofMultipliers {
+		if n > (1<<62)/k {
+			break
+		}
+		if d := squfof(n, k); d != 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// squfof runs the continued fraction expansion of sqrt(kn) until it finds
+// a proper square form that gives a factor of n.
+func squfof(n, k uint64) uint64 {
+	kn := k * n
+	s := int64(isqrt64(kn))
+	if uint64(s*s) == kn {
+		if g := gcdU64(uint64(s), n); g > 1 && g < n {
+			return g
+		}
+		return 0
+	}
+	limit := 3 * 2 * int64(isqrt64(2*uint64(s)))
+	p0, q0, q1 := s, int64(1), int64(kn)-s*s
+	for i := int64(2); i < limit; i++ {
+		b := (s + p0) / q1
+		p1 := b*q1 - p0
+		q0, q1 = q1, q0+b*(p0-p1)
+		p0 = p1
+		if i%2 != 0 {
+			continue
+		}
+		r := int64(isqrt64(uint64(q1)))
+		if r*r != q1 {
+			continue
+		}
+		// the reverse cycle from the square root of the form
+		b = (s - p0) / r
+		rp0 := b*r + p0
+		rq0, rq1 := r, (int64(kn)-rp0*rp0)/r
+		for j := int64(0); j < limit; j++ {
+			b = (s + rp0) / rq1
+			p1 = b*rq1 - rp0
+			rq0, rq1 = rq1, rq0+b*(rp0-p1)
+			if p1 == rp0 {
+				break
+			}
+			rp0 = p1
+		}
+		if g := gcdU64(uint64(rp0), n); g > 1 && g < n {
+			return g
+		}
+	}
+	return 0
+}
+
+// mulMod64 returns a*b mod n.
+func mulMod64(a, b, n uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Rem64(hi, lo, n)
+}
+
+// strongProbablePrime64 reports whether the odd n > a is a strong probable
+// prime to the base a.
+func strongProbablePrime64(n, a uint64) bool {
+	return newMont64(n).sprp(a)
+}
+
+// rho64 returns a proper factor of the composite n with Brent's variant
+// of Pollard rho, or 0 if it fails.
+func rho64(n uint64) uint64 {
+	if n%2 == 0 {
+		return 2
+	}
+	m := newMont64(n)
+	// f(y) = y^2 + c mod n, in Montgomery form
+	f := func(y, c uint64) uint64 {
+		return m.add(m.mul(y, y), c)
+	}
+	for i := uint64(1); i < 20; i++ {
+		c := m.to(i)
+		y, x, q := m.to(2), m.to(2), m.one
+		ys := y
+		g := uint64(1)
+		for r := 1; g == 1 && r < 1<<24; r *= 2 {
+			x = y
+			for i := 0; i < r; i++ {
+				y = f(y, c)
+			}
+			for k := 0; k < r && g == 1; k += 64 {
+				ys = y
+				for i := 0; i < 64 && i < r-k; i++ {
+					y = f(y, c)
+					d := x - y
+					if y > x {
+						d = y - x
+					}
+					q = m.mul(q, d)
+				}
+				g = gcdU64(q, n)
+			}
+		}
+		if g == n {
+			// backtrack from the saved point
+			for g = 1; g == 1; {
+				ys = f(ys, c)
+				d := x - ys
+				if ys > x {
+					d = ys - x
+				}
+				g = gcdU64(d, n)
+			}
+		}
+		if g != 1 && g != n {
+			return g
+		}
+	}
+	return 0
+}
+
+func gcdU64(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,161 @@
+package intfact
+
+import (
+	"math/big"
+	"testing"
+)
+
+// semiprimes64 are products of two primes of about the same size.
+var semiprimes64 = []uint64{
+	1000003 * 1000033,
+	65537 * 4294967291,
+	2147483647 * 2147483629,
+	3 * 4611686018427387847,
+	4294967291 * 4294967279,
+}
+
+func TestFactorUint64(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want []uint64
+	}{
+		{0, nil},
+		{1, nil},
+		{2, []uint64{2}},
+		{1 << 63, nil},
+		{720, []uint64{2, 2, 2, 2, 3, 3, 5}},
+		{1000003 * 1000003, []uint64{1000003, 1000003}},
+		{1000003 * 1000003 * 1009, []uint64{1009, 1000003, 1000003}},
+		{2147483647 * 2147483629, []uint64{2147483629, 2147483647}},
+		{4294967291 * 4294967279, []uint64{4294967279, 4294967291}},
+		{18446744073709551557, []uint64{18446744073709551557}},
+		{18446744073709551615, []uint64{3, 5, 17, 257, 641, 65537, 6700417}},
+		{65521 * 65521 * 65521 * 65519, []uint64{65519, 65521, 65521, 65521}},
+	}
+	for _, tt := range tests {
+		if tt.n == 1<<63 {
+			for i := 0; i < 63; i++ {
+				tt.want = append(tt.want, 2)
+			}
+		}
+		got := FactorUint64(tt.n)
+		if len(got) != len(tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.n, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%v: got %v, want %v", tt.n, got, tt.want)
+				break
+			}
+		}
+	}
+	// random numbers
+	x := uint64(1)
+	for i := 0; i < 2000; i++ {
+		x = x*6364136223846793005 + 1442695040888963407
+		n := x >> uint(i%40)
+		fs := FactorUint64(n)
+		prod := uint64(1)
+		for _, p := range fs {
+			if !big.NewInt(0).SetUint64(p).ProbablyPrime(20) {
+				t.Fatalf("%v: %v is not prime", n, p)
+			}
+			prod *= p
+		}
+		if n > 1 && prod != n {
+			t.Fatalf("%v: got %v", n, fs)
+		}
+	}
+}
+
+func TestSplit64(t *testing.T) {
+	methods := []struct {
+		name  string
+		f     func(uint64) uint64
+		limit uint64
+	}{
+		{"HartOLF", HartOLF, 1 << 42},
+		{"Lehman", Lehman, 1 << 45},
+		{"Squfof", Squfof, 1 << 62},
+	}
+	ns := append([]uint64{15, 1009 * 1013, 999983 * 1000003, 10007 * 10009 * 10037}, semiprimes64...)
+	for _, m := range methods {
+		found := 0
+		for _, n := range ns {
+			if n >= m.limit {
+				continue
+			}
+			d := m.f(n)
+			if d == 0 {
+				continue
+			}
+			found++
+			if d <= 1 || d >= n || n%d != 0 {
+				t.Errorf("%v(%v) = %v", m.name, n, d)
+			}
+		}
+		if found == 0 {
+			t.Errorf("%v found no factors", m.name)
+		}
+	}
+	// Lehman always succeeds
+	for _, n := range []uint64{15, 1009 * 1013, 999983 * 1000003, 4194301 * 4194319} {
+		if d := Lehman(n); d <= 1 || d >= n || n%d != 0 {
+			t.Errorf("Lehman(%v) = %v", n, d)
+		}
+	}
+	if d := Lehman(1000003); d != 0 {
+		t.Errorf("Lehman(1000003) = %v", d)
+	}
+}
+
+func TestIsPrime64(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want bool
+	}{
+		{0, false},
+		{1, false},
+		{2, true},
+		{37, true},
+		{1000003, true},
+		{4294967291, true},
+		{1000003 * 1000033, false},
+		// strong pseudoprimes to several bases
+		{3215031751, false},
+		{3825123056546413051, false},
+		{2305843009213693951, true},
+		{18446744073709551557, true},
+	}
+	for _, tt := range tests {
+		if got := isPrime64(tt.n); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRho64(t *testing.T) {
+	for _, n := range []uint64{15, 1000003 * 1000033, 65537 * 4294967291, 2147483647 * 2147483629} {
+		f := rho64(n)
+		if f <= 1 || f >= n || n%f != 0 {
+			t.Errorf("rho64(%v) = %v", n, f)
+		}
+	}
+	for _, tt := range []struct {
+		n    uint64
+		want bool
+	}{
+		{1000003, true},
+		{4294967291, true},
+		{1000003 * 1000033, false},
+		// a pseudoprime to the base 2
+		{2047, true},
+		{3215031751, true},
+		{2305843009213693951, true},
+	} {
+		if got := strongProbablePrime64(tt.n, 2); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}